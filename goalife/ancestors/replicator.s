@@ -0,0 +1,30 @@
+; A minimal replicator.  It wanders, eating whatever lies ahead of it, and
+; divides every few cells, giving its offspring half of its energy.  It
+; doesn't look before it acts, so it divides into any empty cell and eats
+; its own kind as readily as food.
+
+.macro half         ; A = 128
+    Zero
+    Shl1
+    Shl0 * 7
+.end
+
+.macro most         ; A = 255
+    Zero
+    Shl1 * 8
+.end
+
+loop:
+    most            ; eat what's ahead and move on
+    Eat
+    Forward
+    most            ; and again, around a corner
+    Eat
+    Right
+    Forward
+    most            ; then eat and divide
+    Eat
+    half
+    Divide
+    Left
+    JumpR loop
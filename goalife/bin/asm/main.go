@@ -0,0 +1,80 @@
+// Asm assembles cpu1 source files, reporting any errors, and prints a
+// listing of the resulting code with the address of each instruction and
+// the target of each jump.  With --disassemble, it instead prints the
+// genome saved in a census file as source.
+package main
+
+import "encoding/gob"
+import "flag"
+import "fmt"
+import "os"
+import "path"
+import "time"
+
+import "github.com/dnesting/alife/goalife/census"
+import "github.com/dnesting/alife/goalife/grid2d/org/cpu1"
+
+var disassemble bool
+
+func init() {
+	flag.BoolVar(&disassemble, "disassemble", false, "disassemble the genomes in census files instead")
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [flags] file.s ...\n", path.Base(os.Args[0]))
+	flag.PrintDefaults()
+}
+
+func assemble(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	code, err := cpu1.Ops.Assemble(f)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("; %s: %d bytes, hash %x\n", filename, len(code), code.Hash())
+	return cpu1.Ops.Disassemble(os.Stdout, code)
+}
+
+func disassembleCensus(filename string) error {
+	h, pop, err := census.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	if err := h.Check(census.Magic); err != nil {
+		return err
+	}
+	c, ok := pop.Key.(*cpu1.Cpu)
+	if !ok {
+		return fmt.Errorf("not a cpu1 genome: %v", pop.Key)
+	}
+	fmt.Printf("; %s: %d bytes, hash %x\n", filename, len(c.Code), c.Code.Hash())
+	return cpu1.Ops.Disassemble(os.Stdout, c.Code)
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+	gob.Register(time.Time{})
+	gob.Register(&cpu1.Cpu{})
+
+	for _, filename := range flag.Args() {
+		var err error
+		if disassemble {
+			err = disassembleCensus(filename)
+		} else {
+			err = assemble(filename)
+		}
+		if err != nil {
+			fmt.Printf("%s: %v\n", filename, err)
+			os.Exit(1)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+// Debug loads a single cpu1 genome, from a census file or from assembler
+// source, into a small sandbox world and lets the user step through its
+// execution interactively.  Type "help" at the prompt for commands.
+package main
+
+import "bufio"
+import "bytes"
+import "encoding/gob"
+import "flag"
+import "fmt"
+import "os"
+import "path"
+import "strconv"
+import "strings"
+import "time"
+
+import "github.com/dnesting/alife/goalife/census"
+import "github.com/dnesting/alife/goalife/grid2d"
+import "github.com/dnesting/alife/goalife/grid2d/food"
+import "github.com/dnesting/alife/goalife/grid2d/org"
+import "github.com/dnesting/alife/goalife/grid2d/org/cpu1"
+import "github.com/dnesting/alife/goalife/term"
+import "github.com/dnesting/alife/goalife/util/rng"
+
+var (
+	size       int
+	geometry   string
+	energy     int
+	numFood    int
+	foodEnergy int
+	seed       int64
+)
+
+func init() {
+	flag.IntVar(&size, "size", 11, "width and height of the sandbox world")
+	flag.StringVar(&geometry, "geometry", "square", "shape of the sandbox's cells: square or hex")
+	flag.IntVar(&energy, "energy", 10000, "initial energy of the organism")
+	flag.IntVar(&numFood, "food", 10, "number of cells of food placed randomly in the sandbox")
+	flag.IntVar(&foodEnergy, "food-energy", 1000, "energy of each cell of food")
+	flag.Int64Var(&seed, "seed", 1, "seed for the sandbox's randomness")
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [flags] /path/to/census-file|/path/to/source.s\n", path.Base(os.Args[0]))
+	flag.PrintDefaults()
+}
+
+const help = `Commands:
+  s [n]        step n instructions (default 1)
+  c [max]      continue until a breakpoint (at most max instructions, default 100000)
+  b [ip|op]    set a breakpoint on an address or opcode, or list breakpoints
+  d ip|op      delete a breakpoint
+  r            show registers, energy and direction
+  l [n]        list n instructions around the next one (default 10)
+  w            show the sandbox world
+  q            quit
+An empty line repeats the previous command.`
+
+// load returns the genome in filename, assembling it if it ends in .s.
+func load(filename string) (cpu1.Bytecode, error) {
+	if strings.HasSuffix(filename, ".s") {
+		ancestors, err := cpu1.LoadAncestors(filename)
+		if err != nil {
+			return nil, err
+		}
+		return ancestors[0], nil
+	}
+	h, pop, err := census.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.Check(census.Magic); err != nil {
+		return nil, err
+	}
+	c, ok := pop.Key.(*cpu1.Cpu)
+	if !ok {
+		return nil, fmt.Errorf("not a cpu1 genome: %v", pop.Key)
+	}
+	return c.Code, nil
+}
+
+// sandbox returns a new world holding an organism running code at its
+// center, surrounded by randomly-placed food.
+func sandbox(code cpu1.Bytecode) (grid2d.Grid, *cpu1.Debugger, error) {
+	geom, err := grid2d.GeometryByName(geometry)
+	if err != nil {
+		return nil, nil, err
+	}
+	var g grid2d.Grid
+	if geom == grid2d.Hex {
+		g = grid2d.NewHex(size, size, grid2d.Torus, nil)
+	} else {
+		g = grid2d.New(size, size, nil)
+	}
+	c := &cpu1.Cpu{Code: code}
+	o := org.RandomIn(geom)
+	o.Driver = c
+	o.AddEnergy(energy)
+	g.Put(size/2, size/2, o, grid2d.PutAlways)
+	for i := 0; i < numFood; i++ {
+		g.PutRandomly(food.New(foodEnergy), grid2d.PutWhenNil)
+	}
+	return g, cpu1.NewDebugger(o, c), nil
+}
+
+// list prints n instructions of code around the next one.
+func list(d *cpu1.Debugger, n int) {
+	var b bytes.Buffer
+	cpu1.Ops.Disassemble(&b, d.C.Code)
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	ip, _ := d.Next()
+	from := ip - n/2
+	if from < 0 {
+		from = 0
+	}
+	for i := from; i < from+n && i < len(lines); i++ {
+		mark := "  "
+		if i == ip {
+			mark = "=>"
+		}
+		fmt.Printf("%s %s\n", mark, lines[i])
+	}
+}
+
+// count parses an optional count argument.
+func count(args []string, def int) (int, error) {
+	if len(args) == 0 {
+		return def, nil
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid count %q", args[0])
+	}
+	return n, nil
+}
+
+// stopped reports why execution stopped.
+func stopped(d *cpu1.Debugger, err error) {
+	if err != nil {
+		fmt.Printf("organism died: %v\n", err)
+	} else if d.AtBreakpoint() {
+		fmt.Println("breakpoint")
+	}
+	fmt.Println(d)
+}
+
+// command executes a single command.  Returns false to quit.
+func command(g grid2d.Grid, d *cpu1.Debugger, f []string) bool {
+	cmd, args := f[0], f[1:]
+	switch cmd {
+	case "s", "step":
+		n, err := count(args, 1)
+		if err != nil {
+			fmt.Println(err)
+			break
+		}
+		for i := 0; i < n && err == nil; i++ {
+			err = d.Step()
+		}
+		stopped(d, err)
+	case "c", "continue":
+		max, err := count(args, 100000)
+		if err != nil {
+			fmt.Println(err)
+			break
+		}
+		_, err = d.Continue(max)
+		stopped(d, err)
+	case "b", "break":
+		if len(args) == 0 {
+			fmt.Printf("breakpoints: %s\n", strings.Join(d.Breakpoints(), " "))
+		}
+		for _, a := range args {
+			if err := d.Break(a); err != nil {
+				fmt.Println(err)
+			}
+		}
+	case "d", "delete":
+		for _, a := range args {
+			if err := d.Clear(a); err != nil {
+				fmt.Println(err)
+			}
+		}
+	case "r", "regs":
+		fmt.Println(d)
+	case "l", "list":
+		n, err := count(args, 10)
+		if err != nil {
+			fmt.Println(err)
+			break
+		}
+		list(d, n)
+	case "w", "world":
+		term.PrintWorld(os.Stdout, g)
+		fmt.Println()
+	case "q", "quit":
+		return false
+	case "h", "help", "?":
+		fmt.Println(help)
+	default:
+		fmt.Printf("unknown command %q, try help\n", cmd)
+	}
+	return true
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 1 {
+		usage()
+		os.Exit(2)
+	}
+	rng.Seed(seed)
+	gob.Register(time.Time{})
+	gob.Register(&cpu1.Cpu{})
+
+	code, err := load(flag.Arg(0))
+	if err != nil {
+		fmt.Printf("%s: %v\n", flag.Arg(0), err)
+		os.Exit(1)
+	}
+	g, d, err := sandbox(code)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(1)
+	}
+	// Don't mutate the organism's offspring; we're watching it, not evolving it.
+	cpu1.MutationRate = 0
+
+	fmt.Printf("loaded %d bytes from %s\n", len(code), flag.Arg(0))
+	fmt.Println(d)
+	in := bufio.NewScanner(os.Stdin)
+	var last []string
+	for {
+		fmt.Print("(debug) ")
+		if !in.Scan() {
+			fmt.Println()
+			return
+		}
+		f := strings.Fields(in.Text())
+		if len(f) == 0 {
+			f = last
+		}
+		if len(f) == 0 {
+			continue
+		}
+		last = f
+		if !command(g, d, f) {
+			return
+		}
+	}
+}
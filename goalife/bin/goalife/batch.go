@@ -0,0 +1,124 @@
+package main
+
+import "fmt"
+import "os"
+import "sync"
+import "time"
+
+import "github.com/dnesting/alife/goalife/census"
+import "github.com/dnesting/alife/goalife/experiment"
+import "github.com/dnesting/alife/goalife/grid2d"
+import "github.com/dnesting/alife/goalife/grid2d/autosave"
+
+// stopped describes why a run stopped.
+type stopped struct {
+	reason string
+	status int
+	rounds int64
+}
+
+// monitor samples the world as it runs, and stops the run when cfg.Stop is
+// met.
+type monitor struct {
+	g     grid2d.Grid
+	start time.Time
+	done  chan stopped
+
+	mu     sync.Mutex
+	series []experiment.Point
+	top    []experiment.Genome
+}
+
+// newMonitor returns a monitor for g if the run should be monitored.
+func newMonitor(g grid2d.Grid) *monitor {
+	if !cfg.Stop.Any() && summaryFile == "" {
+		return nil
+	}
+	return &monitor{g: g, start: time.Now(), done: make(chan stopped, 1)}
+}
+
+// sample observes the world after rounds rounds.
+func (m *monitor) sample(rounds int64) *experiment.Point {
+	p, genomes := experiment.Observe(m.g)
+	p.Round = rounds
+	p.Elapsed = time.Since(m.start).Seconds()
+	if len(genomes) > numTop {
+		genomes = genomes[:numTop]
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.series = append(m.series, p)
+	m.top = genomes
+	return &p
+}
+
+// check checks cfg.Stop after rounds rounds, first sampling the world if
+// sampled is true.  If the run should stop, the world is sampled one last
+// time if it wasn't already, the monitor's done channel is signaled and
+// check returns true.
+func (m *monitor) check(rounds int64, sampled bool) bool {
+	var p *experiment.Point
+	if sampled {
+		p = m.sample(rounds)
+	}
+	reason, status := cfg.Stop.Check(rounds, time.Since(m.start), p)
+	if reason == "" {
+		return false
+	}
+	if p == nil {
+		m.sample(rounds)
+	}
+	m.done <- stopped{reason, status, rounds}
+	return true
+}
+
+// watch checks cfg.Stop every --sample-interval, for worlds not executed by
+// a scheduler.
+func (m *monitor) watch() {
+	d, _ := time.ParseDuration(cfg.Sample.Interval)
+	t := time.NewTicker(d)
+	defer t.Stop()
+	for range t.C {
+		if m.check(0, true) {
+			return
+		}
+	}
+}
+
+// finish writes the summary of the run to --summary, if given, saves the
+// world to d, if not nil, and exits with the status of the reason it
+// stopped.
+func (m *monitor) finish(st stopped, cns census.Census, d *autosave.Dir) {
+	m.mu.Lock()
+	s := &experiment.Summary{
+		Reason:  st.reason,
+		Status:  st.status,
+		Rounds:  st.rounds,
+		Elapsed: time.Since(m.start).Seconds(),
+		Config:  cfg,
+		Census:  experiment.Counts(cns),
+		Top:     m.top,
+		Series:  m.series,
+	}
+	if len(m.series) > 0 {
+		s.Final = m.series[len(m.series)-1]
+	}
+	m.mu.Unlock()
+
+	if summaryFile != "-" {
+		fmt.Printf("stopped (%s) after %d rounds, %.1fs\n", st.reason, st.rounds, s.Elapsed)
+	}
+	if d != nil {
+		if _, err := d.Save(m.g); err != nil {
+			fmt.Printf("autosave: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if summaryFile != "" {
+		if err := s.Write(summaryFile); err != nil {
+			fmt.Printf("--summary: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	os.Exit(st.status)
+}
@@ -1,47 +1,76 @@
 // An implementation of artificial life.
 //
 // This binary instantiates a basic world and populates it with
-// random organisms whenever the number of organisms drops below a
-// certain threshold. A view of the world is rendered to the terminal
+// random organisms (or copies of hand-written ancestors) whenever the
+// number of organisms drops below a certain threshold.  New organisms are
+// driven by either the cpu1 or the cpu2 virtual machine. A view of the world is rendered to the terminal
 // as it evolves.
 package main
 
 import "encoding/gob"
 import "flag"
 import "fmt"
-import "math/rand"
 import "net/http"
 import "os"
+import "path"
 import "runtime"
+import "strconv"
+import "strings"
 import "sync"
 import "sync/atomic"
 import "time"
 import _ "net/http/pprof"
 
 import "github.com/dnesting/alife/goalife/census"
+import "github.com/dnesting/alife/goalife/experiment"
 import "github.com/dnesting/alife/goalife/grid2d"
 import "github.com/dnesting/alife/goalife/grid2d/autosave"
 import "github.com/dnesting/alife/goalife/grid2d/food"
+import "github.com/dnesting/alife/goalife/grid2d/journal"
 import "github.com/dnesting/alife/goalife/grid2d/maintain"
 import "github.com/dnesting/alife/goalife/grid2d/org"
 import "github.com/dnesting/alife/goalife/grid2d/org/cpu1"
+import "github.com/dnesting/alife/goalife/grid2d/org/cpu2"
+import "github.com/dnesting/alife/goalife/grid2d/sched"
+import "github.com/dnesting/alife/goalife/lineage"
 import "github.com/dnesting/alife/goalife/log"
+import "github.com/dnesting/alife/goalife/mutation"
+import "github.com/dnesting/alife/goalife/physics"
+import "github.com/dnesting/alife/goalife/stats"
 import "github.com/dnesting/alife/goalife/term"
 import "github.com/dnesting/alife/goalife/util/chanbuf"
+import "github.com/dnesting/alife/goalife/util/rng"
 
 var Logger = log.Null()
 
 const initialEnergy = 10000
 
+// cfg holds the parameters of the run, from --config and the flags
+// overriding it.
+var cfg = experiment.Default()
+
 var (
-	printWorld    bool
-	printRate     float64
-	pprof         bool
-	minOrgs       int
-	syncToRender  bool
-	saveFile      string
-	saveEvery     int
-	width, height int
+	configFile   string
+	printWorld   bool
+	printRate    float64
+	pprof        bool
+	syncToRender bool
+	resume       string
+	listSaves    bool
+	journalFile  string
+	physicsFile  string
+	summaryFile  string
+	numTop       int
+
+	mutationFile  string
+	statsFile     string
+	statsFormat   string
+	statsInterval time.Duration
+
+	traceFile   string
+	traceHash   string
+	traceRegion string
+	traceSample float64
 
 	traceAll      bool
 	traceCpu      bool
@@ -51,15 +80,58 @@ var (
 )
 
 func init() {
+	flag.StringVar(&configFile, "config", "", "read the experiment's parameters from this JSON file, overridden by any flags given (see experiment/experiment.go)")
 	flag.BoolVar(&printWorld, "print", true, "render the world to the terminal")
 	flag.Float64Var(&printRate, "print_hz", 10.0, "refresh rate in Hz for --print")
 	flag.BoolVar(&pprof, "pprof", false, "enable profiling")
-	flag.IntVar(&minOrgs, "min", 50, "maintain this many organisms at a minimum")
+	flag.IntVar(&cfg.Seeding.Min, "min", cfg.Seeding.Min, "maintain this many organisms at a minimum")
 	flag.BoolVar(&syncToRender, "sync", false, "sync world updates to rendering")
-	flag.StringVar(&saveFile, "save-file", "/tmp/autosave.dat", "auto-save to this filename")
-	flag.IntVar(&saveEvery, "save-every", 3, "auto-save every save-every secs")
-	flag.IntVar(&width, "width", 200, "width of world")
-	flag.IntVar(&height, "height", 50, "height of world")
+	flag.StringVar(&cfg.Autosave.Dir, "save-dir", cfg.Autosave.Dir, "auto-save snapshots to this directory, along with the experiment's parameters")
+	flag.IntVar(&cfg.Autosave.Every, "save-every", cfg.Autosave.Every, "auto-save every save-every secs")
+	flag.IntVar(&cfg.Autosave.Keep, "save-keep", cfg.Autosave.Keep, "always keep this many of the most recent snapshots")
+	flag.StringVar(&cfg.Autosave.Thin, "save-thin", cfg.Autosave.Thin, "beyond --save-keep, keep one snapshot per period for snapshots of each age, as after:period,...")
+	flag.StringVar(&resume, "resume", "latest", "resume from this snapshot: latest, none, a time (RFC 3339) or a snapshot file")
+	flag.BoolVar(&listSaves, "list-saves", false, "list the snapshots in --save-dir and exit")
+	flag.StringVar(&journalFile, "journal", "", "record every change to the world to this file, replacing it (see bin/replay)")
+	flag.StringVar(&cfg.Census.Dir, "census-dir", cfg.Census.Dir, "record populations to this directory")
+	flag.IntVar(&cfg.Census.Threshold, "census-threshold", cfg.Census.Threshold, "record populations once they grow beyond this size")
+	flag.StringVar(&cfg.Census.Lineage, "lineage-file", cfg.Census.Lineage, "record the parent of each genome born by division to this file, appending to it (see bin/lineage)")
+	flag.IntVar(&cfg.World.Width, "width", cfg.World.Width, "width of world")
+	flag.IntVar(&cfg.World.Height, "height", cfg.World.Height, "height of world")
+	flag.StringVar(&cfg.World.Topology, "topology", cfg.World.Topology, "edges of the world: torus, walled or reflective")
+	flag.StringVar(&cfg.World.Geometry, "geometry", cfg.World.Geometry, "shape of the world's cells: square or hex (hex renders 2*width+height+2 columns wide)")
+	flag.Int64Var(&cfg.Seed, "seed", cfg.Seed, "seed for the world's randomness (0 picks one based on the time)")
+	flag.StringVar(&cfg.Sched, "sched", cfg.Sched, "how organisms are executed: goroutine, round-robin or shuffled")
+	flag.StringVar(&cfg.Seeding.Cpu, "cpu", cfg.Seeding.Cpu, "virtual machine driving new organisms: cpu1 or cpu2")
+	flag.Float64Var(&cfg.Mutation.Rate, "mutation-rate", cfg.Mutation.Rate, "probability that an offspring's genome is mutated")
+	flag.Var(weightsFlag{&cfg.Mutation.Operators}, "mutation-ops", "relative weights of each kind of mutation, as kind=weight,... (kinds: "+kindNames()+"; default point, duplicate and delete equally)")
+	flag.Float64Var(&cfg.Mutation.Segment, "mutation-segment", cfg.Mutation.Segment, "standard deviation of the lengths of segments mutated")
+	flag.IntVar(&cfg.Mutation.Crossover, "crossover", cfg.Mutation.Crossover, "crossover points (1 or 2) used when cpu1 organisms Mate with a neighbor; 0 leaves Mate out of cpu1's instruction set")
+	flag.Float64Var(&cfg.Mutation.CopyError, "copy-error", cfg.Mutation.CopyError, "probability that a copy mutation miscopies each instruction")
+	flag.StringVar(&physicsFile, "physics", "", "read energy costs and constants from this JSON file (default: from --config, as saved, or built in)")
+	flag.BoolVar(&cfg.Seeding.Once, "seed-once", cfg.Seeding.Once, "seed --min organisms at the start only, instead of maintaining --min")
+	flag.Var(listFlag{&cfg.Seeding.Ancestors}, "ancestors", "seed new organisms from these comma-separated cpu1 source files (see bin/asm)")
+	flag.Float64Var(&cfg.Seeding.Random, "random", cfg.Seeding.Random, "with --ancestors, the fraction of new organisms seeded with random code instead")
+
+	flag.Int64Var(&cfg.Stop.Rounds, "stop-rounds", cfg.Stop.Rounds, "stop after this many rounds (requires --sched)")
+	flag.StringVar(&cfg.Stop.After, "stop-after", cfg.Stop.After, "stop after running this long, such as 10m")
+	flag.BoolVar(&cfg.Stop.Extinct, "stop-extinct", cfg.Stop.Extinct, "stop when no organisms remain (see --seed-once)")
+	flag.IntVar(&cfg.Stop.Species, "stop-species", cfg.Stop.Species, "stop when any species has more than this many organisms")
+	flag.Float64Var(&cfg.Stop.Diversity, "stop-diversity", cfg.Stop.Diversity, "stop when the Shannon diversity of genomes falls below this")
+	flag.Int64Var(&cfg.Sample.Rounds, "sample-rounds", cfg.Sample.Rounds, "with --sched, sample the world and check --stop-* conditions every this many rounds")
+	flag.StringVar(&cfg.Sample.Interval, "sample-interval", cfg.Sample.Interval, "without --sched, sample the world and check --stop-* conditions this often")
+	flag.StringVar(&summaryFile, "summary", "", "when stopped, write a summary of the run as JSON to this file (- for standard output); the exit status gives the reason for stopping")
+	flag.IntVar(&numTop, "top", 10, "number of the most numerous genomes to include in --summary")
+
+	flag.StringVar(&mutationFile, "mutation-log", "", "record each mutation, and whether the mutant went on to divide, to this file as JSON, replacing it")
+	flag.StringVar(&statsFile, "stats-file", "", "record statistics about the world over time to this file, replacing it")
+	flag.StringVar(&statsFormat, "stats-format", "csv", "format of --stats-file: csv, or json for one object per line")
+	flag.DurationVar(&statsInterval, "stats-interval", time.Second, "with --stats-file, how often to record statistics")
+
+	flag.StringVar(&traceFile, "trace-file", "", "record each step of selected organisms to this file as JSON, replacing it")
+	flag.StringVar(&traceHash, "trace-hash", "", "with --trace-file, trace organisms with these comma-separated genome hashes (in hex)")
+	flag.StringVar(&traceRegion, "trace-region", "", "with --trace-file, trace organisms starting within x0,y0,x1,y1")
+	flag.Float64Var(&traceSample, "trace-sample", 0, "with --trace-file, trace this fraction of organisms at random")
 
 	flag.BoolVar(&traceAll, "trace-all", false, "enable all tracing")
 	flag.BoolVar(&traceCpu, "trace-cpu", false, "enable cpu tracing")
@@ -68,18 +140,157 @@ func init() {
 	flag.BoolVar(&traceOrg, "trace-org", false, "enable org tracing")
 }
 
-func startOrg(g grid2d.Grid) {
-	c := cpu1.Random()
-	o := org.Random()
-	o.Driver = c
-	o.AddEnergy(initialEnergy)
-	for {
-		// PutRandomly might fail if there's no room, so just keep trying.
-		if _, loc := g.PutRandomly(o, org.PutWhenFood); loc != nil {
-			go c.Run(o)
-			break
+// listFlag is a flag.Value holding a comma-separated list.
+type listFlag struct {
+	p *[]string
+}
+
+func (f listFlag) String() string {
+	if f.p == nil {
+		return ""
+	}
+	return strings.Join(*f.p, ",")
+}
+
+func (f listFlag) Set(s string) error {
+	*f.p = nil
+	if s != "" {
+		*f.p = strings.Split(s, ",")
+	}
+	return nil
+}
+
+// weightsFlag is a flag.Value holding the weights of kinds of mutation.
+type weightsFlag struct {
+	p *map[mutation.Kind]float64
+}
+
+func (f weightsFlag) String() string {
+	if f.p == nil {
+		return ""
+	}
+	var ws []string
+	for _, k := range mutation.Kinds() {
+		if w, ok := (*f.p)[k]; ok {
+			ws = append(ws, fmt.Sprintf("%s=%g", k, w))
+		}
+	}
+	return strings.Join(ws, ",")
+}
+
+func (f weightsFlag) Set(s string) error {
+	w, err := mutation.ParseWeights(s)
+	if err != nil {
+		return err
+	}
+	*f.p = w
+	return nil
+}
+
+func kindNames() string {
+	var names []string
+	for _, k := range mutation.Kinds() {
+		names = append(names, string(k))
+	}
+	return strings.Join(names, ", ")
+}
+
+// parseFlags parses the command line into cfg.  If --config is given, cfg is
+// read from it, and then the command line is parsed again so that any flags
+// given override it.
+func parseFlags() {
+	flag.Parse()
+	if configFile == "" {
+		return
+	}
+	if err := experiment.Load(configFile, cfg); err != nil {
+		fmt.Printf("--config: %v\n", err)
+		os.Exit(1)
+	}
+	flag.Parse()
+}
+
+// applyConfig puts cfg into effect, with the physics.Physics in --physics
+// if given.
+func applyConfig() {
+	if physicsFile != "" {
+		p, err := physics.Load(physicsFile)
+		if err != nil {
+			fmt.Printf("--physics: %v\n", err)
+			os.Exit(1)
+		}
+		cfg.Physics = p
+	}
+	if err := cfg.Apply(); err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(1)
+	}
+}
+
+// writeConfig records cfg, including the physics in effect, alongside the
+// snapshots in d, so that the run can be reproduced.
+func writeConfig(d *autosave.Dir) {
+	c := *cfg
+	c.Physics = physics.Current()
+	err := os.MkdirAll(d.Path, 0755)
+	if err == nil {
+		err = c.Write(path.Join(d.Path, "experiment.json"))
+	}
+	if err != nil {
+		fmt.Printf("error writing experiment: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// newSeeder returns the Seeder for new organisms described by --ancestors
+// and --random.
+func newSeeder() *cpu1.Seeder {
+	s := &cpu1.Seeder{Random: cfg.Seeding.Random}
+	if len(cfg.Seeding.Ancestors) > 0 {
+		var err error
+		if s.Ancestors, err = cpu1.LoadAncestors(cfg.Seeding.Ancestors...); err != nil {
+			fmt.Printf("--ancestors: %v\n", err)
+			os.Exit(1)
 		}
 	}
+	return s
+}
+
+// starter begins executing organisms driven by either kind of Cpu.
+type starter struct {
+	cpu1 cpu1.StartFunc
+	cpu2 cpu2.StartFunc
+}
+
+// startAll begins executing every organism in g.
+func (st starter) startAll(g grid2d.Grid) {
+	cpu1.StartAll(g, st.cpu1)
+	cpu2.StartAll(g, st.cpu2)
+}
+
+// startOrg places a new organism in the world, driven by the Cpu selected by
+// --cpu and seeded by seeder if cpu1, and uses st to begin executing it.
+// Returns false if there was no room for it.
+func startOrg(g grid2d.Grid, seeder *cpu1.Seeder, st starter) bool {
+	var driver interface{}
+	var start func(o *org.Organism)
+	if cfg.Seeding.Cpu == "cpu2" {
+		c := cpu2.Random()
+		c.Start = st.cpu2
+		driver, start = c, func(o *org.Organism) { st.cpu2(o, c) }
+	} else {
+		c := seeder.Cpu()
+		c.Start = st.cpu1
+		driver, start = c, func(o *org.Organism) { st.cpu1(o, c) }
+	}
+	o := org.RandomIn(g.Geometry())
+	o.Driver = driver
+	o.AddEnergy(initialEnergy)
+	if _, loc := g.PutRandomly(o, org.PutWhenFood); loc != nil {
+		start(o)
+		return true
+	}
+	return false
 }
 
 func isOrg(o interface{}) bool {
@@ -89,9 +300,17 @@ func isOrg(o interface{}) bool {
 
 func orgHash(o interface{}) *census.Key {
 	if o, ok := o.(*org.Organism); ok {
-		if c, ok := o.Driver.(*cpu1.Cpu); ok {
-			i := census.Key(c)
-			return &i
+		if k, ok := o.Driver.(census.Key); ok {
+			return &k
+		}
+	}
+	return nil
+}
+
+func orgDescendant(o interface{}) lineage.Descendant {
+	if o, ok := o.(*org.Organism); ok {
+		if d, ok := o.Driver.(lineage.Descendant); ok {
+			return d
 		}
 	}
 	return nil
@@ -101,6 +320,7 @@ func setupTracing() {
 	l := log.Real()
 	if traceAll || traceCpu {
 		cpu1.Logger = l
+		cpu2.Logger = l
 	}
 	if traceAll || traceGrid {
 		grid2d.Logger = l
@@ -113,6 +333,57 @@ func setupTracing() {
 	}
 }
 
+// newTracer returns a Tracer recording to --trace-file the organisms
+// selected by --trace-hash, --trace-region and --trace-sample, or nil if
+// not tracing.
+func newTracer() *cpu1.Tracer {
+	if traceFile == "" {
+		return nil
+	}
+	var sels []cpu1.TraceSelector
+	if traceHash != "" {
+		var hashes []uint64
+		for _, h := range strings.Split(traceHash, ",") {
+			v, err := strconv.ParseUint(h, 16, 64)
+			if err != nil {
+				fmt.Printf("--trace-hash: %v\n", err)
+				os.Exit(1)
+			}
+			hashes = append(hashes, v)
+		}
+		sels = append(sels, cpu1.TraceHashes(hashes...))
+	}
+	if traceRegion != "" {
+		var x0, y0, x1, y1 int
+		if _, err := fmt.Sscanf(traceRegion, "%d,%d,%d,%d", &x0, &y0, &x1, &y1); err != nil {
+			fmt.Printf("--trace-region: expected x0,y0,x1,y1: %v\n", err)
+			os.Exit(1)
+		}
+		sels = append(sels, cpu1.TraceRegion(x0, y0, x1, y1))
+	}
+	if traceSample > 0 {
+		sels = append(sels, cpu1.TraceSample(traceSample, cfg.Seed))
+	}
+	if len(sels) == 0 {
+		fmt.Println("--trace-file requires --trace-hash, --trace-region or --trace-sample")
+		os.Exit(1)
+	}
+	f, err := os.Create(traceFile)
+	if err != nil {
+		fmt.Printf("--trace-file: %v\n", err)
+		os.Exit(1)
+	}
+	return cpu1.NewTracer(f, cpu1.TraceAny(sels...))
+}
+
+// traced wraps start so that organisms are traced by t, if not nil.
+func traced(t *cpu1.Tracer, start cpu1.StartFunc) cpu1.StartFunc {
+	if t == nil {
+		return start
+	}
+	return t.Start(start)
+}
+
 func setupPprof() {
 	runtime.SetBlockProfileRate(1000)
 	go func() {
@@ -123,13 +394,16 @@ func setupPprof() {
 func registerGob() {
 	gob.Register(time.Time{})
 	gob.Register(&cpu1.Cpu{})
+	gob.Register(&cpu2.Cpu{})
 	gob.Register(&food.Food{})
 	gob.Register(&org.Organism{})
 }
 
 func startCensus(g grid2d.Grid) *census.DirCensus {
-	// Create a new Census that writes to /tmp/census when a population grows to 40.
-	cns, err := census.NewDirCensus("/tmp/census", func(p census.Population) bool { return p.Count > 40 })
+	// Create a new Census that writes to the census directory when a population grows
+	// beyond the threshold.
+	threshold := cfg.Census.Threshold
+	cns, err := census.NewDirCensus(cfg.Census.Dir, func(p census.Population) bool { return p.Count > threshold })
 	if err != nil {
 		fmt.Printf("Error creating census: %v\n", err)
 		os.Exit(1)
@@ -138,8 +412,10 @@ func startCensus(g grid2d.Grid) *census.DirCensus {
 	// Use human times.
 	timeNow := func(interface{}) interface{} { return time.Now() }
 
+	// Queue updates so that writing to the census never delays the world, and only
+	// receive those that can change the census.
 	ch := make(chan []grid2d.Update, 0)
-	g.Subscribe(ch)
+	g.Subscribe(ch, grid2d.Unbounded(), grid2d.Kinds(grid2d.Added|grid2d.Removed|grid2d.Replaced), grid2d.Occupants(isOrg))
 
 	// Populate the Census with what's already in the world (perhaps restored from an autosave).
 	// Assumes nothing in the world is changing yet.
@@ -151,19 +427,83 @@ func startCensus(g grid2d.Grid) *census.DirCensus {
 	return cns
 }
 
-func startAndMaintainOrgs(g grid2d.Grid) {
+func startAndMaintainOrgs(g grid2d.Grid, seeder *cpu1.Seeder, tracer *cpu1.Tracer, m *monitor) {
+	st := starter{traced(tracer, cpu1.Go), cpu2.Go}
+
 	// Obtain an initial count before we start anything executing.
 	mCount := maintain.Count(g, isOrg)
+	if m != nil {
+		go m.watch()
+	}
+
+	if cfg.Seeding.Once {
+		st.startAll(g)
+		// If there's no room, give up.
+		for n := mCount; n < cfg.Seeding.Min && startOrg(g, seeder, st); n++ {
+		}
+		return
+	}
 
 	ch := make(chan []grid2d.Update, 0)
-	g.Subscribe(ch)
+	g.Subscribe(ch, grid2d.Unbounded(), grid2d.Kinds(grid2d.Added|grid2d.Removed|grid2d.Replaced), grid2d.Occupants(isOrg))
 
 	// Start all organisms currently existing in the Grid.  We do this *after*
 	// subscribing ch so that we don't end up with a wrong count if any organisms
 	// divide or die.
-	cpu1.StartAll(g)
+	st.startAll(g)
+
+	go maintain.Maintain(ch, isOrg, func() {
+		// PutRandomly might fail if there's no room, so keep trying, backing
+		// off (up to a second) until organisms die and make some.
+		for d := time.Millisecond; !startOrg(g, seeder, st); {
+			time.Sleep(d)
+			if d < time.Second {
+				d *= 2
+			}
+		}
+	}, cfg.Seeding.Min, mCount)
+}
+
+func newScheduler() *sched.Scheduler {
+	var order sched.Order
+	switch cfg.Sched {
+	case "goroutine":
+		return nil
+	case "round-robin":
+		order = sched.RoundRobin
+	case "shuffled":
+		order = sched.Shuffled
+	default:
+		fmt.Printf("unknown --sched mode %q\n", cfg.Sched)
+		os.Exit(1)
+	}
+	return sched.New(order, cfg.Seed)
+}
+
+func startAndRunOrgs(g grid2d.Grid, s *sched.Scheduler, seeder *cpu1.Seeder, tracer *cpu1.Tracer, m *monitor, exit <-chan bool) {
+	// Every organism is executed by s in a single goroutine, so to keep the run
+	// reproducible, we top up the population between rounds instead of using
+	// maintain.Maintain.
+	st := starter{
+		traced(tracer, func(o *org.Organism, c *cpu1.Cpu) { s.Add(o, c) }),
+		func(o *org.Organism, c *cpu2.Cpu) { s.Add(o, c) },
+	}
+	st.startAll(g)
 
-	go maintain.Maintain(ch, isOrg, func() { startOrg(g) }, minOrgs, mCount)
+	go s.Run(exit, func() {
+		if !cfg.Seeding.Once || s.Rounds() == 0 {
+			// If there's no room, give up until the next round.
+			for s.Len() < cfg.Seeding.Min && startOrg(g, seeder, st) {
+			}
+		}
+		if m != nil {
+			r := s.Rounds()
+			if m.check(r, r%cfg.Sample.Rounds == 0) {
+				// Leave the world as it was when it stopped.
+				select {}
+			}
+		}
+	})
 }
 
 func startUpdateTracker(g grid2d.Grid, numUpdates *int64) {
@@ -173,12 +513,172 @@ func startUpdateTracker(g grid2d.Grid, numUpdates *int64) {
 			atomic.AddInt64(numUpdates, int64(len(updates)))
 		}
 	}()
-	g.Subscribe(ch)
+	g.Subscribe(ch, grid2d.Unbounded())
+}
+
+func startJournal(g grid2d.Grid) {
+	f, err := os.Create(journalFile)
+	if err != nil {
+		fmt.Printf("journal: %v\n", err)
+		os.Exit(1)
+	}
+	ch := make(chan []grid2d.Update, 0)
+	g.Subscribe(ch, grid2d.Unbounded())
+	go func() {
+		if err := journal.Record(f, ch); err != nil {
+			fmt.Printf("journal: %v\n", err)
+		}
+		f.Close()
+	}()
+}
+
+func startLineage(g grid2d.Grid) {
+	t, err := lineage.Open(cfg.Census.Lineage)
+	if err != nil {
+		fmt.Printf("lineage: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Only births can add to the lineage.
+	ch := make(chan []grid2d.Update, 0)
+	g.Subscribe(ch, grid2d.Unbounded(), grid2d.Kinds(grid2d.Added|grid2d.Replaced), grid2d.Occupants(isOrg))
+
+	// Record anything already in the world (perhaps restored from an autosave)
+	// descended from something else.  Assumes nothing in the world is changing yet.
+	if err := grid2d.ScanForLineage(t, g, orgDescendant); err != nil {
+		fmt.Printf("lineage: %v\n", err)
+		os.Exit(1)
+	}
+	go func() {
+		if err := grid2d.WatchForLineage(t, ch, orgDescendant); err != nil {
+			fmt.Printf("lineage: %v\n", err)
+		}
+		t.Close()
+	}()
+}
+
+func startMutationLog(g grid2d.Grid) {
+	f, err := os.Create(mutationFile)
+	if err != nil {
+		fmt.Printf("mutation log: %v\n", err)
+		os.Exit(1)
+	}
+	mutation.Events = mutation.NewLog(f)
+
+	// A mutant that dies before dividing never will.
+	ch := make(chan []grid2d.Update, 0)
+	g.Subscribe(ch, grid2d.Unbounded(), grid2d.Kinds(grid2d.Removed|grid2d.Replaced), grid2d.Occupants(isOrg))
+	go func() {
+		var reported bool
+		for updates := range ch {
+			for _, u := range updates {
+				if o, ok := u.Old.V.(*org.Organism); ok {
+					if m, ok := o.Driver.(mutation.Mutant); ok {
+						m.MutationEvent().Died(o.Cause())
+					}
+				}
+			}
+			if err := mutation.Events.Err(); err != nil && !reported {
+				fmt.Printf("mutation log: %v\n", err)
+				reported = true
+			}
+		}
+		f.Close()
+	}()
+}
+
+func startStats(g grid2d.Grid, exit <-chan bool) {
+	if statsInterval <= 0 {
+		fmt.Printf("--stats-interval: must be positive\n")
+		os.Exit(2)
+	}
+	f, err := os.Create(statsFile)
+	if err != nil {
+		fmt.Printf("stats: %v\n", err)
+		os.Exit(1)
+	}
+	w, err := stats.NewWriter(f, statsFormat)
+	if err != nil {
+		fmt.Printf("--stats-format: %v\n", err)
+		os.Exit(2)
+	}
+	c := stats.New(g)
+	c.Subscribe()
+	go func() {
+		if err := c.Run(w, statsInterval, exit); err != nil {
+			fmt.Printf("stats: %v\n", err)
+		}
+		f.Close()
+	}()
+}
+
+func newSaveDir() *autosave.Dir {
+	thin, err := autosave.ParseThin(cfg.Autosave.Thin)
+	if err != nil {
+		fmt.Printf("--save-thin: %v\n", err)
+		os.Exit(1)
+	}
+	return &autosave.Dir{
+		Path:      cfg.Autosave.Dir,
+		Retention: autosave.Retention{Keep: cfg.Autosave.Keep, Thin: thin},
+	}
+}
+
+func listSnapshots(d *autosave.Dir) {
+	snaps, err := d.List()
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(1)
+	}
+	for _, s := range snaps {
+		fmt.Println(s)
+	}
+}
+
+// restoreSnapshot restores g from the snapshot in d selected by --resume, if any.
+func restoreSnapshot(d *autosave.Dir, g grid2d.Grid) {
+	if resume == "none" {
+		return
+	}
+	var filename string
+	if s, err := d.Select(resume); err == nil {
+		filename = s.Path
+	} else if err == autosave.ErrNoSnapshot && resume == "latest" {
+		return
+	} else if err == autosave.ErrNoSnapshot {
+		// Perhaps a file outside of the directory.
+		filename = resume
+	} else {
+		fmt.Printf("error selecting snapshot: %v\n", err)
+		os.Exit(1)
+	}
+	if err := autosave.Restore(filename, g); err != nil {
+		fmt.Printf("error restoring from %s: %v\n", filename, err)
+		os.Exit(1)
+	}
+	fmt.Printf("resuming from %s\n", filename)
+
+	// Unless told otherwise, carry on with the physics the world was saved with.
+	if cfg.Physics == nil {
+		h, err := autosave.ReadHeader(filename)
+		if err != nil {
+			fmt.Printf("error reading %s: %v\n", filename, err)
+			os.Exit(1)
+		}
+		p, err := autosave.Physics(h)
+		if err == nil && p != nil {
+			err = physics.Set(p)
+		}
+		if err != nil {
+			fmt.Printf("error restoring physics from %s: %v\n", filename, err)
+			os.Exit(1)
+		}
+	}
 }
 
-func startAutosave(g grid2d.Grid, exit <-chan bool) {
+func startAutosave(d *autosave.Dir, g grid2d.Grid, exit <-chan bool) {
 	go func() {
-		err := autosave.Loop(saveFile, g, time.Duration(saveEvery)*time.Second, exit)
+		err := d.Loop(g, time.Duration(cfg.Autosave.Every)*time.Second, exit)
 		if err != nil {
 			fmt.Printf("autosave: %v\n", err)
 			os.Exit(1)
@@ -202,7 +702,7 @@ func printLoop(ch <-chan []grid2d.Update, g grid2d.Grid, cns *census.DirCensus,
 		}
 
 		// Write some summary stats after the rendering.
-		fmt.Printf("%d updates\n", atomic.LoadInt64(numUpdates))
+		fmt.Printf("%d updates (seed %d)\n", atomic.LoadInt64(numUpdates), cfg.Seed)
 		fmt.Printf("%d/%d orgs (%d/%d species, %d recorded)\n", cns.Count(), cns.CountAllTime(), cns.Distinct(), cns.DistinctAllTime(), cns.NumRecorded())
 		if loc := g.Get(0, 0); loc != nil {
 			fmt.Printf("random: %v\n", loc.Value())
@@ -218,9 +718,8 @@ func printLoop(ch <-chan []grid2d.Update, g grid2d.Grid, cns *census.DirCensus,
 
 func startPrintLoop(g grid2d.Grid, cns *census.DirCensus, cond *sync.Cond, numUpdates *int64, clearScreen bool) {
 	// We want to use chanbuf.Tick to ensure renders occur at specific intervals regardless
-	// of the rate at which updates arrive.  To prevent the notification channel from backing up
-	// and causing deadlock, we buffer using a chanbuf.Trigger (since we don't care about the
-	// update messages themselves).  We could have used a time.Tick instead, but we'd need to
+	// of the rate at which updates arrive.  We don't care about the update messages themselves,
+	// so we subscribe keeping only the latest, and buffer using a chanbuf.Trigger.  We could have used a time.Tick instead, but we'd need to
 	// do something special to ensure this loop exits when the notifications stop.
 
 	// grid notifier -> updateCh -> trigger -> tick -> print world
@@ -234,7 +733,7 @@ func startPrintLoop(g grid2d.Grid, cns *census.DirCensus, cond *sync.Cond, numUp
 	freq := time.Duration(1000000.0/printRate) * time.Microsecond
 	go chanbuf.Feed(trigger, grid2d.NotifyToInterface(updateCh))
 	tickCh := chanbuf.Tick(trigger, freq, true)
-	g.Subscribe(updateCh)
+	g.Subscribe(updateCh, grid2d.DropOldest(1))
 
 	go printLoop(grid2d.NotifyFromInterface(tickCh), g, cns, cond, numUpdates, clearScreen)
 }
@@ -244,8 +743,11 @@ func isTracing() bool {
 }
 
 func main() {
-	rand.Seed(time.Now().UnixNano())
-	flag.Parse()
+	parseFlags()
+	if cfg.Seed == 0 {
+		cfg.Seed = time.Now().UnixNano()
+	}
+	rng.Seed(cfg.Seed)
 
 	setupTracing()
 	if pprof {
@@ -260,30 +762,75 @@ func main() {
 	}
 
 	registerGob()
+	applyConfig()
+	seeder := newSeeder()
+	tracer := newTracer()
+
+	topo, err := grid2d.TopologyByName(cfg.World.Topology)
+	if err != nil {
+		fmt.Printf("--topology: %v\n", err)
+		os.Exit(1)
+	}
 
 	// Set up the Grid, and restore it from autosave if able.
-	g := grid2d.New(0, 0, cond)
-	if saveFile != "" {
-		if err := autosave.Restore(saveFile, g); err != nil && !os.IsNotExist(err) {
-			fmt.Printf("error restoring from %s: %v\n", saveFile, err)
-			os.Exit(1)
+	var g grid2d.Grid
+	switch cfg.World.Geometry {
+	case "square":
+		g = grid2d.NewWithTopology(0, 0, topo, cond)
+	case "hex":
+		g = grid2d.NewHex(0, 0, topo, cond)
+	default:
+		fmt.Printf("unknown --geometry %q\n", cfg.World.Geometry)
+		os.Exit(1)
+	}
+	var saves *autosave.Dir
+	if cfg.Autosave.Dir != "" {
+		saves = newSaveDir()
+		if listSaves {
+			listSnapshots(saves)
+			return
 		}
+		restoreSnapshot(saves, g)
+		writeConfig(saves)
+	}
+
+	// Begin journaling before we make any changes, so that the journal continues
+	// from the autosave we just restored.
+	if journalFile != "" {
+		startJournal(g)
 	}
 
 	// Force the world to conform to --width and --height.
-	g.Resize(width, height, nil)
+	g.Resize(cfg.World.Width, cfg.World.Height, nil)
 
 	// Record the contents of the grid (which may not be empty if restored from autosave)
 	// and start monitoring it for changes.
 	cns := startCensus(g)
+	if cfg.Census.Lineage != "" {
+		startLineage(g)
+	}
+
+	// Begin collecting statistics before any organisms start, so that everything
+	// they do is counted.
+	if statsFile != "" {
+		startStats(g, exit)
+	}
+	if mutationFile != "" {
+		startMutationLog(g)
+	}
 
 	// Start any organisms that exist in the world (e.g., from autosave) and begin tracking
 	// the number of organisms and maintaining a minimum number.
-	startAndMaintainOrgs(g)
+	m := newMonitor(g)
+	if s := newScheduler(); s != nil {
+		startAndRunOrgs(g, s, seeder, tracer, m, exit)
+	} else {
+		startAndMaintainOrgs(g, seeder, tracer, m)
+	}
 
-	if saveFile != "" && saveEvery != 0 {
+	if saves != nil && cfg.Autosave.Every != 0 {
 		// Begin auto-saving the world periodically.
-		startAutosave(g, exit)
+		startAutosave(saves, g, exit)
 	}
 
 	// Track the number of updates observed in the world for display during rendering.
@@ -295,7 +842,11 @@ func main() {
 		startPrintLoop(g, cns, cond, &numUpdates, !isTracing())
 	}
 
-	// Block until exit, which presently is never.
+	// Block until the run stops, if it is monitored, or else until exit, which
+	// presently is never.
+	if m != nil {
+		m.finish(<-m.done, cns, saves)
+	}
 	<-exit
 
 	// For completeness, stop all of the goroutines waiting on world events.
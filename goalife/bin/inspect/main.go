@@ -8,36 +8,40 @@ import "time"
 
 import "github.com/dnesting/alife/goalife/census"
 import "github.com/dnesting/alife/goalife/grid2d/org/cpu1"
+import "github.com/dnesting/alife/goalife/grid2d/org/cpu2"
 
 func main() {
 	if len(os.Args) < 2 {
 		fmt.Printf("Usage: %s /path/to/census-file\n", path.Base(os.Args[0]))
 	}
-	f, err := os.Open(os.Args[1])
-	if err != nil {
-		fmt.Printf("%s: %v\n", os.Args[1], err)
-		return
-	}
-	fmt.Printf("reading from %#v\n", *f)
-	dec := gob.NewDecoder(f)
+	fmt.Printf("reading from %s\n", os.Args[1])
 	gob.Register(time.Time{})
 	gob.Register(&cpu1.Cpu{})
+	gob.Register(&cpu2.Cpu{})
 
-	var pop census.Population
-	if err := dec.Decode(&pop); err != nil {
+	h, pop, err := census.ReadFile(os.Args[1])
+	if err != nil {
 		fmt.Printf("error: %v\n", err)
 		return
 	}
+	if err := h.Check(census.Magic); err != nil {
+		fmt.Printf("%v (see bin/migrate)\n", err)
+		return
+	}
 	fmt.Printf("%+v\n", pop)
 	fmt.Printf("%#v\n", pop)
 
-	if c, ok := pop.Key.(*cpu1.Cpu); ok {
-		slist, err := cpu1.Ops.Decompile(c.Code)
-		if err != nil {
-			fmt.Printf("error decompiling: %v\n", err)
-		}
-		for _, s := range slist {
-			fmt.Println(s)
-		}
+	var slist []string
+	switch c := pop.Key.(type) {
+	case *cpu1.Cpu:
+		slist, err = cpu1.Ops.Decompile(c.Code)
+	case *cpu2.Cpu:
+		slist, err = cpu2.Ops.Decompile(c.Code)
+	}
+	if err != nil {
+		fmt.Printf("error decompiling: %v\n", err)
+	}
+	for _, s := range slist {
+		fmt.Println(s)
 	}
 }
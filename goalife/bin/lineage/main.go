@@ -0,0 +1,105 @@
+// Lineage reads the lineage of genomes recorded by bin/goalife's
+// --lineage-file.  By default it prints the number of genomes recorded and
+// the seeded genomes they descend from.  With --ancestry, it prints the
+// ancestry of a genome, and with --newick, it writes the phylogeny in Newick
+// format for use with standard phylogenetics tools.
+package main
+
+import "flag"
+import "fmt"
+import "io/ioutil"
+import "os"
+import "path"
+
+import "github.com/dnesting/alife/goalife/lineage"
+
+var (
+	ancestry  string
+	newick    bool
+	censusDir string
+)
+
+func init() {
+	flag.StringVar(&ancestry, "ancestry", "", "print the ancestry of the genome with this hash (in hex)")
+	flag.BoolVar(&newick, "newick", false, "write the phylogeny in Newick format")
+	flag.StringVar(&censusDir, "census-dir", "", "with --newick, write only the genomes recorded in this census directory and their ancestors")
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [flags] /path/to/lineage-file\n", path.Base(os.Args[0]))
+	flag.PrintDefaults()
+}
+
+// recorded returns the set of genomes recorded in the census directory dir.
+func recorded(dir string) (map[lineage.Hash]bool, error) {
+	ls, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	hashes := make(map[lineage.Hash]bool)
+	for _, fi := range ls {
+		var h lineage.Hash
+		if err := h.UnmarshalText([]byte(fi.Name())); err == nil {
+			hashes[h] = true
+		}
+	}
+	return hashes, nil
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 1 {
+		usage()
+		os.Exit(2)
+	}
+	t, err := lineage.Load(flag.Arg(0))
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(1)
+	}
+
+	switch {
+	case ancestry != "":
+		var h lineage.Hash
+		if err := h.UnmarshalText([]byte(ancestry)); err != nil {
+			fmt.Printf("--ancestry: %v\n", err)
+			os.Exit(2)
+		}
+		rs := t.Ancestry(h)
+		if len(rs) == 0 {
+			fmt.Printf("%v was not born by division\n", h)
+			os.Exit(1)
+		}
+		for _, r := range rs {
+			fmt.Printf("%v  born %s from %v by %s", r.Hash, r.Born.Format("2006-01-02 15:04:05.000"), r.Parent, r.Mutation)
+			if r.Mate != 0 {
+				fmt.Printf(" with %v", r.Mate)
+			}
+			fmt.Println()
+		}
+		fmt.Printf("%v  seeded\n", rs[len(rs)-1].Parent)
+
+	case newick:
+		var keep func(lineage.Hash) bool
+		if censusDir != "" {
+			hashes, err := recorded(censusDir)
+			if err != nil {
+				fmt.Printf("--census-dir: %v\n", err)
+				os.Exit(1)
+			}
+			keep = func(h lineage.Hash) bool { return hashes[h] }
+		}
+		if err := t.Newick(os.Stdout, keep); err != nil {
+			fmt.Printf("%v\n", err)
+			os.Exit(1)
+		}
+
+	default:
+		roots := t.Roots()
+		fmt.Printf("%d genomes born by division, descended from %d seeded genomes\n", t.Len(), len(roots))
+		for _, r := range roots {
+			fmt.Printf("%v: %d children\n", r, len(t.Children(r)))
+		}
+	}
+}
@@ -0,0 +1,156 @@
+// Migrate rewrites the genomes saved in a census directory and in autosave
+// snapshots to match the current cpu1 opcode table, matching opcodes by
+// name.  Opcodes that no longer exist are replaced with no-ops and
+// reported.
+package main
+
+import "bufio"
+import "encoding/gob"
+import "flag"
+import "fmt"
+import "os"
+import "path"
+import "sort"
+import "strings"
+import "time"
+
+import "github.com/dnesting/alife/goalife/census"
+import "github.com/dnesting/alife/goalife/grid2d/autosave"
+import "github.com/dnesting/alife/goalife/grid2d/food"
+import "github.com/dnesting/alife/goalife/grid2d/org"
+import "github.com/dnesting/alife/goalife/grid2d/org/cpu1"
+import "github.com/dnesting/alife/goalife/grid2d/org/cpu2"
+import "github.com/dnesting/alife/goalife/util/schema"
+
+var (
+	censusDir string
+	fromFile  string
+)
+
+func init() {
+	flag.StringVar(&censusDir, "census-dir", "", "migrate the populations in this census directory")
+	flag.StringVar(&fromFile, "from", "", "file listing the old opcode names, one per line (default: as saved)")
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [flags] [/path/to/autosave ...]\n", path.Base(os.Args[0]))
+	flag.PrintDefaults()
+}
+
+// from holds the old opcode names read from --from, if given.
+var from []string
+
+// readNames reads a list of opcode names, one per line, from filename.
+func readNames(filename string) ([]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var names []string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if name := strings.TrimSpace(s.Text()); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names, s.Err()
+}
+
+// oldNames returns the opcode names that files with header h were saved
+// with.
+func oldNames(h schema.Header) []string {
+	if from != nil {
+		return from
+	}
+	return h.Tables[cpu1.TableName]
+}
+
+// report prints the number of each missing opcode replaced in what.
+func report(what string, missing map[string]int) {
+	var names []string
+	for name := range missing {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("%s: opcode %s no longer exists, replaced %d times\n", what, name, missing[name])
+	}
+}
+
+func migrateCensus(dir string) error {
+	cns := &census.DirCensus{Dir: dir}
+	missing := make(map[string]int)
+	n, err := cns.Migrate(func(h schema.Header, p *census.Population) error {
+		if c, ok := p.Key.(*cpu1.Cpu); ok {
+			var m []string
+			c.Code, m = cpu1.Remap(c.Code, oldNames(h), cpu1.Ops)
+			for _, name := range m {
+				missing[name]++
+			}
+		}
+		return nil
+	})
+	report(dir, missing)
+	fmt.Printf("%s: migrated %d populations\n", dir, n)
+	return err
+}
+
+func migrateSnapshot(filename string) error {
+	h, err := autosave.ReadHeader(filename)
+	if err != nil {
+		return err
+	}
+	g, err := autosave.NewGrid(h)
+	if err != nil {
+		return err
+	}
+	if h, err = autosave.Load(filename, g); err != nil {
+		return err
+	}
+	if h.Version == schema.Version && h.Check(autosave.Magic) == nil {
+		fmt.Printf("%s: already current\n", filename)
+		return nil
+	}
+	report(filename, cpu1.Migrate(g, oldNames(h)))
+	if err := autosave.Save(filename, g); err != nil {
+		return err
+	}
+	fmt.Printf("%s: migrated\n", filename)
+	return nil
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if censusDir == "" && flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	gob.Register(time.Time{})
+	gob.Register(&cpu1.Cpu{})
+	gob.Register(&cpu2.Cpu{})
+	gob.Register(&food.Food{})
+	gob.Register(&org.Organism{})
+
+	if fromFile != "" {
+		var err error
+		if from, err = readNames(fromFile); err != nil {
+			fmt.Printf("--from: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if censusDir != "" {
+		if err := migrateCensus(censusDir); err != nil {
+			fmt.Printf("error migrating %s: %v\n", censusDir, err)
+			os.Exit(1)
+		}
+	}
+	for _, filename := range flag.Args() {
+		if err := migrateSnapshot(filename); err != nil {
+			fmt.Printf("error migrating %s: %v\n", filename, err)
+			os.Exit(1)
+		}
+	}
+}
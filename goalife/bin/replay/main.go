@@ -0,0 +1,97 @@
+// Replay reconstructs a world from an autosave snapshot and a journal
+// recorded by goalife --journal, as it was after a given change, and
+// renders it to the terminal.
+package main
+
+import "encoding/gob"
+import "flag"
+import "fmt"
+import "os"
+import "path"
+import "time"
+
+import "github.com/dnesting/alife/goalife/grid2d/autosave"
+import "github.com/dnesting/alife/goalife/grid2d/food"
+import "github.com/dnesting/alife/goalife/grid2d/journal"
+import "github.com/dnesting/alife/goalife/grid2d/org"
+import "github.com/dnesting/alife/goalife/grid2d/org/cpu1"
+import "github.com/dnesting/alife/goalife/grid2d/org/cpu2"
+import "github.com/dnesting/alife/goalife/term"
+
+var (
+	seq      uint64
+	geometry string
+	saveFile string
+)
+
+func init() {
+	flag.Uint64Var(&seq, "seq", journal.Last, "replay up to and including this change (default: all)")
+	flag.StringVar(&geometry, "geometry", "", "shape of the world's cells: square or hex (default: as saved, else square)")
+	flag.StringVar(&saveFile, "save-file", "", "save the reconstructed world to this file")
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [flags] /path/to/autosave /path/to/journal\n", path.Base(os.Args[0]))
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	gob.Register(time.Time{})
+	gob.Register(&cpu1.Cpu{})
+	gob.Register(&cpu2.Cpu{})
+	gob.Register(&food.Food{})
+	gob.Register(&org.Organism{})
+
+	h, err := autosave.ReadHeader(flag.Arg(0))
+	if err != nil {
+		fmt.Printf("error reading %s: %v\n", flag.Arg(0), err)
+		os.Exit(1)
+	}
+	if geometry != "" {
+		if h.Params == nil {
+			h.Params = make(map[string]string)
+		}
+		h.Params["geometry"] = geometry
+	}
+	g, err := autosave.NewGrid(h)
+	if err != nil {
+		fmt.Printf("error reading %s: %v\n", flag.Arg(0), err)
+		os.Exit(1)
+	}
+	if err := autosave.Restore(flag.Arg(0), g); err != nil {
+		fmt.Printf("error restoring from %s: %v\n", flag.Arg(0), err)
+		os.Exit(1)
+	}
+	from := g.Seq()
+
+	f, err := os.Open(flag.Arg(1))
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+	last, err := journal.Replay(g, f, from, seq)
+	if err != nil {
+		fmt.Printf("error replaying %s: %v\n", flag.Arg(1), err)
+		os.Exit(1)
+	}
+
+	term.PrintWorld(os.Stdout, g)
+	fmt.Println()
+	_, _, count := g.Locations(nil)
+	fmt.Printf("replayed changes %d to %d, %d occupants\n", from+1, last, count)
+
+	if saveFile != "" {
+		if err := autosave.Save(saveFile, g); err != nil {
+			fmt.Printf("error saving to %s: %v\n", saveFile, err)
+			os.Exit(1)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+// Sweep runs bin/goalife over every combination of a set of parameter
+// values, each repeated a number of times with distinct seeds, and collates
+// the summaries of the runs into a CSV file.
+//
+// Each run is a separate bin/goalife process, since the mutation parameters
+// and physics are global to a process.  Its configuration, census,
+// snapshots, output and summary are kept in its own directory under --out.
+// Parameters are given as paths into an experiment file (see package
+// experiment), such as:
+//
+//	sweep --config=base.json --replicates=5 \
+//	    --vary=Mutation.Rate=0.001,0.01,0.1 \
+//	    --vary=World.Width=100,200 \
+//	    --vary=Physics.Costs.cpu1.Ops.Forward=5,20 \
+//	    --vary=Stop.Rounds=100000
+//
+// Any arguments following the flags are passed on to each bin/goalife.
+package main
+
+import "encoding/csv"
+import "flag"
+import "fmt"
+import "os"
+import "os/exec"
+import "path"
+import "runtime"
+import "strconv"
+import "strings"
+import "sync"
+
+import "github.com/dnesting/alife/goalife/experiment"
+
+var (
+	configFile string
+	params     paramsFlag
+	replicates int
+	seed       int64
+	jobs       int
+	goalife    string
+	outDir     string
+	csvFile    string
+)
+
+func init() {
+	flag.StringVar(&configFile, "config", "", "base experiment file (default: the built-in defaults)")
+	flag.Var(&params, "vary", "vary a parameter over comma-separated values, as path=value,...; may be repeated")
+	flag.IntVar(&replicates, "replicates", 1, "number of runs of each combination of values")
+	flag.Int64Var(&seed, "seed", 1, "seed of the first run; the others count up from it")
+	flag.IntVar(&jobs, "jobs", runtime.NumCPU(), "number of runs executed at once")
+	flag.StringVar(&goalife, "goalife", "goalife", "the bin/goalife executable")
+	flag.StringVar(&outDir, "out", "/tmp/sweep", "directory to keep the runs in")
+	flag.StringVar(&csvFile, "csv", "", "write the results to this file (default: results.csv in --out)")
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [flags] [-- goalife flags]\n", path.Base(os.Args[0]))
+	flag.PrintDefaults()
+}
+
+// paramsFlag is a flag.Value accumulating experiment.Params.
+type paramsFlag []experiment.Param
+
+func (f *paramsFlag) String() string {
+	var s []string
+	for _, p := range *f {
+		s = append(s, p.Path+"="+strings.Join(p.Values, ","))
+	}
+	return strings.Join(s, " ")
+}
+
+func (f *paramsFlag) Set(s string) error {
+	p, err := experiment.ParseParam(s)
+	if err != nil {
+		return err
+	}
+	*f = append(*f, p)
+	return nil
+}
+
+// result is the outcome of a run.
+type result struct {
+	summary *experiment.Summary
+	err     error
+}
+
+// execute runs r in dir, returning its summary.
+func execute(r experiment.Run, dir string) result {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return result{nil, err}
+	}
+	c := *r.Config
+	c.Census.Dir = path.Join(dir, "census")
+	c.Autosave.Dir = path.Join(dir, "autosave")
+	if c.Census.Lineage != "" {
+		c.Census.Lineage = path.Join(dir, "lineage")
+	}
+	configFile := path.Join(dir, "config.json")
+	if err := c.Write(configFile); err != nil {
+		return result{nil, err}
+	}
+	summaryFile := path.Join(dir, "summary.json")
+	os.Remove(summaryFile)
+
+	out, err := os.Create(path.Join(dir, "output"))
+	if err != nil {
+		return result{nil, err}
+	}
+	defer out.Close()
+	args := append([]string{"--config=" + configFile, "--print=false", "--resume=none", "--summary=" + summaryFile}, flag.Args()...)
+	cmd := exec.Command(goalife, args...)
+	cmd.Stdout, cmd.Stderr = out, out
+	err = cmd.Run()
+
+	// A run stopping for a reason other than a limit exits with a non-zero
+	// status, but still writes its summary.
+	s, serr := experiment.ReadSummary(summaryFile)
+	if serr != nil {
+		if err == nil {
+			err = serr
+		}
+		return result{nil, fmt.Errorf("%v (see %s)", err, out.Name())}
+	}
+	return result{s, nil}
+}
+
+// runAll executes runs, jobs at a time, and returns their results in
+// order.
+func runAll(runs []experiment.Run) []result {
+	results := make([]result, len(runs))
+	ch := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range ch {
+				r := runs[n]
+				results[n] = execute(r, path.Join(outDir, fmt.Sprintf("run-%04d", r.Index)))
+				if err := results[n].err; err != nil {
+					fmt.Printf("run %d: %v\n", r.Index, err)
+				} else {
+					fmt.Printf("run %d: stopped (%s)\n", r.Index, results[n].summary.Reason)
+				}
+			}
+		}()
+	}
+	for i := range runs {
+		ch <- i
+	}
+	close(ch)
+	wg.Wait()
+	return results
+}
+
+// writeCSV writes a row for each run to filename.
+func writeCSV(filename string, runs []experiment.Run, results []result) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	head := []string{"run", "replicate", "seed"}
+	for _, p := range params {
+		head = append(head, p.Path)
+	}
+	head = append(head, "reason", "status", "rounds", "elapsed", "orgs", "species", "largest", "diversity",
+		"count_all_time", "distinct_all_time", "top_genome", "top_count", "error")
+	w.Write(head)
+
+	for i, r := range runs {
+		row := []string{strconv.Itoa(r.Index), strconv.Itoa(r.Replicate), strconv.FormatInt(r.Config.Seed, 10)}
+		row = append(row, r.Values...)
+		if s := results[i].summary; s != nil {
+			var topGenome, topCount string
+			if len(s.Top) > 0 {
+				topGenome, topCount = s.Top[0].Hash, strconv.Itoa(s.Top[0].Count)
+			}
+			row = append(row, s.Reason, strconv.Itoa(s.Status), strconv.FormatInt(s.Rounds, 10),
+				strconv.FormatFloat(s.Elapsed, 'f', 3, 64),
+				strconv.Itoa(s.Final.Orgs), strconv.Itoa(s.Final.Species), strconv.Itoa(s.Final.Largest),
+				strconv.FormatFloat(s.Final.Diversity, 'f', 4, 64),
+				strconv.Itoa(s.Census.CountAllTime), strconv.Itoa(s.Census.DistinctAllTime),
+				topGenome, topCount, "")
+		} else {
+			row = append(row, "", "", "", "", "", "", "", "", "", "", "", "", results[i].err.Error())
+		}
+		w.Write(row)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	base := experiment.Default()
+	if configFile != "" {
+		if err := experiment.Load(configFile, base); err != nil {
+			fmt.Printf("--config: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if jobs < 1 {
+		fmt.Printf("--jobs: must be at least 1\n")
+		os.Exit(2)
+	}
+	runs, err := experiment.Sweep(base, params, replicates, seed)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(1)
+	}
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		fmt.Printf("--out: %v\n", err)
+		os.Exit(1)
+	}
+	if csvFile == "" {
+		csvFile = path.Join(outDir, "results.csv")
+	}
+
+	fmt.Printf("%d runs, %d at a time, in %s\n", len(runs), jobs, outDir)
+	results := runAll(runs)
+	if err := writeCSV(csvFile, runs, results); err != nil {
+		fmt.Printf("--csv: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("results written to %s\n", csvFile)
+	for _, r := range results {
+		if r.err != nil {
+			os.Exit(1)
+		}
+	}
+}
@@ -1,7 +1,6 @@
 package census
 
 import "errors"
-import "encoding/gob"
 import "fmt"
 import "io"
 import "io/ioutil"
@@ -9,20 +8,27 @@ import "math/rand"
 import "os"
 import "path"
 
+import "github.com/dnesting/alife/goalife/util/schema"
+
 var deps = struct {
 	ReadDir  func(string) ([]os.FileInfo, error)
 	Stat     func(string) (os.FileInfo, error)
 	Create   func(string) (io.ReadWriteCloser, error)
 	Open     func(string) (io.ReadWriteCloser, error)
 	MkdirAll func(string, os.FileMode) error
+	Remove   func(string) error
 }{
 	ioutil.ReadDir,
 	os.Stat,
 	func(s string) (io.ReadWriteCloser, error) { return os.Create(s) },
 	func(s string) (io.ReadWriteCloser, error) { return os.Open(s) },
 	os.MkdirAll,
+	os.Remove,
 }
 
+// Magic identifies population files written by a DirCensus.
+const Magic = "goalife/census"
+
 // DirCensus implements a Census that saves interesting populations to disk.
 type DirCensus struct {
 	Dir       string                  // the parent directory holding populations
@@ -61,7 +67,7 @@ func (b *DirCensus) IsRecorded(key Key) bool {
 	return err == nil
 }
 
-// Record writes population to disk.
+// Record writes population to disk, preceded by a schema.Header.
 func (b *DirCensus) Record(c Population) error {
 	f, err := deps.Create(b.filename(c.Key))
 	if err != nil {
@@ -69,7 +75,10 @@ func (b *DirCensus) Record(c Population) error {
 	}
 	defer f.Close()
 
-	enc := gob.NewEncoder(f)
+	enc, err := schema.Write(f, schema.New(Magic))
+	if err != nil {
+		return err
+	}
 	if err := enc.Encode(c); err != nil {
 		return err
 	}
@@ -91,21 +100,77 @@ func (b *DirCensus) Random() (Population, error) {
 	return b.decodeFromFilename(path.Join(b.Dir, fi.Name()))
 }
 
-func (b *DirCensus) decodeFromFilename(name string) (Population, error) {
+// ReadFile reads the population file name and the schema.Header it was
+// written with, without checking that it is compatible.  Files written
+// without a header yield the schema.Legacy header.
+func ReadFile(name string) (schema.Header, Population, error) {
 	f, err := deps.Open(name)
 	if err != nil {
-		return Population{}, err
+		return schema.Header{}, Population{}, err
 	}
 	defer f.Close()
 
-	dec := gob.NewDecoder(f)
+	h, dec, err := schema.Read(f, Magic)
+	if err != nil {
+		return h, Population{}, err
+	}
 	var p Population
 	if err := dec.Decode(&p); err != nil {
+		return h, Population{}, err
+	}
+	return h, p, nil
+}
+
+// decodeFromFilename reads the population in the file name, returning a
+// *schema.IncompatibleError if it was written with a different opcode
+// table or format.
+func (b *DirCensus) decodeFromFilename(name string) (Population, error) {
+	h, p, err := ReadFile(name)
+	if err != nil {
+		return Population{}, err
+	}
+	if err := h.Check(Magic); err != nil {
 		return Population{}, err
 	}
 	return p, nil
 }
 
+// Migrate rewrites the populations on disk written with an older format or
+// with tables that have since changed.  fn is called to update each such
+// population, given the header it was written with.  Each population is
+// then recorded again with the current header, under its updated key.
+// Returns the number of populations rewritten.
+func (b *DirCensus) Migrate(fn func(h schema.Header, p *Population) error) (int, error) {
+	ls, err := deps.ReadDir(b.Dir)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, fi := range ls {
+		name := path.Join(b.Dir, fi.Name())
+		h, p, err := ReadFile(name)
+		if err != nil {
+			return n, fmt.Errorf("%s: %v", name, err)
+		}
+		if h.Version == schema.Version && h.Check(Magic) == nil {
+			continue
+		}
+		if err := fn(h, &p); err != nil {
+			return n, fmt.Errorf("%s: %v", name, err)
+		}
+		if err := b.Record(p); err != nil {
+			return n, err
+		}
+		if b.filename(p.Key) != name {
+			if err := deps.Remove(name); err != nil {
+				return n, err
+			}
+		}
+		n++
+	}
+	return n, nil
+}
+
 // Add indicates an instance of population was added, possibly
 // writing the Population to disk if it satisfies the DirCensus's
 // threshold.
@@ -8,6 +8,8 @@ import "path"
 import "testing"
 import "time"
 
+import "github.com/dnesting/alife/goalife/util/schema"
+
 type closeBuffer struct {
 	bytes.Buffer
 	Closed bool
@@ -18,11 +20,18 @@ func (c *closeBuffer) Close() error {
 	return nil
 }
 
-func encoded(t *testing.T, p Population) io.ReadWriteCloser {
+// encoded encodes p, preceded by h if it is non-nil.
+func encoded(t *testing.T, h *schema.Header, p Population) *closeBuffer {
 	fk := fakeKey(0)
 	gob.Register(fk)
 	var b closeBuffer
 	enc := gob.NewEncoder(&b.Buffer)
+	if h != nil {
+		var err error
+		if enc, err = schema.Write(&b.Buffer, *h); err != nil {
+			t.Fatalf("unable to encode %v: %v", h, err)
+		}
+	}
 	if err := enc.Encode(p); err != nil {
 		t.Fatalf("unable to encode %v: %v", p, err)
 	}
@@ -31,7 +40,10 @@ func encoded(t *testing.T, p Population) io.ReadWriteCloser {
 
 func decoded(t *testing.T, b *closeBuffer) Population {
 	var p Population
-	dec := gob.NewDecoder(b)
+	h, dec, err := schema.Read(b, Magic)
+	if err != nil || h.Version == 0 {
+		t.Fatalf("expected a header, got %v (err=%v)", h, err)
+	}
 	if err := dec.Decode(&p); err != nil {
 		t.Fatalf("unable to decode %v: %v", p, err)
 	}
@@ -75,7 +87,8 @@ func TestGetFromRecord(t *testing.T) {
 	badKey := fakeKey(0x101)
 	badFile := path.Join(dir, "101")
 
-	f := encoded(t, Population{
+	h := schema.New(Magic)
+	f := encoded(t, &h, Population{
 		Key:   key,
 		Count: 10,
 	})
@@ -195,6 +208,9 @@ func TestAdd(t *testing.T) {
 	c.Add(31, key2)
 	c.Add(32, key2)
 
+	if !ok {
+		t.Errorf("expected %v to be recorded", file2)
+	}
 	p := decoded(t, b)
 	if p.Key != key2 {
 		t.Errorf("Unexpected key, expected %v got %+v", key2, p)
@@ -258,3 +274,106 @@ func TestRemove(t *testing.T) {
 		t.Errorf("Unexpected last time, expected 25 got %v", p.Last)
 	}
 }
+
+func TestGetLegacy(t *testing.T) {
+	dir := "/path/foo"
+	key := fakeKey(0x100)
+	deps.Open = func(s string) (io.ReadWriteCloser, error) {
+		return encoded(t, nil, Population{Key: key, Count: 10}), nil
+	}
+
+	c := DirCensus{Dir: dir}
+	p, err := c.GetFromRecord(key)
+	if err != nil {
+		t.Errorf("got error %v reading a population saved without a header", err)
+	}
+	if p.Count != 10 {
+		t.Errorf("retrieved count was wrong, expected 10, got %v", p.Count)
+	}
+}
+
+func TestGetIncompatible(t *testing.T) {
+	schema.Register("census.test", func() []string { return []string{"b", "a"} }, []string{"a", "b"})
+	defer schema.Register("census.test", func() []string { return nil }, nil)
+
+	dir := "/path/foo"
+	key := fakeKey(0x100)
+	var f *closeBuffer
+	deps.Open = func(s string) (io.ReadWriteCloser, error) { return f, nil }
+	c := DirCensus{Dir: dir}
+
+	h := schema.New(Magic)
+	h.Tables["census.test"] = []string{"a", "b"}
+	f = encoded(t, &h, Population{Key: key, Count: 10})
+	if _, err := c.GetFromRecord(key); err == nil {
+		t.Errorf("expected an error reading a population saved with a different table")
+	} else if _, ok := err.(*schema.IncompatibleError); !ok {
+		t.Errorf("expected *schema.IncompatibleError got %v", err)
+	}
+
+	// Files saved without a header are assumed to use the legacy table.
+	f = encoded(t, nil, Population{Key: key, Count: 10})
+	if _, err := c.GetFromRecord(key); err == nil {
+		t.Errorf("expected an error reading a legacy population with a changed table")
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	dir := "/path/foo"
+	h := schema.New(Magic)
+	files := map[string]*closeBuffer{
+		path.Join(dir, "100"): encoded(t, nil, Population{Key: fakeKey(0x100), Count: 10}),
+		path.Join(dir, "200"): encoded(t, &h, Population{Key: fakeKey(0x200), Count: 20}),
+	}
+	deps.ReadDir = func(s string) ([]os.FileInfo, error) {
+		return []os.FileInfo{fi{"100"}, fi{"200"}}, nil
+	}
+	deps.Open = func(s string) (io.ReadWriteCloser, error) {
+		if f, ok := files[s]; ok {
+			// Each Open reads from the beginning.
+			return encodedCopy(f), nil
+		}
+		return nil, os.ErrNotExist
+	}
+	deps.Create = func(s string) (io.ReadWriteCloser, error) {
+		files[s] = &closeBuffer{}
+		return files[s], nil
+	}
+	deps.Remove = func(s string) error {
+		delete(files, s)
+		return nil
+	}
+
+	c := DirCensus{Dir: dir}
+	var migrated []Key
+	n, err := c.Migrate(func(h schema.Header, p *Population) error {
+		if h.Version != 0 {
+			t.Errorf("expected only the legacy population to be migrated, got version %d", h.Version)
+		}
+		migrated = append(migrated, p.Key)
+		p.Key = fakeKey(0x300)
+		return nil
+	})
+	if err != nil {
+		t.Errorf("unexpected error migrating: %v", err)
+	}
+	if n != 1 || len(migrated) != 1 || migrated[0] != fakeKey(0x100) {
+		t.Errorf("expected only %v to be migrated, got %d %v", fakeKey(0x100), n, migrated)
+	}
+	if _, ok := files[path.Join(dir, "100")]; ok {
+		t.Errorf("expected the old population file to be removed")
+	}
+	p, err := c.GetFromRecord(fakeKey(0x300))
+	if err != nil {
+		t.Errorf("got error %v reading the migrated population", err)
+	} else if p.Count != 10 {
+		t.Errorf("expected the migrated population to have count 10, got %v", p.Count)
+	}
+}
+
+// encodedCopy returns a copy of b that can be read independently.
+func encodedCopy(b *closeBuffer) *closeBuffer {
+	var c closeBuffer
+	c.Write(b.Bytes())
+	return &c
+}
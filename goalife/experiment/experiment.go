@@ -0,0 +1,274 @@
+// Package experiment describes all of the parameters of a run of the
+// world in a single Config, which can be read from and written to a JSON
+// file so that a run can be described, shared and reproduced.
+//
+// A Config file need only give the fields that differ from the defaults,
+// such as:
+//
+//	{
+//	    "World": {"Width": 100, "Height": 40, "Topology": "walled"},
+//	    "Seed": 42,
+//	    "Sched": "shuffled",
+//	    "Mutation": {"Rate": 0.05},
+//	    "Physics": {"BodyEnergy": 2000}
+//	}
+package experiment
+
+import "encoding/json"
+import "fmt"
+import "io"
+import "os"
+import "time"
+
+import "github.com/dnesting/alife/goalife/grid2d"
+import "github.com/dnesting/alife/goalife/grid2d/org/cpu1"
+import "github.com/dnesting/alife/goalife/grid2d/org/cpu2"
+import "github.com/dnesting/alife/goalife/mutation"
+import "github.com/dnesting/alife/goalife/physics"
+
+// World describes the shape of the world.
+type World struct {
+	Width, Height int
+	Topology      string // torus, walled or reflective
+	Geometry      string // square or hex
+}
+
+// Seeding describes the organisms introduced into the world from outside.
+type Seeding struct {
+	Min       int      // maintain at least this many organisms
+	Once      bool     // seed Min organisms at the start only, instead of maintaining Min
+	Cpu       string   // the virtual machine driving them: cpu1 or cpu2
+	Ancestors []string `json:",omitempty"` // cpu1 source files to seed them from, if any
+	Random    float64  // with Ancestors, the fraction seeded with random code
+}
+
+// Mutation describes how genomes vary.
+type Mutation struct {
+	Rate      float64 // the probability that an offspring's genome is mutated
+	LengthMin int     // the minimum length of random genomes
+	LengthMax int     // the maximum length of random genomes
+
+	// Operators gives the relative weight of each kind of mutation (see
+	// mutation.Kinds) when a genome is mutated.  If empty, point,
+	// duplicate and delete are weighted equally.
+	Operators map[mutation.Kind]float64 `json:",omitempty"`
+	Segment   float64                   // the standard deviation of the lengths of segments mutated
+	CopyError float64                   // the probability that a copy mutation miscopies each instruction
+	Crossover int                       // the crossover points (1 or 2) used by cpu1's Mate; 0 disables recombination
+}
+
+// Scheme returns the mutation.Scheme described by m.
+func (m Mutation) Scheme() *mutation.Scheme {
+	s := mutation.DefaultScheme()
+	if len(m.Operators) > 0 {
+		s.Weights = m.Operators
+	}
+	s.Segment = m.Segment
+	s.CopyError = m.CopyError
+	return s
+}
+
+// Census describes how populations are recorded.
+type Census struct {
+	Dir       string // the directory populations are recorded to
+	Threshold int    // populations are recorded once they exceed this size
+	Lineage   string `json:",omitempty"` // the file genomes' lineage is recorded to; empty disables
+}
+
+// Autosave describes how the world is saved.
+type Autosave struct {
+	Dir   string // the directory snapshots are saved to; empty disables
+	Every int    // save every this many seconds; 0 disables
+	Keep  int    // always keep this many of the most recent snapshots
+	Thin  string // beyond Keep, how to thin older snapshots (see autosave.ParseThin)
+}
+
+// Stop describes when a run should stop.  A run stops when any condition
+// given is met, or runs forever if none are.
+type Stop struct {
+	Rounds    int64   `json:",omitempty"` // after this many rounds (requires Sched other than goroutine)
+	After     string  `json:",omitempty"` // after this much time has passed, as a time.Duration
+	Extinct   bool    `json:",omitempty"` // when no organisms remain (see Seeding.Once)
+	Species   int     `json:",omitempty"` // when any species has more than this many organisms
+	Diversity float64 `json:",omitempty"` // when the diversity of genomes (see Point) falls below this
+}
+
+// Any returns true if any condition is given.
+func (s Stop) Any() bool {
+	return s.Rounds > 0 || s.After != "" || s.Extinct || s.Species > 0 || s.Diversity > 0
+}
+
+// Sample describes how often a run's progress is sampled and its Stop
+// conditions checked.
+type Sample struct {
+	Rounds   int64  // every this many rounds, when Sched is not goroutine
+	Interval string // every this much time, as a time.Duration, when Sched is goroutine
+}
+
+// Config describes a run of the world.
+type Config struct {
+	World    World
+	Seed     int64  // seeds the world's randomness; 0 picks one based on the time
+	Sched    string // how organisms are executed: goroutine, round-robin or shuffled
+	Seeding  Seeding
+	Mutation Mutation
+	Physics  *physics.Physics `json:",omitempty"` // if nil, as saved or the default
+	Census   Census
+	Autosave Autosave
+	Stop     Stop
+	Sample   Sample
+}
+
+// Default returns the default Config.
+func Default() *Config {
+	return &Config{
+		World: World{
+			Width:    200,
+			Height:   50,
+			Topology: "torus",
+			Geometry: "square",
+		},
+		Sched: "goroutine",
+		Seeding: Seeding{
+			Min: 50,
+			Cpu: "cpu1",
+		},
+		Mutation: Mutation{
+			Rate:      0.01,
+			LengthMin: 50,
+			LengthMax: 1000,
+			Segment:   5,
+			CopyError: 0.01,
+		},
+		Census: Census{
+			Dir:       "/tmp/census",
+			Threshold: 40,
+		},
+		Autosave: Autosave{
+			Dir:   "/tmp/autosave",
+			Every: 3,
+			Keep:  20,
+			Thin:  "0s:1m,1h:1h,24h:24h",
+		},
+		Sample: Sample{
+			Rounds:   100,
+			Interval: "1s",
+		},
+	}
+}
+
+// Decode reads a Config encoded as JSON from r into c.  Fields left out keep
+// their values in c, and unknown fields are an error.
+func Decode(r io.Reader, c *Config) error {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	return dec.Decode(c)
+}
+
+// Load reads the JSON file filename into c, as Decode.
+func Load(filename string, c *Config) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := Decode(f, c); err != nil {
+		return fmt.Errorf("%s: %v", filename, err)
+	}
+	return nil
+}
+
+// Write writes c to filename as indented JSON, replacing it.
+func (c *Config) Write(filename string) error {
+	b, err := json.MarshalIndent(c, "", "    ")
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Check returns an error describing the first problem found with c.
+func (c *Config) Check() error {
+	if c.World.Width < 1 || c.World.Height < 1 {
+		return fmt.Errorf("World: invalid size %dx%d", c.World.Width, c.World.Height)
+	}
+	if _, err := grid2d.TopologyByName(c.World.Topology); err != nil {
+		return fmt.Errorf("World.Topology: %v", err)
+	}
+	if _, err := grid2d.GeometryByName(c.World.Geometry); err != nil {
+		return fmt.Errorf("World.Geometry: %v", err)
+	}
+	switch c.Sched {
+	case "goroutine", "round-robin", "shuffled":
+	default:
+		return fmt.Errorf("Sched: unknown mode %q", c.Sched)
+	}
+	switch c.Seeding.Cpu {
+	case "cpu1", "cpu2":
+	default:
+		return fmt.Errorf("Seeding.Cpu: unknown cpu %q", c.Seeding.Cpu)
+	}
+	if len(c.Seeding.Ancestors) > 0 && c.Seeding.Cpu != "cpu1" {
+		return fmt.Errorf("Seeding.Ancestors requires cpu1")
+	}
+	if c.Mutation.Rate < 0 || c.Mutation.Rate > 1 {
+		return fmt.Errorf("Mutation.Rate: %v is not a probability", c.Mutation.Rate)
+	}
+	if c.Mutation.LengthMin < 1 || c.Mutation.LengthMax <= c.Mutation.LengthMin {
+		return fmt.Errorf("Mutation: invalid lengths %d-%d", c.Mutation.LengthMin, c.Mutation.LengthMax)
+	}
+	if c.Mutation.Crossover < 0 || c.Mutation.Crossover > 2 {
+		return fmt.Errorf("Mutation.Crossover: %d must be 0, 1 or 2", c.Mutation.Crossover)
+	}
+	if err := c.Mutation.Scheme().Check(); err != nil {
+		return fmt.Errorf("Mutation: %v", err)
+	}
+	if c.Stop.After != "" {
+		if _, err := time.ParseDuration(c.Stop.After); err != nil {
+			return fmt.Errorf("Stop.After: %v", err)
+		}
+	}
+	if c.Stop.Rounds > 0 && c.Sched == "goroutine" {
+		return fmt.Errorf("Stop.Rounds requires Sched round-robin or shuffled")
+	}
+	if c.Sample.Rounds < 1 {
+		return fmt.Errorf("Sample.Rounds: %d must be at least 1", c.Sample.Rounds)
+	}
+	if d, err := time.ParseDuration(c.Sample.Interval); err != nil || d <= 0 {
+		return fmt.Errorf("Sample.Interval: invalid interval %q", c.Sample.Interval)
+	}
+	if c.Physics != nil {
+		if err := c.Physics.Check(); err != nil {
+			return fmt.Errorf("Physics: %v", err)
+		}
+	}
+	return nil
+}
+
+// Apply checks c, and puts into effect its mutation parameters and its
+// Physics, if any.  It must be called before any organisms begin executing.
+func (c *Config) Apply() error {
+	if err := c.Check(); err != nil {
+		return err
+	}
+	cpu1.MutationRate = c.Mutation.Rate
+	cpu1.RandLengthMin, cpu1.RandLengthMax = c.Mutation.LengthMin, c.Mutation.LengthMax
+	cpu1.SetCrossoverPoints(c.Mutation.Crossover)
+	cpu2.MutationRate = c.Mutation.Rate
+	cpu2.RandLengthMin, cpu2.RandLengthMax = c.Mutation.LengthMin, c.Mutation.LengthMax
+	if err := mutation.Set(c.Mutation.Scheme()); err != nil {
+		return err
+	}
+	if c.Physics != nil {
+		return physics.Set(c.Physics)
+	}
+	return nil
+}
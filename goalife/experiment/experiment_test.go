@@ -0,0 +1,105 @@
+package experiment
+
+import "io/ioutil"
+import "os"
+import "path"
+import "reflect"
+import "strings"
+import "testing"
+
+import "github.com/dnesting/alife/goalife/grid2d/org/cpu1"
+import "github.com/dnesting/alife/goalife/mutation"
+import "github.com/dnesting/alife/goalife/physics"
+
+func TestDecode(t *testing.T) {
+	c := Default()
+	err := Decode(strings.NewReader(`{
+		"World": {"Width": 10, "Topology": "walled", "Geometry": "hex"},
+		"Seed": 42,
+		"Sched": "shuffled",
+		"Physics": {"MoveCost": 3}
+	}`), c)
+	if err != nil {
+		t.Fatalf("error decoding: %v", err)
+	}
+	expected := Default()
+	expected.World = World{Width: 10, Height: 50, Topology: "walled", Geometry: "hex"}
+	expected.Seed = 42
+	expected.Sched = "shuffled"
+	expected.Physics = physics.Default()
+	expected.Physics.MoveCost = 3
+	if !reflect.DeepEqual(c, expected) {
+		t.Errorf("expected %+v got %+v", expected, c)
+	}
+
+	for _, bad := range []string{`{"Wrold": {}}`, `{"Physics": {"MoveCots": 3}}`} {
+		if err := Decode(strings.NewReader(bad), Default()); err == nil {
+			t.Errorf("expected an error decoding an unknown field in %s", bad)
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "experiment")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	name := path.Join(dir, "experiment.json")
+	if err := c.Write(name); err != nil {
+		t.Fatalf("error writing: %v", err)
+	}
+	got := &Config{}
+	if err := Load(name, got); err != nil || !reflect.DeepEqual(got, c) {
+		t.Errorf("expected %+v to round-trip, got %+v (err=%v)", c, got, err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	bad := []func(c *Config){
+		func(c *Config) { c.World.Width = 0 },
+		func(c *Config) { c.World.Topology = "klein" },
+		func(c *Config) { c.World.Geometry = "triangle" },
+		func(c *Config) { c.Sched = "random" },
+		func(c *Config) { c.Seeding.Cpu = "cpu3" },
+		func(c *Config) { c.Seeding.Cpu, c.Seeding.Ancestors = "cpu2", []string{"a.s"} },
+		func(c *Config) { c.Mutation.Rate = 2 },
+		func(c *Config) { c.Mutation.LengthMax = c.Mutation.LengthMin },
+		func(c *Config) { c.Mutation.Operators = map[mutation.Kind]float64{"splice": 1} },
+		func(c *Config) { c.Mutation.CopyError = -1 },
+		func(c *Config) { c.Mutation.Crossover = 3 },
+		func(c *Config) { c.Physics = &physics.Physics{} },
+		func(c *Config) { c.Stop.After = "soon" },
+		func(c *Config) { c.Stop.Rounds = 10 },
+		func(c *Config) { c.Sample.Interval = "0s" },
+	}
+	for i, fn := range bad {
+		c := Default()
+		fn(c)
+		if err := c.Check(); err == nil {
+			t.Errorf("case %d: expected an error checking %+v", i, c)
+		}
+	}
+	if err := Default().Check(); err != nil {
+		t.Errorf("expected the default config to check cleanly, got %v", err)
+	}
+}
+
+func TestApply(t *testing.T) {
+	defer func(r float64) { cpu1.MutationRate = r }(cpu1.MutationRate)
+	defer physics.Set(physics.Default())
+	defer mutation.Set(mutation.DefaultScheme())
+
+	c := Default()
+	c.Mutation.Rate = 0.5
+	c.Mutation.Operators = map[mutation.Kind]float64{mutation.Inversion: 1}
+	c.Physics = physics.Default()
+	c.Physics.BodyEnergy = 5
+	if err := c.Apply(); err != nil {
+		t.Fatalf("error applying: %v", err)
+	}
+	if cpu1.MutationRate != 0.5 || physics.Current().BodyEnergy != 5 {
+		t.Errorf("expected mutation rate 0.5 and body energy 5 got %v and %v", cpu1.MutationRate, physics.Current().BodyEnergy)
+	}
+	if w := mutation.Current().Weights; !reflect.DeepEqual(w, c.Mutation.Operators) {
+		t.Errorf("expected mutation weights %v got %v", c.Mutation.Operators, w)
+	}
+}
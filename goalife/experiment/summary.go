@@ -0,0 +1,177 @@
+package experiment
+
+import "encoding/json"
+import "fmt"
+import "math"
+import "os"
+import "sort"
+import "time"
+
+import "github.com/dnesting/alife/goalife/census"
+import "github.com/dnesting/alife/goalife/grid2d"
+import "github.com/dnesting/alife/goalife/grid2d/org"
+import "github.com/dnesting/alife/goalife/grid2d/org/cpu1"
+import "github.com/dnesting/alife/goalife/grid2d/org/cpu2"
+
+// The reasons a run stops, and the exit status of a process stopping for
+// each.  Status 1 and 2 are left for errors and invalid usage.
+const (
+	StatusLimit     = 0 // Stop.Rounds or Stop.After was reached
+	StatusExtinct   = 3 // Stop.Extinct
+	StatusSpecies   = 4 // Stop.Species
+	StatusDiversity = 5 // Stop.Diversity
+)
+
+// Point describes the world at a moment of a run.
+type Point struct {
+	Round     int64   // rounds completed, if scheduled
+	Elapsed   float64 // seconds since the run began
+	Orgs      int     // organisms living
+	Species   int     // distinct genomes among them
+	Largest   int     // organisms sharing the most common genome
+	Diversity float64 // the Shannon diversity index of their genomes, in nats
+}
+
+// Genome describes a population of organisms sharing a genome.
+type Genome struct {
+	Hash   string // the genome's census key, in hex
+	Cpu    string // the kind of Cpu: cpu1 or cpu2
+	Length int    // the genome's length in bytes
+	Count  int    // organisms living with the genome
+}
+
+// Observe scans g, returning a Point describing it (without Round or
+// Elapsed) and the genomes of its organisms, most numerous first.
+func Observe(g grid2d.Grid) (Point, []Genome) {
+	var locs []grid2d.Point
+	g.Locations(&locs)
+	pops := make(map[uint64]*Genome)
+	var p Point
+	for _, l := range locs {
+		o, ok := l.V.(*org.Organism)
+		if !ok {
+			continue
+		}
+		k, ok := o.Driver.(census.Key)
+		if !ok {
+			continue
+		}
+		p.Orgs++
+		h := k.Hash()
+		gn := pops[h]
+		if gn == nil {
+			gn = &Genome{Hash: fmt.Sprintf("%x", h)}
+			switch c := k.(type) {
+			case *cpu1.Cpu:
+				gn.Cpu, gn.Length = "cpu1", len(c.Code)
+			case *cpu2.Cpu:
+				gn.Cpu, gn.Length = "cpu2", len(c.Code)
+			}
+			pops[h] = gn
+		}
+		gn.Count++
+	}
+
+	genomes := make([]Genome, 0, len(pops))
+	for _, gn := range pops {
+		genomes = append(genomes, *gn)
+		frac := float64(gn.Count) / float64(p.Orgs)
+		p.Diversity -= frac * math.Log(frac)
+		if gn.Count > p.Largest {
+			p.Largest = gn.Count
+		}
+	}
+	sort.Slice(genomes, func(i, j int) bool {
+		if genomes[i].Count != genomes[j].Count {
+			return genomes[i].Count > genomes[j].Count
+		}
+		return genomes[i].Hash < genomes[j].Hash
+	})
+	p.Species = len(genomes)
+	return p, genomes
+}
+
+// Check returns the reason and exit status for stopping a run, given the
+// rounds completed, the time elapsed and, if sampled, a Point describing
+// the world.  Returns an empty reason if the run should continue.
+func (s Stop) Check(rounds int64, elapsed time.Duration, p *Point) (string, int) {
+	if s.Rounds > 0 && rounds >= s.Rounds {
+		return "rounds", StatusLimit
+	}
+	if d, err := time.ParseDuration(s.After); err == nil && elapsed >= d {
+		return "after", StatusLimit
+	}
+	if p == nil {
+		return "", 0
+	}
+	switch {
+	case s.Extinct && p.Orgs == 0:
+		return "extinct", StatusExtinct
+	case s.Species > 0 && p.Largest > s.Species:
+		return "species", StatusSpecies
+	case s.Diversity > 0 && p.Diversity < s.Diversity:
+		return "diversity", StatusDiversity
+	}
+	return "", 0
+}
+
+// CensusCounts summarizes a census.Census.
+type CensusCounts struct {
+	Count, CountAllTime       int
+	Distinct, DistinctAllTime int
+}
+
+// Counts returns the counts of c.
+func Counts(c census.Census) CensusCounts {
+	return CensusCounts{c.Count(), c.CountAllTime(), c.Distinct(), c.DistinctAllTime()}
+}
+
+// Summary describes the outcome of a run.
+type Summary struct {
+	Reason  string  // why the run stopped; see Stop.Check
+	Status  int     // the exit status for Reason
+	Rounds  int64   // rounds completed, if scheduled
+	Elapsed float64 // seconds the run took
+	Config  *Config // the run's parameters
+	Final   Point   // the world when the run stopped
+	Census  CensusCounts
+	Top     []Genome // the most numerous genomes when the run stopped
+	Series  []Point  // the world as sampled over the run
+}
+
+// Write writes s to filename as indented JSON, replacing it, or to the
+// standard output if filename is "-".
+func (s *Summary) Write(filename string) error {
+	b, err := json.MarshalIndent(s, "", "    ")
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	if filename == "-" {
+		_, err = os.Stdout.Write(b)
+		return err
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReadSummary reads a Summary written by Write from filename.
+func ReadSummary(filename string) (*Summary, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	s := &Summary{}
+	if err := json.NewDecoder(f).Decode(s); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return s, nil
+}
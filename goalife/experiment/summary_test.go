@@ -0,0 +1,104 @@
+package experiment
+
+import "io/ioutil"
+import "math"
+import "os"
+import "path"
+import "reflect"
+import "testing"
+import "time"
+
+import "github.com/dnesting/alife/goalife/grid2d"
+import "github.com/dnesting/alife/goalife/grid2d/food"
+import "github.com/dnesting/alife/goalife/grid2d/org"
+import "github.com/dnesting/alife/goalife/grid2d/org/cpu1"
+import "github.com/dnesting/alife/goalife/grid2d/org/cpu2"
+
+func TestObserve(t *testing.T) {
+	g := grid2d.New(10, 10, nil)
+	put := func(x int, driver interface{}) {
+		o := org.Random()
+		o.Driver = driver
+		g.Put(x, 0, o, grid2d.PutAlways)
+	}
+	put(0, &cpu1.Cpu{Code: cpu1.Bytecode{1, 2, 3}})
+	put(1, &cpu1.Cpu{Code: cpu1.Bytecode{1, 2, 3}})
+	put(2, &cpu1.Cpu{Code: cpu1.Bytecode{1, 2, 3}})
+	put(3, &cpu2.Cpu{Code: cpu2.Bytecode{1, 2}})
+	g.Put(4, 0, food.New(100), grid2d.PutAlways)
+
+	p, genomes := Observe(g)
+	if p.Orgs != 4 || p.Species != 2 || p.Largest != 3 {
+		t.Errorf("expected 4 orgs, 2 species, largest 3 got %+v", p)
+	}
+	diversity := -(0.75*math.Log(0.75) + 0.25*math.Log(0.25))
+	if math.Abs(p.Diversity-diversity) > 1e-9 {
+		t.Errorf("expected diversity %f got %f", diversity, p.Diversity)
+	}
+	if len(genomes) != 2 {
+		t.Fatalf("expected 2 genomes got %v", genomes)
+	}
+	if g := genomes[0]; g.Cpu != "cpu1" || g.Length != 3 || g.Count != 3 {
+		t.Errorf("expected 3 of a 3-byte cpu1 genome first got %+v", g)
+	}
+	if g := genomes[1]; g.Cpu != "cpu2" || g.Length != 2 || g.Count != 1 {
+		t.Errorf("expected 1 of a 2-byte cpu2 genome second got %+v", g)
+	}
+}
+
+func TestStopCheck(t *testing.T) {
+	cases := []struct {
+		stop    Stop
+		rounds  int64
+		elapsed time.Duration
+		p       *Point
+		reason  string
+		status  int
+	}{
+		{Stop{}, 1000, time.Hour, &Point{}, "", 0},
+		{Stop{Rounds: 10}, 9, 0, nil, "", 0},
+		{Stop{Rounds: 10}, 10, 0, nil, "rounds", StatusLimit},
+		{Stop{After: "1m"}, 0, time.Minute, nil, "after", StatusLimit},
+		{Stop{Extinct: true}, 0, 0, nil, "", 0},
+		{Stop{Extinct: true}, 0, 0, &Point{Orgs: 1}, "", 0},
+		{Stop{Extinct: true}, 0, 0, &Point{}, "extinct", StatusExtinct},
+		{Stop{Species: 5}, 0, 0, &Point{Largest: 5}, "", 0},
+		{Stop{Species: 5}, 0, 0, &Point{Largest: 6}, "species", StatusSpecies},
+		{Stop{Diversity: 1}, 0, 0, &Point{Diversity: 0.5}, "diversity", StatusDiversity},
+		{Stop{Rounds: 10, Extinct: true}, 10, 0, &Point{}, "rounds", StatusLimit},
+	}
+	for _, c := range cases {
+		reason, status := c.stop.Check(c.rounds, c.elapsed, c.p)
+		if reason != c.reason || status != c.status {
+			t.Errorf("%+v.Check(%d, %v, %+v) expected %q %d got %q %d", c.stop, c.rounds, c.elapsed, c.p, c.reason, c.status, reason, status)
+		}
+	}
+}
+
+func TestSummary(t *testing.T) {
+	dir, err := ioutil.TempDir("", "experiment")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	filename := path.Join(dir, "summary.json")
+
+	s := &Summary{
+		Reason: "extinct",
+		Status: StatusExtinct,
+		Rounds: 42,
+		Config: Default(),
+		Top:    []Genome{{"abc", "cpu1", 10, 3}},
+		Series: []Point{{Round: 42, Orgs: 3, Species: 1, Largest: 3}},
+	}
+	if err := s.Write(filename); err != nil {
+		t.Fatalf("error writing: %v", err)
+	}
+	got, err := ReadSummary(filename)
+	if err != nil {
+		t.Fatalf("error reading: %v", err)
+	}
+	if !reflect.DeepEqual(got, s) {
+		t.Errorf("expected %+v got %+v", s, got)
+	}
+}
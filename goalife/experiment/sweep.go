@@ -0,0 +1,175 @@
+package experiment
+
+import "bytes"
+import "encoding/json"
+import "fmt"
+import "strings"
+
+import "github.com/dnesting/alife/goalife/physics"
+
+// Param is a field of a Config varied across a sweep.
+type Param struct {
+	Path   string   // the field, such as Mutation.Rate or Physics.Costs.cpu1.Ops.Forward
+	Values []string // the values it takes, as JSON or else as bare strings
+}
+
+// ParseParam parses a Param given as path=value,value,...
+func ParseParam(s string) (Param, error) {
+	i := strings.Index(s, "=")
+	if i < 1 || i == len(s)-1 {
+		return Param{}, fmt.Errorf("expected path=value,... got %q", s)
+	}
+	return Param{s[:i], strings.Split(s[i+1:], ",")}, nil
+}
+
+// With returns a copy of c with the field at path set to value, which is
+// decoded as JSON, or taken as a string if it isn't valid JSON.  The path
+// names fields as they appear in a Config file, separated by dots.  If c
+// has no Physics and path sets one of its fields, the rest of the Physics
+// is the default.
+func (c *Config) With(path, value string) (*Config, error) {
+	base := *c
+	if strings.HasPrefix(path, "Physics.") {
+		p := physics.Default()
+		if base.Physics != nil {
+			cp := *base.Physics
+			p = &cp
+		}
+		// Give every opcode table an entry, so that a path into Costs can
+		// tell the table names, which contain dots, from what follows.
+		costs := make(map[string]map[string]int)
+		for _, name := range physics.Tables() {
+			costs[name] = make(map[string]int)
+		}
+		for name, t := range p.Costs {
+			costs[name] = t
+		}
+		p.Costs = costs
+		base.Physics = p
+	}
+	b, err := json.Marshal(&base)
+	if err != nil {
+		return nil, err
+	}
+	var tree map[string]interface{}
+	if err := decodeNumbers(b, &tree); err != nil {
+		return nil, err
+	}
+
+	var v interface{}
+	if err := decodeNumbers([]byte(value), &v); err != nil {
+		v = value
+	}
+	setPath(tree, strings.Split(path, "."), v)
+	prune(tree)
+
+	if b, err = json.Marshal(tree); err != nil {
+		return nil, err
+	}
+	nc := Default()
+	if err := Decode(bytes.NewReader(b), nc); err != nil {
+		return nil, fmt.Errorf("%s=%s: %v", path, value, err)
+	}
+	return nc, nil
+}
+
+// decodeNumbers decodes the JSON in b into v, keeping numbers as
+// json.Number so that large integers survive being encoded again.
+func decodeNumbers(b []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if dec.More() {
+		return fmt.Errorf("unexpected data after JSON value")
+	}
+	return nil
+}
+
+// setPath sets the value at the path given by segs in tree, creating
+// objects along the way.  Since some keys contain dots themselves, the
+// longest run of segments naming an existing key is followed.
+func setPath(tree map[string]interface{}, segs []string, v interface{}) {
+	for len(segs) > 1 {
+		n := len(segs) - 1
+		for ; n > 1; n-- {
+			if _, ok := tree[strings.Join(segs[:n], ".")]; ok {
+				break
+			}
+		}
+		key := strings.Join(segs[:n], ".")
+		sub, ok := tree[key].(map[string]interface{})
+		if !ok {
+			sub = make(map[string]interface{})
+			tree[key] = sub
+		}
+		tree, segs = sub, segs[n:]
+	}
+	tree[segs[0]] = v
+}
+
+// prune removes the empty objects from tree.
+func prune(tree map[string]interface{}) {
+	for k, v := range tree {
+		if sub, ok := v.(map[string]interface{}); ok {
+			if prune(sub); len(sub) == 0 {
+				delete(tree, k)
+			}
+		}
+	}
+}
+
+// Run is a single run of a sweep.
+type Run struct {
+	Index     int      // the run's position in the sweep, from 0
+	Replicate int      // which replicate of its parameter values this is, from 0
+	Values    []string // the value of each Param
+	Config    *Config
+}
+
+// Sweep returns the runs of every combination of the values of params
+// applied to base, each repeated replicates times.  The runs are given
+// distinct seeds, counting up from seed.  Every run must be given a Stop
+// condition, since it would otherwise never end.
+func Sweep(base *Config, params []Param, replicates int, seed int64) ([]Run, error) {
+	if replicates < 1 {
+		return nil, fmt.Errorf("invalid number of replicates %d", replicates)
+	}
+	combos := [][]string{nil}
+	for _, p := range params {
+		if len(p.Values) == 0 {
+			return nil, fmt.Errorf("%s: no values", p.Path)
+		}
+		var next [][]string
+		for _, combo := range combos {
+			for _, v := range p.Values {
+				next = append(next, append(append([]string(nil), combo...), v))
+			}
+		}
+		combos = next
+	}
+
+	var runs []Run
+	for _, combo := range combos {
+		c := base
+		for i, v := range combo {
+			var err error
+			if c, err = c.With(params[i].Path, v); err != nil {
+				return nil, err
+			}
+		}
+		if err := c.Check(); err != nil {
+			return nil, fmt.Errorf("%s: %v", strings.Join(combo, ","), err)
+		}
+		if !c.Stop.Any() {
+			return nil, fmt.Errorf("no Stop condition given, so runs would never end")
+		}
+		for r := 0; r < replicates; r++ {
+			rc := *c
+			rc.Seed = seed + int64(len(runs))
+			runs = append(runs, Run{len(runs), r, combo, &rc})
+		}
+	}
+	return runs, nil
+}
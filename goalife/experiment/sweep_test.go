@@ -0,0 +1,79 @@
+package experiment
+
+import "reflect"
+import "testing"
+
+import "github.com/dnesting/alife/goalife/physics"
+
+func TestWith(t *testing.T) {
+	c := Default()
+	c, err := c.With("World.Topology", "walled")
+	if err != nil {
+		t.Fatalf("error setting World.Topology: %v", err)
+	}
+	if c, err = c.With("Mutation.Rate", "0.5"); err != nil {
+		t.Fatalf("error setting Mutation.Rate: %v", err)
+	}
+	if c, err = c.With("Physics.Costs.cpu1.Ops.Forward", "20"); err != nil {
+		t.Fatalf("error setting Physics.Costs.cpu1.Ops.Forward: %v", err)
+	}
+	if c, err = c.With("Physics.Costs.cpu1.Ops.Eat", "5"); err != nil {
+		t.Fatalf("error setting Physics.Costs.cpu1.Ops.Eat: %v", err)
+	}
+
+	expected := Default()
+	expected.World.Topology = "walled"
+	expected.Mutation.Rate = 0.5
+	expected.Physics = physics.Default()
+	expected.Physics.Costs = map[string]map[string]int{"cpu1.Ops": {"Forward": 20, "Eat": 5}}
+	if !reflect.DeepEqual(c, expected) {
+		t.Errorf("expected %+v got %+v", expected, c)
+	}
+
+	for _, bad := range [][2]string{
+		{"Wrold.Width", "10"},
+		{"World.Width", "wide"},
+		{"Physics.MoveCots", "1"},
+	} {
+		if _, err := Default().With(bad[0], bad[1]); err == nil {
+			t.Errorf("expected an error setting %s=%s", bad[0], bad[1])
+		}
+	}
+}
+
+func TestSweep(t *testing.T) {
+	base := Default()
+	base.Stop.After = "1m"
+	params := []Param{
+		{"Mutation.Rate", []string{"0.1", "0.2"}},
+		{"World.Width", []string{"10", "20", "30"}},
+	}
+	runs, err := Sweep(base, params, 2, 100)
+	if err != nil {
+		t.Fatalf("error sweeping: %v", err)
+	}
+	if len(runs) != 12 {
+		t.Fatalf("expected 12 runs got %d", len(runs))
+	}
+	seeds := make(map[int64]bool)
+	for i, r := range runs {
+		if r.Index != i || r.Replicate != i%2 {
+			t.Errorf("expected run %d replicate %d got %d %d", i, i%2, r.Index, r.Replicate)
+		}
+		seeds[r.Config.Seed] = true
+	}
+	if len(seeds) != 12 {
+		t.Errorf("expected 12 distinct seeds got %d", len(seeds))
+	}
+	r := runs[9]
+	if !reflect.DeepEqual(r.Values, []string{"0.2", "20"}) || r.Config.Mutation.Rate != 0.2 || r.Config.World.Width != 20 {
+		t.Errorf("expected Mutation.Rate 0.2 and World.Width 20 got %v %+v", r.Values, r.Config)
+	}
+
+	if _, err := Sweep(Default(), params, 1, 1); err == nil {
+		t.Errorf("expected an error sweeping without a Stop condition")
+	}
+	if _, err := Sweep(base, []Param{{"World.Width", []string{"0"}}}, 1, 1); err == nil {
+		t.Errorf("expected an error sweeping an invalid Config")
+	}
+}
@@ -1,13 +1,69 @@
 // Package autosave provides a method for saving and storing a grid2d.
+// A Dir keeps a history of timestamped snapshots, thinned over time.
+//
+// Each snapshot begins with a schema.Header describing the world it holds
+// (its size, topology, geometry and physics) and the opcode tables in use,
+// so that Restore can refuse snapshots it would otherwise misinterpret.
 package autosave
 
-import "encoding/gob"
+import "fmt"
 import "io/ioutil"
 import "os"
 import "path"
 import "time"
 
 import "github.com/dnesting/alife/goalife/grid2d"
+import "github.com/dnesting/alife/goalife/physics"
+import "github.com/dnesting/alife/goalife/util/schema"
+
+// Magic identifies snapshots written by Save.
+const Magic = "goalife/autosave"
+
+// header returns the Header describing a snapshot of g.
+func header(g grid2d.Grid) schema.Header {
+	h := schema.New(Magic)
+	width, height := g.Extents()
+	h.Params = map[string]string{
+		"width":    fmt.Sprint(width),
+		"height":   fmt.Sprint(height),
+		"topology": fmt.Sprint(g.Topology()),
+		"geometry": fmt.Sprint(g.Geometry()),
+		"physics":  physics.Current().String(),
+	}
+	return h
+}
+
+// Physics returns the physics.Physics in effect when the snapshot with
+// Header h was saved, or nil if it was saved without one.
+func Physics(h schema.Header) (*physics.Physics, error) {
+	s, ok := h.Params["physics"]
+	if !ok {
+		return nil, nil
+	}
+	return physics.Parse(s)
+}
+
+// NewGrid returns an empty Grid with the topology and geometry described by
+// h, suitable for restoring the snapshot h was read from.  Snapshots saved
+// without them are assumed to be square tori.
+func NewGrid(h schema.Header) (grid2d.Grid, error) {
+	topo, geom := grid2d.Torus, grid2d.Square
+	var err error
+	if name, ok := h.Params["topology"]; ok {
+		if topo, err = grid2d.TopologyByName(name); err != nil {
+			return nil, err
+		}
+	}
+	if name, ok := h.Params["geometry"]; ok {
+		if geom, err = grid2d.GeometryByName(name); err != nil {
+			return nil, err
+		}
+	}
+	if geom == grid2d.Hex {
+		return grid2d.NewHex(0, 0, topo, nil), nil
+	}
+	return grid2d.NewWithTopology(0, 0, topo, nil), nil
+}
 
 // Save writes g to filename.
 func Save(filename string, g grid2d.Grid) error {
@@ -22,7 +78,11 @@ func Save(filename string, g grid2d.Grid) error {
 	}
 	defer f.Close()
 
-	enc := gob.NewEncoder(f)
+	enc, err := schema.Write(f, header(g))
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
 	if err := enc.Encode(g); err != nil {
 		os.Remove(f.Name())
 		return err
@@ -36,19 +96,63 @@ func Save(filename string, g grid2d.Grid) error {
 	return nil
 }
 
-// Restore restores the contents of g from filename.
+// ReadHeader reads the Header of the snapshot in filename.  Snapshots saved
+// without a header yield the schema.Legacy header, with no Params.
+func ReadHeader(filename string) (schema.Header, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return schema.Header{}, err
+	}
+	defer f.Close()
+	h, _, err := schema.Read(f, Magic)
+	return h, err
+}
+
+// Restore restores the contents of g from filename.  Returns a
+// *schema.IncompatibleError if the snapshot was saved with a different
+// opcode table, topology or geometry than g uses.
 func Restore(filename string, g grid2d.Grid) error {
+	_, err := restore(filename, g, func(h schema.Header) error {
+		return h.Check(Magic)
+	})
+	return err
+}
+
+// Load restores the contents of g from filename like Restore, but without
+// checking the opcode tables the snapshot was saved with, and returns the
+// snapshot's Header.  Use this to migrate a snapshot to the current tables.
+func Load(filename string, g grid2d.Grid) (schema.Header, error) {
+	return restore(filename, g, func(h schema.Header) error {
+		return h.CheckMagic(Magic)
+	})
+}
+
+// restore restores g from filename if check accepts the snapshot's Header
+// and the snapshot's topology and geometry match g's.
+func restore(filename string, g grid2d.Grid, check func(schema.Header) error) (schema.Header, error) {
 	f, err := os.Open(filename)
 	if err != nil {
-		return err
+		return schema.Header{}, err
 	}
 	defer f.Close()
 
-	dec := gob.NewDecoder(f)
+	h, dec, err := schema.Read(f, Magic)
+	if err != nil {
+		return h, err
+	}
+	if err := check(h); err != nil {
+		return h, err
+	}
+	if err := h.CheckParam("topology", fmt.Sprint(g.Topology())); err != nil {
+		return h, err
+	}
+	if err := h.CheckParam("geometry", fmt.Sprint(g.Geometry())); err != nil {
+		return h, err
+	}
 	if err := dec.Decode(g); err != nil {
-		return err
+		return h, err
 	}
-	return nil
+	return h, nil
 }
 
 // Loop calls Save every freq.  Stops saving when exit yields a value.
@@ -0,0 +1,233 @@
+package autosave
+
+import "errors"
+import "fmt"
+import "io/ioutil"
+import "os"
+import "path"
+import "sort"
+import "strings"
+import "time"
+
+import "github.com/dnesting/alife/goalife/grid2d"
+
+const (
+	snapshotPrefix = "snapshot-"
+	snapshotSuffix = ".dat"
+
+	// snapshotTimeFormat sorts in chronological order.
+	snapshotTimeFormat = "20060102-150405.000000000"
+)
+
+// Snapshot describes a snapshot saved in a Dir.
+type Snapshot struct {
+	Path string
+	Time time.Time
+}
+
+func (s Snapshot) String() string {
+	return fmt.Sprintf("%s (%s)", s.Path, s.Time.Local().Format(time.RFC3339))
+}
+
+// Thin describes a thinning rule for a Retention policy.  Among snapshots
+// older than After, at most one snapshot is kept for each period of length
+// Every.
+type Thin struct {
+	After time.Duration
+	Every time.Duration
+}
+
+// Retention describes which snapshots a Dir keeps.  The Keep most recent
+// snapshots are always kept.  Older snapshots are kept only if a Thin rule
+// keeps them.  When several rules apply to a snapshot, the one with the
+// greatest After is used.
+type Retention struct {
+	Keep int
+	Thin []Thin
+}
+
+// ParseThin parses a comma-separated list of thinning rules of the form
+// after:every, such as "0s:1m,1h:1h,24h:24h".
+func ParseThin(s string) ([]Thin, error) {
+	var rules []Thin
+	if s == "" {
+		return nil, nil
+	}
+	for _, r := range strings.Split(s, ",") {
+		parts := strings.Split(r, ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid thinning rule %q, expected after:every", r)
+		}
+		after, err := time.ParseDuration(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		every, err := time.ParseDuration(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		if every <= 0 {
+			return nil, fmt.Errorf("invalid thinning rule %q, every must be positive", r)
+		}
+		rules = append(rules, Thin{after, every})
+	}
+	return rules, nil
+}
+
+// Dir saves timestamped snapshots of a Grid into a directory, removing
+// older snapshots according to a Retention policy.
+type Dir struct {
+	Path      string
+	Retention Retention
+}
+
+// ErrNoSnapshot is returned by Select when no snapshot matches.
+var ErrNoSnapshot = errors.New("no matching snapshot")
+
+// Save writes a new snapshot of g, and then removes any snapshots no longer
+// retained.
+func (d *Dir) Save(g grid2d.Grid) (Snapshot, error) {
+	now := time.Now().UTC()
+	s := Snapshot{
+		Path: path.Join(d.Path, snapshotPrefix+now.Format(snapshotTimeFormat)+snapshotSuffix),
+		Time: now,
+	}
+	if err := Save(s.Path, g); err != nil {
+		return s, err
+	}
+	return s, d.Prune(now)
+}
+
+// List returns the snapshots in the directory, most recent first.
+func (d *Dir) List() ([]Snapshot, error) {
+	infos, err := ioutil.ReadDir(d.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var snaps []Snapshot
+	for _, fi := range infos {
+		name := fi.Name()
+		if !strings.HasPrefix(name, snapshotPrefix) || !strings.HasSuffix(name, snapshotSuffix) {
+			continue
+		}
+		ts := strings.TrimSuffix(strings.TrimPrefix(name, snapshotPrefix), snapshotSuffix)
+		t, err := time.Parse(snapshotTimeFormat, ts)
+		if err != nil {
+			continue
+		}
+		snaps = append(snaps, Snapshot{path.Join(d.Path, name), t})
+	}
+	sort.Sort(sort.Reverse(byTime(snaps)))
+	return snaps, nil
+}
+
+type byTime []Snapshot
+
+func (s byTime) Len() int           { return len(s) }
+func (s byTime) Less(i, j int) bool { return s[i].Time.Before(s[j].Time) }
+func (s byTime) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// retained returns the snapshots from snaps, sorted most recent first, that
+// r keeps as of now.
+func (r Retention) retained(snaps []Snapshot, now time.Time) []Snapshot {
+	var keep []Snapshot
+	kept := make(map[Thin]map[int64]bool)
+	// Walk from oldest to newest, so that the oldest snapshot in each period
+	// is the one kept, and keeps being kept as newer snapshots arrive.
+	for i := len(snaps) - 1; i >= 0; i-- {
+		s := snaps[i]
+		if i < r.Keep {
+			keep = append(keep, s)
+			continue
+		}
+		var rule *Thin
+		age := now.Sub(s.Time)
+		for j := range r.Thin {
+			if t := &r.Thin[j]; age >= t.After && (rule == nil || t.After > rule.After) {
+				rule = t
+			}
+		}
+		if rule == nil {
+			continue
+		}
+		period := s.Time.UnixNano() / int64(rule.Every)
+		if kept[*rule] == nil {
+			kept[*rule] = make(map[int64]bool)
+		}
+		if !kept[*rule][period] {
+			kept[*rule][period] = true
+			keep = append(keep, s)
+		}
+	}
+	sort.Sort(sort.Reverse(byTime(keep)))
+	return keep
+}
+
+// Prune removes the snapshots not retained as of now.
+func (d *Dir) Prune(now time.Time) error {
+	snaps, err := d.List()
+	if err != nil {
+		return err
+	}
+	keep := make(map[string]bool)
+	for _, s := range d.Retention.retained(snaps, now) {
+		keep[s.Path] = true
+	}
+	for _, s := range snaps {
+		if !keep[s.Path] {
+			if err := os.Remove(s.Path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Select chooses a snapshot.  spec may be "latest" for the most recent
+// snapshot, a time (in RFC 3339 format) to choose the most recent snapshot
+// taken at or before that time, or the name of a snapshot file.  Returns
+// ErrNoSnapshot if none matches.
+func (d *Dir) Select(spec string) (Snapshot, error) {
+	snaps, err := d.List()
+	if err != nil {
+		return Snapshot{}, err
+	}
+	if spec == "latest" {
+		if len(snaps) == 0 {
+			return Snapshot{}, ErrNoSnapshot
+		}
+		return snaps[0], nil
+	}
+	if t, err := time.Parse(time.RFC3339, spec); err == nil {
+		for _, s := range snaps {
+			if !s.Time.After(t) {
+				return s, nil
+			}
+		}
+		return Snapshot{}, ErrNoSnapshot
+	}
+	for _, s := range snaps {
+		if s.Path == spec || path.Base(s.Path) == spec {
+			return s, nil
+		}
+	}
+	return Snapshot{}, ErrNoSnapshot
+}
+
+// Loop calls Save every freq.  Stops saving when exit yields a value.
+func (d *Dir) Loop(g grid2d.Grid, freq time.Duration, exit <-chan bool) error {
+	ch := time.Tick(freq)
+	for {
+		select {
+		case <-ch:
+			if _, err := d.Save(g); err != nil {
+				return err
+			}
+		case <-exit:
+			return nil
+		}
+	}
+}
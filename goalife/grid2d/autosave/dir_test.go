@@ -0,0 +1,211 @@
+package autosave
+
+import "encoding/gob"
+import "io/ioutil"
+import "os"
+import "path"
+import "reflect"
+import "testing"
+import "time"
+
+import "github.com/dnesting/alife/goalife/grid2d"
+import "github.com/dnesting/alife/goalife/physics"
+import "github.com/dnesting/alife/goalife/util/schema"
+
+var epoch = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func tempDir(t *testing.T) *Dir {
+	p, err := ioutil.TempDir("", "autosave")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	return &Dir{Path: p}
+}
+
+// touch creates an empty snapshot in d taken at t.
+func touch(t *testing.T, d *Dir, at time.Time) {
+	name := path.Join(d.Path, snapshotPrefix+at.Format(snapshotTimeFormat)+snapshotSuffix)
+	if err := ioutil.WriteFile(name, nil, 0644); err != nil {
+		t.Fatalf("error creating %s: %v", name, err)
+	}
+}
+
+func times(t *testing.T, d *Dir) []time.Time {
+	snaps, err := d.List()
+	if err != nil {
+		t.Fatalf("error listing snapshots: %v", err)
+	}
+	var ts []time.Time
+	for _, s := range snaps {
+		ts = append(ts, s.Time)
+	}
+	return ts
+}
+
+func TestPrune(t *testing.T) {
+	d := tempDir(t)
+	defer os.RemoveAll(d.Path)
+	d.Retention = Retention{
+		Keep: 2,
+		Thin: []Thin{{After: 0, Every: time.Hour}, {After: 24 * time.Hour, Every: 24 * time.Hour}},
+	}
+
+	// Snapshots every 20 minutes for two days.
+	for m := 0; m < 48*60; m += 20 {
+		touch(t, d, epoch.Add(time.Duration(m)*time.Minute))
+	}
+	now := epoch.Add(48 * time.Hour)
+	if err := d.Prune(now); err != nil {
+		t.Fatalf("error pruning: %v", err)
+	}
+
+	// The two most recent, then the first in each hour for the last day, then
+	// the first in each day before that.
+	at := func(h, m int) time.Time {
+		return epoch.Add(time.Duration(h)*time.Hour + time.Duration(m)*time.Minute)
+	}
+	expected := []time.Time{at(47, 40), at(47, 20)}
+	for h := 47; h > 24; h-- {
+		expected = append(expected, at(h, 0))
+	}
+	// 24:00 is exactly a day old, so it is the first of its day, and 24:20
+	// is the first of its hour.
+	expected = append(expected, at(24, 20), at(24, 0), at(0, 0))
+	if got := times(t, d); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v got %v", expected, got)
+	}
+
+	// Once 24:20 is more than a day old, only the first of its day is kept.
+	if err := d.Prune(now.Add(30 * time.Minute)); err != nil {
+		t.Fatalf("error pruning: %v", err)
+	}
+	expected = append(expected[:len(expected)-3], at(24, 0), at(0, 0))
+	if got := times(t, d); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v got %v", expected, got)
+	}
+}
+
+func TestSelect(t *testing.T) {
+	d := tempDir(t)
+	defer os.RemoveAll(d.Path)
+	if _, err := d.Select("latest"); err != ErrNoSnapshot {
+		t.Errorf("expected ErrNoSnapshot from an empty dir, got %v", err)
+	}
+	for h := 0; h < 3; h++ {
+		touch(t, d, epoch.Add(time.Duration(h)*time.Hour))
+	}
+
+	cases := []struct {
+		spec     string
+		expected time.Time
+		err      error
+	}{
+		{"latest", epoch.Add(2 * time.Hour), nil},
+		{epoch.Add(90 * time.Minute).Format(time.RFC3339), epoch.Add(time.Hour), nil},
+		{snapshotPrefix + epoch.Format(snapshotTimeFormat) + snapshotSuffix, epoch, nil},
+		{epoch.Add(-time.Hour).Format(time.RFC3339), time.Time{}, ErrNoSnapshot},
+		{"nonexistent", time.Time{}, ErrNoSnapshot},
+	}
+	for _, c := range cases {
+		s, err := d.Select(c.spec)
+		if err != c.err {
+			t.Errorf("Select(%q) expected error %v got %v", c.spec, c.err, err)
+		} else if !s.Time.Equal(c.expected) {
+			t.Errorf("Select(%q) expected %v got %v", c.spec, c.expected, s.Time)
+		}
+	}
+}
+
+func TestSaveRestore(t *testing.T) {
+	d := tempDir(t)
+	defer os.RemoveAll(d.Path)
+	d.Retention.Keep = 1
+
+	g := grid2d.New(3, 3, nil)
+	g.Put(1, 2, 5, grid2d.PutAlways)
+	if _, err := d.Save(g); err != nil {
+		t.Fatalf("error saving: %v", err)
+	}
+	g.Put(2, 2, 6, grid2d.PutAlways)
+	s, err := d.Save(g)
+	if err != nil {
+		t.Fatalf("error saving: %v", err)
+	}
+	if snaps, _ := d.List(); len(snaps) != 1 || snaps[0] != s {
+		t.Errorf("expected only %v to be kept, got %v", s, snaps)
+	}
+
+	r := grid2d.New(0, 0, nil)
+	if err := Restore(s.Path, r); err != nil {
+		t.Fatalf("error restoring: %v", err)
+	}
+	var expected, got []grid2d.Point
+	g.Locations(&expected)
+	r.Locations(&got)
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v got %v", expected, got)
+	}
+}
+
+func TestRestoreIncompatible(t *testing.T) {
+	d := tempDir(t)
+	defer os.RemoveAll(d.Path)
+
+	g := grid2d.New(3, 3, nil)
+	g.Put(1, 2, 5, grid2d.PutAlways)
+	name := path.Join(d.Path, "square.dat")
+	if err := Save(name, g); err != nil {
+		t.Fatalf("error saving: %v", err)
+	}
+	h, err := ReadHeader(name)
+	if err != nil {
+		t.Fatalf("error reading header: %v", err)
+	}
+	if h.Params["geometry"] != "square" || h.Params["topology"] != "torus" {
+		t.Errorf("expected square torus params got %v", h.Params)
+	}
+	if p, err := Physics(h); err != nil || p.String() != physics.Current().String() {
+		t.Errorf("expected the current physics got %v (err=%v)", p, err)
+	}
+
+	if err := Restore(name, grid2d.NewHex(0, 0, grid2d.Torus, nil)); err == nil {
+		t.Errorf("expected an error restoring a square world into a hex grid")
+	} else if _, ok := err.(*schema.IncompatibleError); !ok {
+		t.Errorf("expected *schema.IncompatibleError got %v", err)
+	}
+	if err := Restore(name, grid2d.NewWithTopology(0, 0, grid2d.Walled, nil)); err == nil {
+		t.Errorf("expected an error restoring a torus world into a walled grid")
+	}
+}
+
+func TestRestoreLegacy(t *testing.T) {
+	d := tempDir(t)
+	defer os.RemoveAll(d.Path)
+
+	// Snapshots saved before headers were introduced hold only the grid.
+	g := grid2d.New(3, 3, nil)
+	g.Put(1, 2, 5, grid2d.PutAlways)
+	name := path.Join(d.Path, "legacy.dat")
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatalf("error creating %s: %v", name, err)
+	}
+	if err := gob.NewEncoder(f).Encode(g); err != nil {
+		t.Fatalf("error encoding: %v", err)
+	}
+	f.Close()
+
+	if h, err := ReadHeader(name); err != nil || h.Version != 0 {
+		t.Errorf("expected a version 0 header got %v (err=%v)", h, err)
+	}
+	r := grid2d.New(0, 0, nil)
+	if err := Restore(name, r); err != nil {
+		t.Fatalf("error restoring: %v", err)
+	}
+	var expected, got []grid2d.Point
+	g.Locations(&expected)
+	r.Locations(&got)
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v got %v", expected, got)
+	}
+}
@@ -0,0 +1,81 @@
+package grid2d
+
+// Kind describes the kinds of change an Update can represent.  Kinds may be
+// combined with | to describe several at once.
+type Kind uint
+
+const (
+	Added Kind = 1 << iota
+	Removed
+	Moved
+	Replaced
+
+	AllKinds = Added | Removed | Moved | Replaced
+)
+
+// Kind returns the kind of change u represents.
+func (u Update) Kind() Kind {
+	var k Kind
+	if u.IsAdd() {
+		k |= Added
+	}
+	if u.IsRemove() {
+		k |= Removed
+	}
+	if u.IsMove() {
+		k |= Moved
+	}
+	if u.IsReplace() {
+		k |= Replaced
+	}
+	return k
+}
+
+// Filter causes the subscriber to receive only those updates for which fn
+// returns true.  fn is invoked while the Grid is locked, so it must be quick
+// and must not interact with the Grid.  Multiple filters may be given, in
+// which case an update must satisfy all of them.
+func Filter(fn func(u Update) bool) SubscribeOption {
+	return func(s *subscription) {
+		s.filters = append(s.filters, fn)
+	}
+}
+
+// Kinds causes the subscriber to receive only updates of the given kinds.
+func Kinds(k Kind) SubscribeOption {
+	return Filter(func(u Update) bool {
+		return u.Kind()&k != 0
+	})
+}
+
+// Occupants causes the subscriber to receive only updates involving an
+// occupant for which fn returns true, either before or after the change.
+// For example, a subscriber interested in organisms would see an organism
+// being replaced by food.
+func Occupants(fn func(v interface{}) bool) SubscribeOption {
+	return Filter(func(u Update) bool {
+		return (u.Old != nil && fn(u.Old.V)) || (u.New != nil && fn(u.New.V))
+	})
+}
+
+// Region causes the subscriber to receive only updates to cells within the
+// width x height rectangle whose top-left corner is x,y.  An occupant moving
+// into or out of the region is included.
+func Region(x, y, width, height int) SubscribeOption {
+	in := func(p *Point) bool {
+		return p != nil && p.X >= x && p.X < x+width && p.Y >= y && p.Y < y+height
+	}
+	return Filter(func(u Update) bool {
+		return in(u.Old) || in(u.New)
+	})
+}
+
+// wants returns true if the subscriber should receive u.
+func (s *subscription) wants(u Update) bool {
+	for _, fn := range s.filters {
+		if !fn(u) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,89 @@
+package grid2d
+
+import "fmt"
+
+// Geometry describes the shape of the cells in a Grid, and in particular
+// which cells neighbor one another.  Directions are numbered from 0 (east),
+// proceeding around the cell with north (if present) at a lower number than
+// west.  Callers may pass any value for dir; it is interpreted modulo
+// Directions.
+type Geometry interface {
+	// Directions returns the number of directions leading to a neighboring cell.
+	Directions() int
+
+	// Delta returns the coordinates, relative to a cell, of the cell dist
+	// cells away in direction dir.
+	Delta(dir, dist int) (int, int)
+
+	// Arrow returns a rune depicting direction dir.
+	Arrow(dir int) rune
+}
+
+type geometry struct {
+	name   string
+	deltas [][2]int
+	arrows []rune
+}
+
+func (g *geometry) String() string { return g.name }
+
+func (g *geometry) Directions() int { return len(g.deltas) }
+
+func (g *geometry) dir(dir int) int {
+	dir %= len(g.deltas)
+	if dir < 0 {
+		dir += len(g.deltas)
+	}
+	return dir
+}
+
+func (g *geometry) Delta(dir, dist int) (int, int) {
+	d := g.deltas[g.dir(dir)]
+	return d[0] * dist, d[1] * dist
+}
+
+func (g *geometry) Arrow(dir int) rune {
+	return g.arrows[g.dir(dir)]
+}
+
+var (
+	// Square is the geometry of a grid of square cells, where each cell
+	// has 8 neighbors: the four cardinal compass directions and one in
+	// between each.
+	Square Geometry = &geometry{
+		name: "square",
+		deltas: [][2]int{
+			{1, 0}, {1, -1}, {0, -1}, {-1, -1},
+			{-1, 0}, {-1, 1}, {0, 1}, {1, 1},
+		},
+		arrows: []rune("→↗↑↖←↙↓↘"),
+	}
+
+	// Hex is the geometry of a grid of hexagonal cells, where each cell
+	// has 6 neighbors, all equidistant.  Cells are addressed with axial
+	// coordinates: x increases to the east, and y increases to the
+	// south-east, so each row is offset by half a cell from the one
+	// above it.
+	Hex Geometry = &geometry{
+		name: "hex",
+		deltas: [][2]int{
+			{1, 0}, {1, -1}, {0, -1},
+			{-1, 0}, {-1, 1}, {0, 1},
+		},
+		arrows: []rune("→↗↖←↙↘"),
+	}
+)
+
+// Geometries maps the names of the standard geometries to their values.
+var Geometries = map[string]Geometry{
+	"square": Square,
+	"hex":    Hex,
+}
+
+// GeometryByName returns the standard Geometry with the given name.
+func GeometryByName(name string) (Geometry, error) {
+	if g, ok := Geometries[name]; ok {
+		return g, nil
+	}
+	return nil, fmt.Errorf("unknown geometry %q", name)
+}
@@ -0,0 +1,82 @@
+package grid2d
+
+import "testing"
+
+func TestDelta(t *testing.T) {
+	cases := []struct {
+		geom             Geometry
+		dir, dist        int
+		expectX, expectY int
+	}{
+		{Square, 0, 1, 1, 0},
+		{Square, 3, 2, -2, -2},
+		{Square, 8, 1, 1, 0},
+		{Square, -1, 1, 1, 1},
+		{Hex, 1, 1, 1, -1},
+		{Hex, 4, 3, -3, 3},
+		{Hex, 6, 1, 1, 0},
+	}
+	for _, c := range cases {
+		x, y := c.geom.Delta(c.dir, c.dist)
+		if x != c.expectX || y != c.expectY {
+			t.Errorf("%v.Delta(%d, %d) expected (%d,%d) got (%d,%d)", c.geom, c.dir, c.dist, c.expectX, c.expectY, x, y)
+		}
+	}
+}
+
+func TestHexNeighbors(t *testing.T) {
+	g := NewHex(5, 5, Torus, nil)
+	if g.Geometry() != Hex {
+		t.Errorf("Geometry() should be Hex, got %v", g.Geometry())
+	}
+	_, l := g.Put(2, 2, 10, PutAlways)
+
+	// Every neighbor should be reachable in one step, and stepping back in
+	// the opposite direction should return to the original cell.
+	n := Hex.Directions()
+	for dir := 0; dir < n; dir++ {
+		dx, dy := Hex.Delta(dir, 1)
+		_, nl := l.Put(dx, dy, dir, PutAlways)
+		bx, by := Hex.Delta(dir+n/2, 1)
+		if back := nl.Get(bx, by); back != l {
+			t.Errorf("opposite of direction %d should lead back to %v, got %v", dir, l, back)
+		}
+	}
+	_, _, count := g.Locations(nil)
+	if count != n+1 {
+		t.Errorf("expected %d distinct neighbors plus the center, got %d occupants", n, count-1)
+	}
+}
+
+// isNeighbor returns true if x2,y2 is one step from x1,y1 in geom.
+func isNeighbor(geom Geometry, x1, y1, x2, y2 int) bool {
+	for dir := 0; dir < geom.Directions(); dir++ {
+		if dx, dy := geom.Delta(dir, 1); x1+dx == x2 && y1+dy == y2 {
+			return true
+		}
+	}
+	return false
+}
+
+func TestReflectiveNeighbors(t *testing.T) {
+	for _, geom := range []Geometry{Square, Hex} {
+		for width := 2; width <= 5; width++ {
+			for height := 2; height <= 5; height++ {
+				g := newGrid(width, height, geom, Reflective, nil)
+				for y := 0; y < height; y++ {
+					for x := 0; x < width; x++ {
+						for dir := 0; dir < geom.Directions(); dir++ {
+							dx, dy := geom.Delta(dir, 1)
+							x2, y2, ok := g.resolve(x+dx, y+dy)
+							if !ok || x2 < 0 || x2 >= width || y2 < 0 || y2 >= height {
+								t.Errorf("%v %dx%d: step %d from (%d,%d) resolved outside the grid to (%d,%d)", geom, width, height, dir, x, y, x2, y2)
+							} else if !isNeighbor(geom, x, y, x2, y2) {
+								t.Errorf("%v %dx%d: step %d from (%d,%d) should land on a neighbor, got (%d,%d)", geom, width, height, dir, x, y, x2, y2)
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+}
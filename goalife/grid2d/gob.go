@@ -2,11 +2,18 @@ package grid2d
 
 import "bytes"
 import "encoding/gob"
+import "fmt"
 
 type gobStruct struct {
 	Width  int
 	Height int
 	Points []Point
+	Seq    uint64
+
+	// Topology and Geometry name the shape of the saved world.  They are
+	// empty in worlds saved before they were recorded.
+	Topology string
+	Geometry string
 }
 
 var gobData gobStruct
@@ -14,9 +21,16 @@ var gobData gobStruct
 func (g *grid) GobEncode() ([]byte, error) {
 	var b bytes.Buffer
 	enc := gob.NewEncoder(&b)
-	width, height, _ := g.Locations(&gobData.Points)
+	g.mu.Lock()
+	width, height, _ := g.locationsLocked(&gobData.Points)
+	// No changes can be made while we hold g.mu, so the snapshot reflects
+	// exactly the updates up to and including Seq.
+	gobData.Seq = g.Seq()
+	g.mu.Unlock()
 	gobData.Width = width
 	gobData.Height = height
+	gobData.Topology = fmt.Sprint(g.topo)
+	gobData.Geometry = fmt.Sprint(g.geom)
 	if err := enc.Encode(gobData); err != nil {
 		return nil, err
 	}
@@ -30,9 +44,16 @@ func (g *grid) GobDecode(data []byte) error {
 	if err := dec.Decode(&gs); err != nil {
 		return err
 	}
+	if gs.Topology != "" && gs.Topology != fmt.Sprint(g.topo) {
+		return fmt.Errorf("grid2d: saved with topology %s, but grid has %s", gs.Topology, g.topo)
+	}
+	if gs.Geometry != "" && gs.Geometry != fmt.Sprint(g.geom) {
+		return fmt.Errorf("grid2d: saved with geometry %s, but grid has %s", gs.Geometry, g.geom)
+	}
 	g.Resize(gs.Width, gs.Height, nil)
 	for _, p := range gs.Points {
 		g.Put(p.X, p.Y, p.V, PutAlways)
 	}
+	g.setSeq(gs.Seq)
 	return nil
 }
@@ -3,10 +3,10 @@
 package grid2d
 
 import "fmt"
-import "math/rand"
 import "sync"
 
 import "github.com/dnesting/alife/goalife/log"
+import "github.com/dnesting/alife/goalife/util/rng"
 
 var Logger = log.Null()
 
@@ -36,6 +36,8 @@ type Point struct {
 // Grid is a 2D world that holds occupants at specific discrete coordinates.
 type Grid interface {
 	Extents() (int, int)
+	Topology() Topology
+	Geometry() Geometry
 	Get(x, y int) Locator
 	Put(x, y int, n interface{}, fn PutWhenFunc) (interface{}, Locator)
 	PutRandomly(n interface{}, fn PutWhenFunc) (interface{}, Locator)
@@ -45,32 +47,129 @@ type Grid interface {
 	Resize(width, height int, removedFn func(x, y int, o interface{}))
 	Wait()
 
-	Subscribe(ch chan<- []Update)
+	Seq() uint64
+	Subscribe(ch chan<- []Update, opts ...SubscribeOption)
 	Unsubscribe(ch chan<- []Update)
+	Dropped(ch chan<- []Update) int
 	CloseSubscribers()
 }
 
+// defaultTileSize is the width and height, in cells, of the square region of
+// the Grid protected by a single lock.
+const defaultTileSize = 32
+
+// The grid is divided into square tiles, each protected by its own lock, so
+// that operations on distant parts of the world can proceed concurrently.
+// Operations that involve two cells (such as a move) acquire the locks of
+// both tiles in order of their index to avoid deadlock.
+//
+// Operations that involve the entire grid (such as Locations and Resize)
+// instead acquire mu exclusively.  Every other operation holds mu shared
+// for its duration, and must acquire it before any tile locks.
 type grid struct {
-	sync.RWMutex
+	mu   sync.RWMutex
 	cond *sync.Cond
 	notifier
 
 	width, height int
+	topo          Topology
+	geom          Geometry
 	data          []*locator
+
+	tileSize  int
+	tilesWide int
+	tiles     []sync.Mutex
 }
 
-// New creates a Grid with the given extents.
+// New creates a Grid with the given extents, whose edges wrap around
+// (see Torus).
 //
 // If cond is provided, every world-mutating operation will call
 // cond.Wait to ensure events are synchronized.  This is useful to
 // synchronize updates with rendering.
 func New(width, height int, cond *sync.Cond) Grid {
-	return &grid{
-		cond:   cond,
-		width:  width,
-		height: height,
-		data:   make([]*locator, width*height),
+	return NewWithTopology(width, height, Torus, cond)
+}
+
+// NewWithTopology creates a Grid of square cells with the given extents,
+// whose edges behave as described by topo.  See New for a description of cond.
+func NewWithTopology(width, height int, topo Topology, cond *sync.Cond) Grid {
+	return newGrid(width, height, Square, topo, cond)
+}
+
+// NewHex creates a Grid of hexagonal cells with the given extents, whose
+// edges behave as described by topo.  Cells are addressed using axial
+// coordinates (see Hex), so a Hex grid is shaped like a parallelogram.
+// See New for a description of cond.
+func NewHex(width, height int, topo Topology, cond *sync.Cond) Grid {
+	return newGrid(width, height, Hex, topo, cond)
+}
+
+func newGrid(width, height int, geom Geometry, topo Topology, cond *sync.Cond) *grid {
+	g := &grid{
+		cond:     cond,
+		width:    width,
+		height:   height,
+		topo:     topo,
+		geom:     geom,
+		data:     make([]*locator, width*height),
+		tileSize: defaultTileSize,
+	}
+	g.makeTiles()
+	return g
+}
+
+// makeTiles allocates the tile locks for the grid's current extents.  The
+// caller must hold g.mu exclusively, or otherwise have sole access to g.
+func (g *grid) makeTiles() {
+	g.tilesWide = (g.width + g.tileSize - 1) / g.tileSize
+	tilesHigh := (g.height + g.tileSize - 1) / g.tileSize
+	g.tiles = make([]sync.Mutex, g.tilesWide*tilesHigh)
+}
+
+// setTileSize changes the size of the region of the grid protected by each
+// lock.
+func (g *grid) setTileSize(size int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.tileSize = size
+	g.makeTiles()
+}
+
+// tile returns the index of the tile containing x,y.
+func (g *grid) tile(x, y int) int {
+	return (y/g.tileSize)*g.tilesWide + x/g.tileSize
+}
+
+// tileLock records the tiles locked by lockTiles, for a later unlockTiles.
+type tileLock struct {
+	a, b int
+}
+
+// lockTiles locks tiles a and b, in order, and returns a tileLock that can
+// be passed to unlockTiles.  a and b may be the same tile.
+func (g *grid) lockTiles(a, b int) tileLock {
+	if a > b {
+		a, b = b, a
+	}
+	g.tiles[a].Lock()
+	if a != b {
+		g.tiles[b].Lock()
 	}
+	return tileLock{a, b}
+}
+
+func (g *grid) unlockTiles(tl tileLock) {
+	if tl.a != tl.b {
+		g.tiles[tl.b].Unlock()
+	}
+	g.tiles[tl.a].Unlock()
+}
+
+// lockCell locks the tile containing x,y.
+func (g *grid) lockCell(x, y int) tileLock {
+	t := g.tile(x, y)
+	return g.lockTiles(t, t)
 }
 
 func (g *grid) String() string {
@@ -79,11 +178,30 @@ func (g *grid) String() string {
 
 // Extents returns the size of the world.
 func (g *grid) Extents() (width int, height int) {
-	g.RLock()
-	defer g.RUnlock()
+	g.mu.RLock()
+	defer g.mu.RUnlock()
 	return g.width, g.height
 }
 
+// Topology returns the Topology describing the edges of the world.
+func (g *grid) Topology() Topology {
+	return g.topo
+}
+
+// Geometry returns the Geometry describing the cells of the world.
+func (g *grid) Geometry() Geometry {
+	return g.geom
+}
+
+// resolve maps x,y, which may lie outside of the grid, to a cell within it
+// as described by the grid's Topology.  Returns false if there is no such cell.
+func (g *grid) resolve(x, y int) (int, int, bool) {
+	if r, ok := g.topo.(geometryResolver); ok {
+		return r.resolveIn(g.geom, x, y, g.width, g.height)
+	}
+	return g.topo.Resolve(x, y, g.width, g.height)
+}
+
 // offset converts x,y coordinates to the g.data offset for that cell.
 func (g *grid) offset(x, y int) int {
 	if x < 0 || x > g.width || y < 0 || y > g.height {
@@ -95,14 +213,18 @@ func (g *grid) offset(x, y int) int {
 // Get retrieves the Locator for any occupant at x,y.  If the cell is
 // empty, returns nil.
 func (g *grid) Get(x, y int) Locator {
-	g.RLock()
-	defer g.RUnlock()
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	tl := g.lockCell(x, y)
+	defer g.unlockTiles(tl)
 	if loc := g.getLocked(x, y); loc != nil {
 		return loc
 	}
 	return nil
 }
 
+// getLocked returns the locator at x,y.  The caller must hold g.mu and the
+// lock for the tile containing x,y (or hold g.mu exclusively).
 func (g *grid) getLocked(x, y int) *locator {
 	return g.data[g.offset(x, y)]
 }
@@ -126,36 +248,45 @@ func (g *grid) Wait() {
 // Put places n at x,y when fn returns true.  Returns the existing occupant,
 // and a Locator instance that can be used to relate n to the grid in the future.
 func (g *grid) Put(x, y int, n interface{}, fn PutWhenFunc) (interface{}, Locator) {
-	g.Lock()
-	defer g.Unlock()
-	return g.putLockedWithNotify(x, y, n, fn)
+	g.mu.RLock()
+	tl := g.lockCell(x, y)
+	orig, loc := g.putLockedWithNotify(x, y, n, fn)
+	g.unlockTiles(tl)
+	g.mu.RUnlock()
+	g.flush()
+	return orig, loc
 }
 
 // PutRandomly places n at a random location in the grid.  Returns any occupant
 // that was replaced, and a Locator instance that can be used to relate n to the grid
 // in the future.  If no open cells are available, returns nil, nil.
 func (g *grid) PutRandomly(n interface{}, fn PutWhenFunc) (interface{}, Locator) {
-	g.Lock()
-	defer g.Unlock()
-
-	offsets := rand.Perm(len(g.data))
+	g.mu.RLock()
+	offsets := rng.Perm(len(g.data))
 	for _, offset := range offsets {
 		x, y := offset%g.width, offset/g.width
+		tl := g.lockCell(x, y)
 		orig, loc := g.putLockedWithNotify(x, y, n, fn)
+		g.unlockTiles(tl)
 		if loc != nil {
+			g.mu.RUnlock()
+			g.flush()
 			return orig, loc
 		}
 	}
+	g.mu.RUnlock()
 	return nil, nil
 }
 
+// putLockedWithNotify is putLocked, but also records notifications for the
+// change.  The caller must call g.flush after releasing its locks.
 func (g *grid) putLockedWithNotify(x, y int, n interface{}, fn PutWhenFunc) (interface{}, Locator) {
 	orig, loc := g.putLocked(x, y, n, fn)
 	if orig != nil && n == nil {
-		g.RecordRemove(x, y, orig)
+		g.recordRemove(x, y, orig)
 	}
 	if loc != nil {
-		g.RecordAdd(x, y, n)
+		g.recordAdd(x, y, n)
 		return orig, loc
 	}
 	return orig, nil
@@ -168,6 +299,8 @@ func shouldPut(fn PutWhenFunc, a, b interface{}) bool {
 	return fn(a, b)
 }
 
+// putLocked places n at x,y when fn returns true.  The caller must hold g.mu
+// and the lock for the tile containing x,y.
 func (g *grid) putLocked(x, y int, n interface{}, fn PutWhenFunc) (interface{}, *locator) {
 	Logger.Printf("%v.putLocked(%d,%d, %v)\n", g, x, y, n)
 	origLoc := g.getLocked(x, y)
@@ -187,26 +320,33 @@ func (g *grid) putLocked(x, y int, n interface{}, fn PutWhenFunc) (interface{},
 	return origValue, loc
 }
 
+// moveLocked moves the occupant at x1,y1 to x2,y2 when fn returns true.  The
+// caller must hold g.mu and the locks for the tiles containing both cells,
+// and call g.flush after releasing them.
 func (g *grid) moveLocked(x1, y1, x2, y2 int, fn PutWhenFunc) (interface{}, bool) {
 	Logger.Printf("%v.moveLocked(%d,%d, %d,%d)\n", g, x1, y1, x2, y2)
 	src := g.getLocked(x1, y1)
 	dst := g.getLocked(x2, y2)
+	if src == dst {
+		// The topology resolved the move back onto the same cell, which
+		// we consider to be occupied by the occupant itself.
+		return dst.Value(), false
+	}
 	if !shouldPut(fn, dst.Value(), src.Value()) {
 		return dst.Value(), false
 	}
 	dst.invalidate()
 	g.data[g.offset(x2, y2)] = src
 	g.data[g.offset(x1, y1)] = nil
-	src.x = x2
-	src.y = y2
-	g.RecordMove(x1, y1, x2, y2, src.v)
+	src.setPos(x2, y2)
+	g.recordMove(x1, y1, x2, y2, src.v)
 	return dst.Value(), true
 }
 
 // All returns the Locators for all occupants in the grid.
 func (g *grid) All() []Locator {
-	g.RLock()
-	defer g.RUnlock()
+	g.mu.Lock()
+	defer g.mu.Unlock()
 	var locs []Locator
 	for _, l := range g.data {
 		if l != nil {
@@ -220,8 +360,8 @@ func (g *grid) All() []Locator {
 // and height of the Grid at the time along with a count of the number of occupants
 // found.  Points may be nil if you just want to get a quick count.
 func (g *grid) Locations(points *[]Point) (width int, height int, count int) {
-	g.RLock()
-	defer g.RUnlock()
+	g.mu.Lock()
+	defer g.mu.Unlock()
 	return g.locationsLocked(points)
 }
 
@@ -235,7 +375,8 @@ func (g *grid) locationsLocked(points *[]Point) (width int, height int, count in
 	for _, l := range g.data {
 		if l != nil {
 			if points != nil {
-				*points = append(*points, Point{l.x, l.y, l.v})
+				x, y := l.pos()
+				*points = append(*points, Point{x, y, l.v})
 			}
 			count++
 		}
@@ -247,24 +388,27 @@ func (g *grid) locationsLocked(points *[]Point) (width int, height int, count in
 // outside of the resized Grid are passed individually to removedFn before being
 // discarded.
 func (g *grid) Resize(width, height int, removedFn func(x, y int, o interface{})) {
-	Logger.Printf("%g.Resize(%d,%d)\n", width, height)
-	g.Lock()
-	defer g.Unlock()
+	Logger.Printf("%v.Resize(%d,%d)\n", g, width, height)
+	g.mu.Lock()
+	defer g.flush()
+	defer g.mu.Unlock()
 
 	old := g.data
 	g.data = make([]*locator, width*height)
 	g.width = width
 	g.height = height
+	g.makeTiles()
 
 	for _, l := range old {
 		if l != nil {
-			if l.x >= width || l.y >= height {
+			if x, y := l.pos(); x >= width || y >= height {
 				if removedFn != nil {
-					removedFn(l.x, l.y, l.v)
+					removedFn(x, y, l.v)
 				}
-				g.RecordRemove(l.x, l.y, l.v)
+				l.invalidate()
+				g.recordRemove(x, y, l.v)
 			} else {
-				g.data[g.offset(l.x, l.y)] = l
+				g.data[g.offset(x, y)] = l
 			}
 		}
 	}
@@ -2,7 +2,11 @@ package grid2d
 
 import "bytes"
 import "encoding/gob"
+import "math/rand"
 import "reflect"
+import "runtime"
+import "sync"
+import "sync/atomic"
 import "testing"
 
 func TestBasic(t *testing.T) {
@@ -186,3 +190,137 @@ func TestGob(t *testing.T) {
 		t.Errorf("decoded grid has wrong contents, expected %v got %v", locs, locs2)
 	}
 }
+
+// moveRandomly moves each of locs in a random direction n times, checking
+// that every locator remains valid.
+func moveRandomly(t testing.TB, rnd *rand.Rand, locs []Locator, n int) {
+	for i := 0; i < n; i++ {
+		l := locs[i%len(locs)]
+		l.Get(1, 1)
+		dx, dy := Square.Delta(rnd.Intn(8), 1)
+		l.Move(dx, dy, PutWhenNil)
+		if !l.IsValid() {
+			t.Fatalf("locator %v invalidated by a move", l)
+		}
+	}
+}
+
+func TestConcurrentMoves(t *testing.T) {
+	g := newGrid(20, 20, Square, Torus, nil)
+	g.setTileSize(3)
+
+	const workers = 8
+	const perWorker = 10
+	var locs [workers][]Locator
+	for i := 0; i < workers*perWorker; i++ {
+		_, l := g.PutRandomly(i, PutWhenNil)
+		locs[i%workers] = append(locs[i%workers], l)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			moveRandomly(t, rand.New(rand.NewSource(int64(i))), locs[i], 2000)
+		}(i)
+	}
+	wg.Wait()
+
+	var points []Point
+	_, _, count := g.Locations(&points)
+	if count != workers*perWorker {
+		t.Errorf("concurrent moves should preserve every occupant, expected %d got %d", workers*perWorker, count)
+	}
+	for _, p := range points {
+		if l := g.Get(p.X, p.Y); l == nil || l.Value() != p.V {
+			t.Errorf("inconsistent grid at (%d,%d): expected %v got %v", p.X, p.Y, p.V, l)
+		}
+	}
+}
+
+// benchmarkMoves measures the throughput of organisms moving around a large
+// world concurrently, with each lock protecting tileSize x tileSize cells.
+// If subscribe is true, a subscriber receives every update.
+// benchmarkMoves measures occupants moving around a grid locked in tiles of
+// tileSize.  Per-tile locking can only pay off when moves run on several
+// cores at once, so compare the Tiled and SingleLock variants with -cpu set
+// to several values (e.g. -cpu 1,2,4,8) on a machine with that many cores.
+func benchmarkMoves(b *testing.B, tileSize int, subscribe bool) {
+	g := newGrid(1000, 1000, Square, Torus, nil)
+	g.setTileSize(tileSize)
+
+	const numOccupants = 5000
+	rnd := rand.New(rand.NewSource(1))
+	locs := make([]Locator, 0, numOccupants)
+	for i := 0; len(locs) < numOccupants; i++ {
+		if _, l := g.Put(rnd.Intn(1000), rnd.Intn(1000), i, PutWhenNil); l != nil {
+			locs = append(locs, l)
+		}
+	}
+
+	if subscribe {
+		ch := make(chan []Update)
+		g.Subscribe(ch)
+		go func() {
+			for range ch {
+			}
+		}()
+		defer g.CloseSubscribers()
+	}
+
+	// Each goroutine drives its own share of the occupants, as organisms
+	// would each drive themselves.
+	procs := runtime.GOMAXPROCS(0)
+	var next int32
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		id := int(atomic.AddInt32(&next, 1) - 1)
+		var mine []Locator
+		for i := id % procs; i < len(locs); i += procs {
+			mine = append(mine, locs[i])
+		}
+		rnd := rand.New(rand.NewSource(int64(id)))
+		for i := 0; pb.Next(); i++ {
+			l := mine[i%len(mine)]
+			l.Get(1, 1)
+			dx, dy := Square.Delta(rnd.Intn(8), 1)
+			l.Move(dx, dy, PutWhenNil)
+		}
+	})
+}
+
+// BenchmarkMovesSingleLock protects the entire world with a single lock,
+// equivalent to a grid-wide mutex.
+func BenchmarkMovesSingleLock(b *testing.B) {
+	benchmarkMoves(b, 1000, false)
+}
+
+func BenchmarkMovesTiled(b *testing.B) {
+	benchmarkMoves(b, defaultTileSize, false)
+}
+
+func BenchmarkMovesSingleLockSubscribed(b *testing.B) {
+	benchmarkMoves(b, 1000, true)
+}
+
+func BenchmarkMovesTiledSubscribed(b *testing.B) {
+	benchmarkMoves(b, defaultTileSize, true)
+}
+
+func TestUseAfterResize(t *testing.T) {
+	g := New(4, 4, nil)
+	_, l := g.Put(3, 3, 1, PutWhenNil)
+	g.Resize(2, 2, nil)
+	if l.IsValid() {
+		t.Errorf("locator removed by Resize should be invalid")
+	}
+	l.Remove() // should be a no-op
+
+	defer func() {
+		if r := recover(); r != "attempt to use an invalidated locator" {
+			t.Errorf("expected invalidated locator panic, got %v", r)
+		}
+	}()
+	l.Move(1, 0, PutWhenNil)
+}
@@ -0,0 +1,176 @@
+// Package journal records the changes made to a grid2d.Grid in an
+// append-only journal, and replays them to reconstruct the Grid as it was
+// after any change.
+//
+// A journal extends a snapshot of the Grid (such as one written by
+// grid2d/autosave), which records the sequence number of the last change it
+// reflects (see grid2d.Update).  Replaying a journal onto a Grid restored
+// from that snapshot reproduces the position of every occupant after any
+// later change.  Occupants are written as they were when the journal
+// received the change, and changes that don't produce an Update (such as an
+// organism's energy being spent) are not journaled, so replayed occupants
+// may differ from the originals in those respects.
+//
+// A journal consists of frames, each holding a batch of updates, written as
+// a uvarint length followed by the gob encoding of the batch.  Types of
+// occupants must be registered with gob.Register.
+package journal
+
+import "bufio"
+import "bytes"
+import "encoding/binary"
+import "encoding/gob"
+import "errors"
+import "io"
+
+import "github.com/dnesting/alife/goalife/grid2d"
+import "github.com/dnesting/alife/goalife/log"
+
+var Logger = log.Null()
+
+// Last can be passed to Replay to replay the entire journal.
+const Last = ^uint64(0)
+
+// ErrGap is returned by Replay when the journal is missing updates, such as
+// when it does not continue from the snapshot being replayed onto.
+var ErrGap = errors.New("journal is missing updates")
+
+// Write writes updates to w as a single frame.
+func Write(w io.Writer, updates []grid2d.Update) error {
+	var b bytes.Buffer
+	if err := gob.NewEncoder(&b).Encode(updates); err != nil {
+		return err
+	}
+	var size [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(size[:], uint64(b.Len()))
+	if _, err := w.Write(size[:n]); err != nil {
+		return err
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// strip returns a copy of u without the occupants Apply doesn't need, which
+// are those removed or moved.
+func strip(u grid2d.Update) grid2d.Update {
+	if u.Old != nil {
+		u.Old = &grid2d.Point{X: u.Old.X, Y: u.Old.Y}
+	}
+	if u.IsMove() {
+		u.New = &grid2d.Point{X: u.New.X, Y: u.New.Y}
+	}
+	return u
+}
+
+// Record writes each batch of updates received from ch to w until ch is
+// closed.  ch should be subscribed using grid2d.Unbounded, so that no
+// updates are dropped and writing never delays changes to the Grid.  To
+// save space, only occupants being added or replacing another are written.
+// If a write fails, Record continues to receive from ch, but writes nothing
+// more, and returns the error once ch is closed.
+func Record(w io.Writer, ch <-chan []grid2d.Update) error {
+	var err error
+	for updates := range ch {
+		if err == nil {
+			stripped := make([]grid2d.Update, len(updates))
+			for i, u := range updates {
+				stripped[i] = strip(u)
+			}
+			if err = Write(w, stripped); err != nil {
+				Logger.Printf("journal: %v\n", err)
+			}
+		}
+	}
+	return err
+}
+
+// Reader reads the updates in a journal.
+type Reader struct {
+	r     *bufio.Reader
+	batch []grid2d.Update
+}
+
+// NewReader creates a Reader reading the journal from r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// Next returns the next update in the journal.  Returns io.EOF when no more
+// updates remain.  A partially-written final frame, such as one left by a
+// crash, is treated as the end of the journal.
+func (r *Reader) Next() (grid2d.Update, error) {
+	for len(r.batch) == 0 {
+		size, err := binary.ReadUvarint(r.r)
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		if err != nil {
+			return grid2d.Update{}, err
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(r.r, data); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				err = io.EOF
+			}
+			return grid2d.Update{}, err
+		}
+		r.batch = nil
+		if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&r.batch); err != nil {
+			return grid2d.Update{}, err
+		}
+	}
+	u := r.batch[0]
+	r.batch = r.batch[1:]
+	return u, nil
+}
+
+// Apply makes the change described by u to g.  If u is a move without the
+// occupant being moved, the occupant found in g is moved.
+func Apply(g grid2d.Grid, u grid2d.Update) {
+	switch {
+	case u.IsAdd():
+		g.Put(u.New.X, u.New.Y, u.New.V, grid2d.PutAlways)
+	case u.IsRemove():
+		g.Remove(u.Old.X, u.Old.Y)
+	case u.IsMove():
+		v := g.Remove(u.Old.X, u.Old.Y)
+		if u.New.V != nil {
+			v = u.New.V
+		}
+		g.Put(u.New.X, u.New.Y, v, grid2d.PutAlways)
+	case u.IsReplace():
+		g.Put(u.New.X, u.New.Y, u.New.V, grid2d.PutAlways)
+	}
+}
+
+// Replay applies the updates read from r to g, starting with the update
+// following sequence number from and ending with sequence number to.  g
+// would normally have been restored from a snapshot, with from being its
+// Seq.  Returns the sequence number of the last update applied.  Returns
+// ErrGap if the journal does not contain every update in that range, up to
+// the end of the journal.
+//
+// Since applying updates changes g, g.Seq will not equal the sequence
+// number returned.
+func Replay(g grid2d.Grid, r io.Reader, from, to uint64) (uint64, error) {
+	jr := NewReader(r)
+	last := from
+	for last < to {
+		u, err := jr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return last, err
+		}
+		if u.Seq <= last {
+			continue
+		}
+		if u.Seq != last+1 {
+			return last, ErrGap
+		}
+		Apply(g, u)
+		last = u.Seq
+	}
+	return last, nil
+}
@@ -0,0 +1,96 @@
+package journal
+
+import "bytes"
+import "encoding/gob"
+import "reflect"
+import "testing"
+
+import "github.com/dnesting/alife/goalife/grid2d"
+
+func locations(g grid2d.Grid) []grid2d.Point {
+	var points []grid2d.Point
+	g.Locations(&points)
+	return points
+}
+
+func TestReplay(t *testing.T) {
+	g := grid2d.New(4, 4, nil)
+	g.Put(0, 0, 1, grid2d.PutAlways)
+
+	var snapshot bytes.Buffer
+	if err := gob.NewEncoder(&snapshot).Encode(g); err != nil {
+		t.Fatalf("error encoding snapshot: %v", err)
+	}
+	from := g.Seq()
+
+	var journal bytes.Buffer
+	ch := make(chan []grid2d.Update)
+	g.Subscribe(ch, grid2d.Unbounded())
+	done := make(chan error)
+	go func() { done <- Record(&journal, ch) }()
+
+	// Record the expected world after each change.
+	expected := map[uint64][]grid2d.Point{from: locations(g)}
+	change := func(fn func()) {
+		fn()
+		expected[g.Seq()] = locations(g)
+	}
+	var l grid2d.Locator
+	change(func() { _, l = g.Put(1, 1, 2, grid2d.PutAlways) })
+	change(func() { l.Move(1, 0, grid2d.PutWhenNil) })
+	change(func() { l = l.Replace(3) })
+	change(func() { g.Remove(0, 0) })
+	change(func() { g.Put(3, 3, 4, grid2d.PutAlways) })
+
+	g.CloseSubscribers()
+	if err := <-done; err != nil {
+		t.Fatalf("error recording journal: %v", err)
+	}
+
+	for seq, points := range expected {
+		r := grid2d.New(0, 0, nil)
+		if err := gob.NewDecoder(bytes.NewReader(snapshot.Bytes())).Decode(r); err != nil {
+			t.Fatalf("error decoding snapshot: %v", err)
+		}
+		if r.Seq() != from {
+			t.Errorf("restored snapshot should have seq %d, got %d", from, r.Seq())
+		}
+		last, err := Replay(r, bytes.NewReader(journal.Bytes()), from, seq)
+		if err != nil {
+			t.Errorf("error replaying to %d: %v", seq, err)
+		}
+		if last != seq {
+			t.Errorf("expected replay to end at %d got %d", seq, last)
+		}
+		if got := locations(r); !reflect.DeepEqual(got, points) {
+			t.Errorf("replay to %d expected %v got %v", seq, points, got)
+		}
+	}
+}
+
+func TestReplayGap(t *testing.T) {
+	var journal bytes.Buffer
+	Write(&journal, []grid2d.Update{
+		{Seq: 5, New: &grid2d.Point{X: 0, Y: 0, V: 1}},
+	})
+	g := grid2d.New(2, 2, nil)
+	if _, err := Replay(g, &journal, 3, Last); err != ErrGap {
+		t.Errorf("expected ErrGap got %v", err)
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	var journal bytes.Buffer
+	Write(&journal, []grid2d.Update{{Seq: 1, New: &grid2d.Point{X: 0, Y: 0, V: 1}}})
+	Write(&journal, []grid2d.Update{{Seq: 2, New: &grid2d.Point{X: 1, Y: 0, V: 2}}})
+	data := journal.Bytes()[:journal.Len()-3]
+
+	g := grid2d.New(2, 2, nil)
+	last, err := Replay(g, bytes.NewReader(data), 0, Last)
+	if err != nil {
+		t.Errorf("expected a truncated frame to be ignored, got %v", err)
+	}
+	if last != 1 {
+		t.Errorf("expected replay to end at 1 got %d", last)
+	}
+}
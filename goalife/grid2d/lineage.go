@@ -0,0 +1,41 @@
+package grid2d
+
+import "time"
+
+import "github.com/dnesting/alife/goalife/lineage"
+
+// ScanForLineage adds each occupant of g for which keyFn returns a
+// lineage.Descendant to t, as born now.  This is used to populate a
+// lineage.Tree from a pre-existing Grid.
+func ScanForLineage(t *lineage.Tree, g Grid, keyFn func(interface{}) lineage.Descendant) error {
+	var locs []Point
+	g.Locations(&locs)
+
+	now := time.Now()
+	for _, p := range locs {
+		if d := keyFn(p.V); d != nil {
+			if _, err := t.AddDescendant(now, d); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// WatchForLineage monitors ch and adds each occupant added to the Grid for
+// which keyFn returns a lineage.Descendant to t.  Returns the first error
+// adding to t.
+func WatchForLineage(t *lineage.Tree, ch <-chan []Update, keyFn func(interface{}) lineage.Descendant) error {
+	for updates := range ch {
+		for _, u := range updates {
+			if u.IsAdd() || u.IsReplace() {
+				if d := keyFn(u.New.V); d != nil {
+					if _, err := t.AddDescendant(time.Now(), d); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -7,6 +7,7 @@ package grid2d
 import "fmt"
 import "os"
 import "runtime"
+import "sync/atomic"
 
 // Locator is a handle that allows operations on the Grid relative to an
 // occupant's position without requiring specific knowledge of the occupant's
@@ -20,6 +21,8 @@ type Locator interface {
 	RemoveWithPlaceholder(v interface{})
 	IsValid() bool
 	Value() interface{}
+	Geometry() Geometry
+	Position() (x, y int)
 }
 
 // UsesLocator can be implemented by occupant values if they want to be given a
@@ -29,9 +32,12 @@ type UsesLocator interface {
 	UseLocator(loc Locator)
 }
 
+// The position of a locator is only changed while holding the locks for the
+// tiles containing both its old and new positions, but it may be read without
+// any locks in order to determine which tile to lock, so we access it atomically.
 type locator struct {
 	w        *grid
-	x, y     int
+	x, y     int32
 	v        interface{}
 	invalid  bool
 	invStack []byte
@@ -40,19 +46,32 @@ type locator struct {
 func newLocator(w *grid, x, y int, v interface{}) *locator {
 	return &locator{
 		w:        w,
-		x:        x,
-		y:        y,
+		x:        int32(x),
+		y:        int32(y),
 		v:        v,
 		invStack: make([]byte, 8192),
 	}
 }
 
+// pos returns the location of the locator.
+func (l *locator) pos() (int, int) {
+	return int(atomic.LoadInt32(&l.x)), int(atomic.LoadInt32(&l.y))
+}
+
+// setPos changes the location of the locator.  The caller must hold the locks
+// for the tiles containing both its old and new location.
+func (l *locator) setPos(x, y int) {
+	atomic.StoreInt32(&l.x, int32(x))
+	atomic.StoreInt32(&l.y, int32(y))
+}
+
 func (l *locator) String() string {
 	invalid := ""
 	if l.invalid {
 		invalid = " invalid"
 	}
-	return fmt.Sprintf("[%d,%d%s]", l.x, l.y, invalid)
+	x, y := l.pos()
+	return fmt.Sprintf("[%d,%d%s]", x, y, invalid)
 }
 
 func (l *locator) checkValid() {
@@ -65,35 +84,70 @@ func (l *locator) checkValid() {
 }
 
 func (l *locator) checkLocationInvariant() {
-	found := l.w.getLocked(l.x, l.y)
+	x, y := l.pos()
+	found := l.w.getLocked(x, y)
 	if l != found {
-		panic(fmt.Sprintf("inconsistent location: %v versus %v found at (%d,%d)", l, found, l.x, l.y))
+		panic(fmt.Sprintf("inconsistent location: %v versus %v found at (%d,%d)", l, found, x, y))
 	}
 }
 
-// delta returns the absolute coordinates given coordinates relative to the
-// locator.
-func (l *locator) delta(dx, dy int) (int, int) {
-	x := (l.x + dx) % l.w.width
-	y := (l.y + dy) % l.w.height
-	if x < 0 {
-		x += l.w.width
+// delta returns the absolute coordinates given coordinates relative to x,y,
+// as resolved by the Grid's Topology.  Returns false if there is no such cell.
+func (l *locator) delta(x, y, dx, dy int) (int, int, bool) {
+	return l.w.resolve(x+dx, y+dy)
+}
+
+// outside returns true if the locator lies outside of the Grid, which happens
+// when it was removed by Resize.  The caller must hold l.w.mu.
+func (l *locator) outside() bool {
+	x, y := l.pos()
+	return x >= l.w.width || y >= l.w.height
+}
+
+// lockWith locks the tile containing the locator, along with the tile containing
+// the cell dx,dy relative to it.  Returns the locator's position, the position
+// of the relative cell, whether the relative cell exists, and the tileLock to
+// pass to unlockTiles.  The caller must hold l.w.mu.  It is illegal to call this
+// method on a locator removed by Resize.
+func (l *locator) lockWith(dx, dy int) (x, y, x2, y2 int, ok bool, tl tileLock) {
+	if l.outside() {
+		// Resize invalidated the locator while holding l.w.mu exclusively,
+		// so checkValid will report where.
+		l.checkValid()
 	}
-	if y < 0 {
-		y += l.w.height
+	for {
+		x, y = l.pos()
+		x2, y2, ok = l.delta(x, y, dx, dy)
+		t := l.w.tile(x, y)
+		t2 := t
+		if ok {
+			t2 = l.w.tile(x2, y2)
+		}
+		tl = l.w.lockTiles(t, t2)
+
+		// The locator may have moved before we acquired the lock.
+		if cx, cy := l.pos(); cx == x && cy == y {
+			return
+		}
+		l.w.unlockTiles(tl)
 	}
-	return x, y
 }
 
 // Get retrieves the Locator of an occupant in a cell relative to the one currently
 // referenced by this Locator.  loc.Get(0, 0) will thus return loc.  It is illegal to
-// call this method on an invalidated Locator.
+// call this method on an invalidated Locator.  Returns nil if the cell lies
+// beyond the edge of a walled Grid.
 func (l *locator) Get(dx, dy int) Locator {
-	l.w.RLock()
-	defer l.w.RUnlock()
+	l.w.mu.RLock()
+	defer l.w.mu.RUnlock()
+	_, _, x, y, ok, tl := l.lockWith(dx, dy)
+	defer l.w.unlockTiles(tl)
 	l.checkValid()
 	l.checkLocationInvariant()
-	if loc := l.w.getLocked(l.delta(dx, dy)); loc != nil {
+	if !ok {
+		return nil
+	}
+	if loc := l.w.getLocked(x, y); loc != nil {
 		return loc
 	}
 	return nil
@@ -101,68 +155,94 @@ func (l *locator) Get(dx, dy int) Locator {
 
 // Put places n in the Grid at the relative location dx,dy when fn returns true.
 // Returns the occupant replaced, if any, and the Locator of the newly placed
-// occupant, if it was placed.  It is illegal to call this method on an invalidated Locator.
+// occupant, if it was placed.  If the cell lies beyond the edge of a walled
+// Grid, returns nil, nil.  It is illegal to call this method on an invalidated Locator.
 func (l *locator) Put(dx, dy int, n interface{}, fn PutWhenFunc) (interface{}, Locator) {
-	l.w.Lock()
+	l.w.mu.RLock()
+	_, _, x, y, ok, tl := l.lockWith(dx, dy)
+	unlock := func() {
+		l.w.unlockTiles(tl)
+		l.w.mu.RUnlock()
+	}
 	l.checkValid()
 	l.checkLocationInvariant()
-	x, y := l.delta(dx, dy)
+	if !ok {
+		unlock()
+		return nil, nil
+	}
 	orig, loc := l.w.putLocked(x, y, n, fn)
 	if loc != nil {
-		l.w.RecordAdd(x, y, n)
-		l.w.Unlock()
+		l.w.recordAdd(x, y, n)
+		unlock()
+		l.w.flush()
 		l.w.Wait()
 		return orig, loc
 	}
-	l.w.Unlock()
+	unlock()
 	return orig, nil
 }
 
 // Move atomically changes the location of the Locator by dx,dy, provided fn
 // returns true.  Returns the occupant replaced, if any, and a bool indicating
-// whether a move occurred.  It is illegal to call this method on an invalidated
-// Locator.
+// whether a move occurred.  Moves beyond the edge of a walled Grid always fail.
+// It is illegal to call this method on an invalidated Locator.
 func (l *locator) Move(dx, dy int, fn PutWhenFunc) (interface{}, bool) {
-	l.w.Lock()
+	l.w.mu.RLock()
+	x, y, x2, y2, ok, tl := l.lockWith(dx, dy)
+	unlock := func() {
+		l.w.unlockTiles(tl)
+		l.w.mu.RUnlock()
+	}
 	l.checkValid()
 	l.checkLocationInvariant()
-	x2, y2 := l.delta(dx, dy)
+	if !ok {
+		unlock()
+		return nil, false
+	}
 
-	orig, ok := l.w.moveLocked(l.x, l.y, x2, y2, fn)
+	orig, moved := l.w.moveLocked(x, y, x2, y2, fn)
 	l.checkValid()
 	l.checkLocationInvariant()
 
-	l.w.Unlock()
+	unlock()
 
-	if ok {
+	if moved {
+		l.w.flush()
 		l.w.Wait()
 	}
 
-	return orig, ok
+	return orig, moved
 }
 
 // Replace unconditionally replaces the occupant with n, and returns the
 // new Locator for n.  The existing Locator is invalidated.  It is illegal to
 // call this method on an invalidated Locator.
 func (l *locator) Replace(n interface{}) Locator {
-	l.w.Lock()
+	l.w.mu.RLock()
+	_, _, _, _, _, tl := l.lockWith(0, 0)
 	loc := l.replaceLocked(n)
-	l.w.Unlock()
+	l.w.unlockTiles(tl)
+	l.w.mu.RUnlock()
+	l.w.flush()
 	if loc != nil {
 		l.w.Wait()
 	}
 	return loc
 }
 
+// replaceLocked replaces the occupant with n.  The caller must hold l.w.mu
+// and the lock for the tile containing l, and call l.w.flush after releasing
+// them.
 func (l *locator) replaceLocked(n interface{}) Locator {
 	l.checkValid()
 	l.checkLocationInvariant()
 	old := l.v
-	if _, loc := l.w.putLocked(l.x, l.y, n, PutAlways); loc != nil {
+	x, y := l.pos()
+	if _, loc := l.w.putLocked(x, y, n, PutAlways); loc != nil {
 		if n == nil {
-			l.w.RecordRemove(l.x, l.y, old)
+			l.w.recordRemove(x, y, old)
 		} else {
-			l.w.RecordReplace(l.x, l.y, old, n)
+			l.w.recordReplace(x, y, old, n)
 		}
 		return loc
 	}
@@ -184,15 +264,24 @@ func (l *locator) Remove() {
 // invalidated Locator.  It is illegal to call this method on an invalidated
 // Locator.
 func (l *locator) RemoveWithPlaceholder(v interface{}) {
-	l.w.Lock()
+	l.w.mu.RLock()
+	if l.outside() {
+		// Already removed by Resize.
+		l.w.mu.RUnlock()
+		return
+	}
+	_, _, _, _, _, tl := l.lockWith(0, 0)
 	if l.invalid {
-		l.w.Unlock()
+		l.w.unlockTiles(tl)
+		l.w.mu.RUnlock()
 		return
 	}
 	l.checkLocationInvariant()
 	l.replaceLocked(nil)
 	l.v = v
-	l.w.Unlock()
+	l.w.unlockTiles(tl)
+	l.w.mu.RUnlock()
+	l.w.flush()
 	l.w.Wait()
 }
 
@@ -212,11 +301,28 @@ func (l *locator) IsValid() bool {
 	if l == nil {
 		return false
 	}
-	l.w.RLock()
-	defer l.w.RUnlock()
+	l.w.mu.RLock()
+	defer l.w.mu.RUnlock()
+	if l.outside() {
+		// Removed by Resize.
+		return false
+	}
+	_, _, _, _, _, tl := l.lockWith(0, 0)
+	defer l.w.unlockTiles(tl)
 	return !l.invalid
 }
 
+// Geometry returns the Geometry of the Grid the Locator refers to.
+func (l *locator) Geometry() Geometry {
+	return l.w.geom
+}
+
+// Position returns the location of the occupant in the Grid.  This may
+// change as soon as it is returned if the occupant is moved concurrently.
+func (l *locator) Position() (x, y int) {
+	return l.pos()
+}
+
 // Value returns the occupant referenced by this Locator.  If the locator is
 // nil, returns nil.
 func (l *locator) Value() interface{} {
@@ -125,3 +125,71 @@ func TestReplace(t *testing.T) {
 		t.Errorf("Replcated locator should have value 12, got %v", l3.Value())
 	}
 }
+
+func TestWalled(t *testing.T) {
+	g := NewWithTopology(2, 2, Walled, nil)
+	if g.Topology() != Walled {
+		t.Errorf("Topology() should be Walled, got %v", g.Topology())
+	}
+	_, l := g.Put(1, 1, 11, PutAlways)
+	g.Put(0, 0, 12, PutAlways)
+
+	if l2 := l.Get(1, 1); l2 != nil {
+		t.Errorf("Get() beyond a wall should have given nil, got %v", l2)
+	}
+	if l2 := l.Get(-1, -1); l2 == nil || l2.Value() != 12 {
+		t.Errorf("Get() within the walls should have found 12, got %v", l2)
+	}
+	if o, l2 := l.Put(1, 0, 13, PutAlways); o != nil || l2 != nil {
+		t.Errorf("Put() beyond a wall should have failed, got %v, %v", o, l2)
+	}
+	if _, ok := l.Move(0, 1, PutAlways); ok {
+		t.Errorf("Move() beyond a wall should have failed")
+	}
+	if l2 := g.Get(1, 1); l2 != l {
+		t.Errorf("failed Move() should have left the locator in place, expected %v got %v", l, l2)
+	}
+	if _, ok := l.Move(-1, 0, PutWhenNil); !ok {
+		t.Errorf("Move() within the walls should have succeeded")
+	}
+}
+
+func TestReflective(t *testing.T) {
+	g := NewWithTopology(3, 3, Reflective, nil)
+	_, l := g.Put(0, 0, 11, PutAlways)
+	_, l2 := g.Put(1, 1, 12, PutAlways)
+
+	if l3 := l.Get(-1, -1); l3 != l2 {
+		t.Errorf("Get() beyond the edge should reflect back into the grid, expected %v got %v", l2, l3)
+	}
+	if _, ok := l.Move(-1, 0, PutWhenNil); !ok {
+		t.Errorf("Move() beyond the edge should have reflected into an empty cell")
+	}
+	if l3 := g.Get(1, 0); l3 != l {
+		t.Errorf("Move() beyond the edge should have bounced to (1,0), got %v", l3)
+	}
+
+	cases := []struct{ v, n, expected int }{
+		{-1, 3, 1},
+		{3, 3, 1},
+		{4, 3, 0},
+		{-5, 3, 1},
+		{7, 1, 0},
+	}
+	for _, c := range cases {
+		if got := mirror(c.v, c.n); got != c.expected {
+			t.Errorf("mirror(%d, %d) expected %d got %d", c.v, c.n, c.expected, got)
+		}
+	}
+}
+
+func TestMoveOntoSelf(t *testing.T) {
+	g := New(1, 1, nil)
+	_, l := g.Put(0, 0, 11, PutAlways)
+	if _, ok := l.Move(1, 0, PutAlways); ok {
+		t.Errorf("Move() wrapping onto its own cell should not have succeeded")
+	}
+	if !l.IsValid() || g.Get(0, 0) != l {
+		t.Errorf("Move() wrapping onto its own cell should have left the locator in place")
+	}
+}
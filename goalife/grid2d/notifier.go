@@ -1,18 +1,25 @@
 // The Grid supports notifications for when changes are made.
-// Notifications are delivered via a chan <-[]Update, which, to
-// guarantee ordering and maximize performance, are delivered in
-// the critical path of the mutation itself.  Subscriber goroutines
-// should receive updates promptly to avoid stalling the update,
-// and must not directly interact with the world itself to avoid
-// deadlocking.  Subscribers can insulate themselves from these
-// conditions by queueing updates.  See the util/chanbuf package
-// for one method to accomplish this.
+// Notifications are delivered via a chan <-[]Update, in the order in
+// which the changes were made.  Updates are recorded while the Grid is
+// locked, but delivered only after the locks are released.
+//
+// By default, updates are sent to a subscriber's channel directly, so a
+// slow subscriber stalls the goroutines making changes to the Grid.  Such
+// subscribers must not directly interact with the world itself to avoid
+// deadlocking.  Subscribers can insulate themselves from these conditions
+// by subscribing with a queue (see WithQueue, DropNewest, DropOldest and
+// Unbounded), in which case updates are never delayed by the subscriber,
+// and are delivered in batches as the subscriber receives them.
 package grid2d
 
 import "sync"
 
+import "github.com/dnesting/alife/goalife/util/chanbuf"
+
 // Update represents a notification event of a change occuring to a Grid.
+// Seq numbers every change made to a Grid in order, starting from 1.
 type Update struct {
+	Seq uint64
 	Old *Point
 	New *Point
 }
@@ -41,84 +48,342 @@ func (u Update) IsReplace() bool {
 	return u.Old != nil && u.New != nil && u.Old.V != u.New.V
 }
 
+// maxPending bounds the number of updates that may await delivery before
+// the goroutines recording them begin waiting for subscribers to catch up.
+const maxPending = 1024
+
+type pending struct {
+	seq uint64
+	u   []Update
+}
+
+type subscription struct {
+	ch chan<- []Update
+
+	// from is the sequence number of the last update recorded before the
+	// subscription was made, which it should not receive.
+	from uint64
+
+	// filters select the updates the subscriber wants (see Filter).
+	filters []func(u Update) bool
+
+	// If q is set, updates are queued in q and sent to ch by pump.
+	q    chanbuf.Queue
+	quit chan struct{}
+	done chan struct{}
+}
+
+// SubscribeOption configures a subscription made with Subscribe.
+type SubscribeOption func(s *subscription)
+
+// WithQueue causes updates to be placed in q instead of sent directly to the
+// subscriber, so that a slow subscriber cannot delay changes to the Grid.
+// Queued updates are sent to the subscriber in a single batch.  If q
+// implements chanbuf.Dropper, Dropped will report the number of updates q
+// discarded.
+func WithQueue(q chanbuf.Queue) SubscribeOption {
+	return func(s *subscription) {
+		s.q = q
+	}
+}
+
+// DropNewest queues up to size updates for the subscriber, discarding
+// updates that arrive while the queue is full.
+func DropNewest(size int) SubscribeOption {
+	return WithQueue(chanbuf.Limit(size))
+}
+
+// DropOldest queues up to size updates for the subscriber, discarding the
+// oldest queued update to make room when the queue is full.
+func DropOldest(size int) SubscribeOption {
+	return WithQueue(chanbuf.Ring(size))
+}
+
+// Unbounded queues every update for the subscriber, never discarding any.
+func Unbounded() SubscribeOption {
+	return WithQueue(chanbuf.Unlimited())
+}
+
+// send delivers u to the subscriber, or queues it.
+func (s *subscription) send(u []Update) {
+	if s.q != nil {
+		s.q.Put(u)
+	} else {
+		s.ch <- u
+	}
+}
+
+// pump sends queued updates to the subscriber until the queue is exhausted,
+// and then closes the subscriber's channel.  If quit is closed first, pump
+// returns without closing the channel.
+func (s *subscription) pump() {
+	defer close(s.done)
+	for {
+		values, ok := s.q.Get()
+		if !ok {
+			// The queue is also finished by stop, after closing quit, in
+			// which case the subscriber's channel is not ours to close.
+			select {
+			case <-s.quit:
+			default:
+				close(s.ch)
+			}
+			return
+		}
+		var batch []Update
+		for _, v := range values {
+			batch = append(batch, v.([]Update)...)
+		}
+		select {
+		case s.ch <- batch:
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// stop stops any pump after the subscription has been removed and is no
+// longer being sent updates.
+func (s *subscription) stop() {
+	if s.q != nil {
+		close(s.quit)
+		s.q.Done()
+		<-s.done
+	}
+}
+
+// Updates are recorded into a pending queue by record, and delivered by
+// flush.  Only one goroutine delivers at a time, which preserves the order in
+// which updates were recorded; other goroutines calling flush leave their
+// updates for it.  sendMu is held for the duration of each delivery so that
+// Unsubscribe and CloseSubscribers can wait for deliveries in flight.
 type notifier struct {
-	mu   sync.Mutex
-	subs []chan<- []Update
+	mu         sync.Mutex
+	drained    sync.Cond
+	subs       []*subscription
+	seq        uint64
+	pending    []pending
+	delivering bool
+
+	sendMu sync.Mutex
+	closed bool
 }
 
 // CloseSubscribers iterates over the notification subscribers and closes them, so as
 // to signal consumers that no more notifications will be arriving.  It is illegal to
 // continue to mutate a Grid after this method is called.
 func (n *notifier) CloseSubscribers() {
+	n.sendMu.Lock()
+	defer n.sendMu.Unlock()
 	n.mu.Lock()
 	defer n.mu.Unlock()
-	for _, ch := range n.subs {
-		close(ch)
+	n.closed = true
+	n.pending = nil
+	for _, s := range n.subs {
+		if s.q != nil {
+			// pump will close s.ch once it has sent everything queued.
+			s.q.Done()
+		} else {
+			close(s.ch)
+		}
 	}
 }
 
 // Subscribe adds ch to the list of notification subscribers, which will begin receiving
-// events immediately as the Grid is mutated.
-func (n *notifier) Subscribe(ch chan<- []Update) {
+// events immediately as the Grid is mutated.  By default, updates are sent to ch
+// directly.  See SubscribeOption for alternatives.
+func (n *notifier) Subscribe(ch chan<- []Update, opts ...SubscribeOption) {
+	s := &subscription{ch: ch}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.q != nil {
+		s.quit = make(chan struct{})
+		s.done = make(chan struct{})
+		go s.pump()
+	}
+
 	n.mu.Lock()
 	defer n.mu.Unlock()
-	n.subs = append(n.subs, ch)
+	s.from = n.seq
+	n.subs = append(n.subs, s)
+}
+
+// Dropped returns the number of updates discarded by the queue of the
+// subscription for ch, or 0 if its queue does not discard updates.
+func (n *notifier) Dropped(ch chan<- []Update) int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, s := range n.subs {
+		if s.ch == ch {
+			if d, ok := s.q.(chanbuf.Dropper); ok {
+				return d.Dropped()
+			}
+		}
+	}
+	return 0
 }
 
 // Unsubscribe removes ch from the list of notification subscribers.  No further
 // notifications will be sent to ch once this method returns.
 func (n *notifier) Unsubscribe(ch chan<- []Update) {
 	n.mu.Lock()
-	defer n.mu.Unlock()
-	subs := make([]chan<- []Update, 0, len(n.subs))
+	subs := make([]*subscription, 0, len(n.subs))
+	var removed []*subscription
 	for _, s := range n.subs {
-		if s != ch {
+		if s.ch != ch {
 			subs = append(subs, s)
+		} else {
+			removed = append(removed, s)
 		}
 	}
 	n.subs = subs
+	n.mu.Unlock()
+
+	// Wait for any delivery still using the old list of subscribers.
+	n.sendMu.Lock()
+	closed := n.closed
+	n.sendMu.Unlock()
+
+	if !closed {
+		for _, s := range removed {
+			s.stop()
+		}
+	}
+}
+
+// Seq returns the sequence number of the most recent change.
+func (n *notifier) Seq() uint64 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.seq
+}
+
+// setSeq changes the sequence number of the most recent change.
+func (n *notifier) setSeq(seq uint64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.seq = seq
 }
 
 // RecordAdd records an Add notification for the given occupant.
 func (n *notifier) RecordAdd(x, y int, value interface{}) {
-	n.add([]Update{
-		Update{
-			New: &Point{x, y, value},
-		}})
+	n.recordAdd(x, y, value)
+	n.flush()
 }
 
 // RecordRemove records a Remove notification for the given occupant.
 func (n *notifier) RecordRemove(x, y int, value interface{}) {
-	n.add([]Update{
-		Update{
-			Old: &Point{x, y, value},
-		}})
+	n.recordRemove(x, y, value)
+	n.flush()
 }
 
 // RecordMove records a Move notification for the given occupant.
 // x1,y1 represents the original location and x2,y2 represents the new one.
 func (n *notifier) RecordMove(x1, y1, x2, y2 int, value interface{}) {
-	n.add([]Update{
-		Update{
-			Old: &Point{x1, y1, value},
-			New: &Point{x2, y2, value},
-		}})
+	n.recordMove(x1, y1, x2, y2, value)
+	n.flush()
 }
 
 // RecordReplace records a Replace notification for the given occupants.
 func (n *notifier) RecordReplace(x, y int, orig, repl interface{}) {
-	n.add([]Update{Update{
+	n.recordReplace(x, y, orig, repl)
+	n.flush()
+}
+
+// The unexported record methods queue a notification without delivering it,
+// and may be called with Grid locks held.  The caller must call flush once
+// those locks are released.
+
+func (n *notifier) recordAdd(x, y int, value interface{}) {
+	n.record(Update{
+		New: &Point{x, y, value},
+	})
+}
+
+func (n *notifier) recordRemove(x, y int, value interface{}) {
+	n.record(Update{
+		Old: &Point{x, y, value},
+	})
+}
+
+func (n *notifier) recordMove(x1, y1, x2, y2 int, value interface{}) {
+	n.record(Update{
+		Old: &Point{x1, y1, value},
+		New: &Point{x2, y2, value},
+	})
+}
+
+func (n *notifier) recordReplace(x, y int, orig, repl interface{}) {
+	n.record(Update{
 		Old: &Point{x, y, orig},
 		New: &Point{x, y, repl},
-	}})
+	})
 }
 
-func (n *notifier) add(u []Update) {
+// record numbers u and queues it for delivery, unless no subscriber wants it.
+func (n *notifier) record(u Update) {
 	n.mu.Lock()
 	defer n.mu.Unlock()
-	for _, ch := range n.subs {
-		ch <- u
+	n.seq++
+	u.Seq = n.seq
+	if n.closed {
+		return
+	}
+	for _, s := range n.subs {
+		if s.wants(u) {
+			n.pending = append(n.pending, pending{n.seq, []Update{u}})
+			return
+		}
+	}
+}
+
+// flush delivers pending updates to subscribers, unless another goroutine is
+// already doing so.  If too many updates are pending, waits for them to be
+// delivered first.
+func (n *notifier) flush() {
+	n.mu.Lock()
+	if n.delivering {
+		for n.delivering && len(n.pending) > maxPending {
+			n.waitDrained()
+		}
+		n.mu.Unlock()
+		return
+	}
+	n.delivering = true
+	for len(n.pending) > 0 {
+		batch := n.pending
+		n.pending = nil
+		subs := n.subs
+		n.drained.Broadcast()
+		n.mu.Unlock()
+
+		n.sendMu.Lock()
+		if !n.closed {
+			for _, p := range batch {
+				for _, s := range subs {
+					if p.seq > s.from && s.wants(p.u[0]) {
+						s.send(p.u)
+					}
+				}
+			}
+		}
+		n.sendMu.Unlock()
+
+		n.mu.Lock()
+	}
+	n.delivering = false
+	n.drained.Broadcast()
+	n.mu.Unlock()
+}
+
+// waitDrained waits for the delivering goroutine to take the pending updates.
+// The caller must hold n.mu.
+func (n *notifier) waitDrained() {
+	if n.drained.L == nil {
+		n.drained.L = &n.mu
 	}
+	n.drained.Wait()
 }
 
 // NotifyToInterface provides a type conversion from <-chan []Update to
@@ -6,18 +6,21 @@ import "testing"
 func TestNotify(t *testing.T) {
 	tAdd := []Update{
 		Update{
+			Seq: 1,
 			New: &Point{1, 2, 10},
 		},
 	}
 
 	tRemove := []Update{
 		Update{
+			Seq: 2,
 			Old: &Point{1, 2, 10},
 		},
 	}
 
 	tMove := []Update{
 		Update{
+			Seq: 3,
 			Old: &Point{1, 1, 10},
 			New: &Point{2, 2, 10},
 		},
@@ -25,6 +28,7 @@ func TestNotify(t *testing.T) {
 
 	tReplace := []Update{
 		Update{
+			Seq: 4,
 			Old: &Point{1, 1, 10},
 			New: &Point{1, 1, 11},
 		},
@@ -60,3 +64,166 @@ func TestNotify(t *testing.T) {
 		t.Errorf("notification failed, expected %v got %v", tReplace, got)
 	}
 }
+
+// receiveAll collects the values of every update received from ch until it
+// is closed.
+func receiveAll(ch <-chan []Update) []interface{} {
+	var values []interface{}
+	for updates := range ch {
+		for _, u := range updates {
+			values = append(values, u.New.V)
+		}
+	}
+	return values
+}
+
+func TestSubscribeDropNewest(t *testing.T) {
+	var n notifier
+	ch := make(chan []Update)
+	n.Subscribe(ch, DropNewest(2))
+
+	// Nothing is receiving from ch, so these must not block.
+	for i := 0; i < 10; i++ {
+		n.RecordAdd(0, 0, i)
+	}
+	dropped := n.Dropped(ch)
+	n.CloseSubscribers()
+	got := receiveAll(ch)
+
+	if len(got)+dropped != 10 {
+		t.Errorf("expected 10 updates received or dropped, got %d received and %d dropped", len(got), dropped)
+	}
+	if len(got) == 0 || got[0] != 0 {
+		t.Errorf("expected the first update to be kept, got %v", got)
+	}
+}
+
+func TestSubscribeDropOldest(t *testing.T) {
+	var n notifier
+	ch := make(chan []Update)
+	n.Subscribe(ch, DropOldest(2))
+
+	for i := 0; i < 10; i++ {
+		n.RecordAdd(0, 0, i)
+	}
+	dropped := n.Dropped(ch)
+	n.CloseSubscribers()
+	got := receiveAll(ch)
+
+	if len(got)+dropped != 10 {
+		t.Errorf("expected 10 updates received or dropped, got %d received and %d dropped", len(got), dropped)
+	}
+	if len(got) == 0 || got[len(got)-1] != 9 {
+		t.Errorf("expected the last update to be kept, got %v", got)
+	}
+}
+
+func TestSubscribeUnbounded(t *testing.T) {
+	var n notifier
+	ch := make(chan []Update)
+	n.Subscribe(ch, Unbounded())
+
+	for i := 0; i < 10; i++ {
+		n.RecordAdd(0, 0, i)
+	}
+	n.CloseSubscribers()
+	got := receiveAll(ch)
+
+	expected := []interface{}{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v got %v", expected, got)
+	}
+	if d := n.Dropped(ch); d != 0 {
+		t.Errorf("expected 0 dropped got %d", d)
+	}
+}
+
+func TestUnsubscribeQueued(t *testing.T) {
+	var n notifier
+	ch := make(chan []Update)
+	n.Subscribe(ch, Unbounded())
+	n.RecordAdd(0, 0, 1)
+
+	// Nothing is receiving from ch, so this must not block.
+	n.Unsubscribe(ch)
+	n.RecordAdd(0, 0, 2)
+
+	select {
+	case u := <-ch:
+		t.Errorf("expected no updates after Unsubscribe, got %v", u)
+	default:
+	}
+}
+
+func TestUnsubscribeIdle(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		var n notifier
+		ch := make(chan []Update)
+		n.Subscribe(ch, Unbounded())
+
+		// With nothing queued, Unsubscribe must not close ch.
+		n.Unsubscribe(ch)
+		select {
+		case u, ok := <-ch:
+			t.Fatalf("expected ch to remain open and empty after Unsubscribe, got %v (ok=%v)", u, ok)
+		default:
+		}
+	}
+}
+
+func TestSubscribeFiltered(t *testing.T) {
+	var n notifier
+	isEven := func(v interface{}) bool { return v.(int)%2 == 0 }
+
+	kinds := make(chan []Update)
+	occupants := make(chan []Update)
+	region := make(chan []Update)
+	n.Subscribe(kinds, Unbounded(), Kinds(Added|Removed))
+	n.Subscribe(occupants, Unbounded(), Occupants(isEven))
+	n.Subscribe(region, Unbounded(), Region(1, 1, 2, 2))
+
+	n.RecordAdd(0, 0, 1)
+	n.RecordMove(0, 0, 1, 1, 1)
+	n.RecordReplace(1, 1, 1, 2)
+	n.RecordRemove(1, 1, 2)
+	n.RecordAdd(3, 3, 3)
+	n.CloseSubscribers()
+
+	kindsExpected := []Kind{Added, Removed, Added}
+	occupantsExpected := []Kind{Replaced, Removed}
+	regionExpected := []Kind{Moved, Replaced, Removed}
+	for _, c := range []struct {
+		name     string
+		ch       chan []Update
+		expected []Kind
+	}{
+		{"Kinds", kinds, kindsExpected},
+		{"Occupants", occupants, occupantsExpected},
+		{"Region", region, regionExpected},
+	} {
+		var got []Kind
+		for updates := range c.ch {
+			for _, u := range updates {
+				got = append(got, u.Kind())
+			}
+		}
+		if !reflect.DeepEqual(got, c.expected) {
+			t.Errorf("%s: expected %v got %v", c.name, c.expected, got)
+		}
+	}
+}
+
+func TestRecordUnwanted(t *testing.T) {
+	var n notifier
+	ch := make(chan []Update)
+	n.Subscribe(ch, Unbounded(), Kinds(Removed))
+	n.mu.Lock()
+	n.delivering = true // prevent delivery so we can inspect n.pending
+	n.mu.Unlock()
+
+	n.RecordAdd(0, 0, 1)
+	n.RecordRemove(0, 0, 1)
+	if len(n.pending) != 1 {
+		t.Errorf("expected 1 update pending got %d", len(n.pending))
+	}
+}
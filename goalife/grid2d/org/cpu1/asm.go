@@ -0,0 +1,346 @@
+package cpu1
+
+import "bufio"
+import "fmt"
+import "io"
+import "strconv"
+import "strings"
+
+// The assembler accepts source text of the following form, one statement per
+// line:
+//
+//	; Comments begin with ';' or '#' and run to the end of the line.
+//	loop:           ; defines a label, assembled as one of L1-L4
+//	    Eat
+//	    Inc * 3     ; repeats an instruction
+//	    Jump loop   ; Jump1-Jump4, whichever searches forward for loop
+//	    JumpR loop  ; JumpR1-JumpR4, likewise but backward
+//	    Jump        ; without a label, the Jump instruction itself (IP = D)
+//	    .byte 0x2d  ; a literal byte
+//	.rep 2          ; repeats the statements up to the matching .end
+//	    Forward
+//	.end
+//	.macro turn     ; defines a macro, expanded wherever its name appears
+//	    Left
+//	    Left
+//	.end
+//	    turn * 2
+//
+// Symbolic label names are given the lowest of L1-L4 not otherwise used.
+// The names L1-L4 themselves may also be used, and refer to those labels.
+// A label may be defined more than once; jumps go to the nearest definition
+// in the direction they search.
+
+// AsmErr describes an error in assembler source.
+type AsmErr struct {
+	Line int    // the line number of the error, starting from 1
+	Msg  string // the error
+}
+
+func (e AsmErr) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+// numLabels is the number of distinct labels, L1-L4.
+const numLabels = 4
+
+type line struct {
+	n    int
+	text string
+}
+
+type assembler struct {
+	code      []byte
+	macros    map[string][]line
+	expanding map[string]bool
+	labels    map[string]int  // label names to their numbers
+	used      map[int]string  // label numbers to the names using them
+	defined   map[string]bool // labels that have been defined
+	refs      map[string]int  // labels referenced, to the first line doing so
+	byName    map[string]byte // ops by name
+	explicit  map[string]int  // L1-L4 by name
+}
+
+// Assemble reads source text from r and assembles it into bytecode.  Errors
+// in the source are reported as an AsmErr.
+func (ops OpTable) Assemble(r io.Reader) (Bytecode, error) {
+	a := &assembler{
+		macros:    make(map[string][]line),
+		expanding: make(map[string]bool),
+		labels:    make(map[string]int),
+		used:      make(map[int]string),
+		defined:   make(map[string]bool),
+		refs:      make(map[string]int),
+		byName:    make(map[string]byte),
+		explicit:  make(map[string]int),
+	}
+	for i, op := range ops {
+		a.byName[op.Name] = byte(i)
+	}
+	for n := 1; n <= numLabels; n++ {
+		a.explicit[fmt.Sprintf("L%d", n)] = n
+	}
+
+	var lines []line
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		lines = append(lines, line{n, s.Text()})
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if err := a.block(lines); err != nil {
+		return nil, err
+	}
+	for name, n := range a.refs {
+		if !a.defined[name] {
+			if _, ok := a.explicit[name]; !ok {
+				return nil, AsmErr{n, fmt.Sprintf("label %q is never defined", name)}
+			}
+		}
+	}
+	return Bytecode(a.code), nil
+}
+
+// fields returns the whitespace-separated fields of text, ignoring comments.
+func fields(text string) []string {
+	if i := strings.IndexAny(text, ";#"); i >= 0 {
+		text = text[:i]
+	}
+	return strings.Fields(text)
+}
+
+// body returns the lines following lines[i] up to its matching .end, and the
+// index of the .end.
+func body(lines []line, i int) ([]line, int, error) {
+	depth := 1
+	for j := i + 1; j < len(lines); j++ {
+		f := fields(lines[j].text)
+		if len(f) == 0 {
+			continue
+		}
+		switch f[0] {
+		case ".rep", ".macro":
+			depth++
+		case ".end":
+			depth--
+			if depth == 0 {
+				return lines[i+1 : j], j, nil
+			}
+		}
+	}
+	return nil, 0, AsmErr{lines[i].n, fmt.Sprintf("%s without .end", fields(lines[i].text)[0])}
+}
+
+// block assembles lines.
+func (a *assembler) block(lines []line) error {
+	for i := 0; i < len(lines); i++ {
+		l := lines[i]
+		f := fields(l.text)
+		if len(f) == 0 {
+			continue
+		}
+		switch f[0] {
+		case ".macro":
+			if len(f) != 2 {
+				return AsmErr{l.n, "expected .macro name"}
+			}
+			if _, ok := a.byName[f[1]]; ok {
+				return AsmErr{l.n, fmt.Sprintf("macro %q has the name of an instruction", f[1])}
+			}
+			b, end, err := body(lines, i)
+			if err != nil {
+				return err
+			}
+			a.macros[f[1]] = b
+			i = end
+		case ".rep":
+			if len(f) != 2 {
+				return AsmErr{l.n, "expected .rep count"}
+			}
+			count, err := strconv.Atoi(f[1])
+			if err != nil || count < 0 {
+				return AsmErr{l.n, fmt.Sprintf("invalid count %q", f[1])}
+			}
+			b, end, err := body(lines, i)
+			if err != nil {
+				return err
+			}
+			for j := 0; j < count; j++ {
+				if err := a.block(b); err != nil {
+					return err
+				}
+			}
+			i = end
+		case ".end":
+			return AsmErr{l.n, ".end without .rep or .macro"}
+		default:
+			if err := a.statement(l.n, f); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// label returns the number of the label name, assigning one if necessary.
+func (a *assembler) label(n int, name string) (int, error) {
+	if num, ok := a.labels[name]; ok {
+		return num, nil
+	}
+	num, ok := a.explicit[name]
+	if !ok {
+		for num = 1; num <= numLabels && a.used[num] != ""; num++ {
+		}
+		if num > numLabels {
+			return 0, AsmErr{n, fmt.Sprintf("too many labels, %q would be the %dth", name, numLabels+1)}
+		}
+	} else if other := a.used[num]; other != "" {
+		return 0, AsmErr{n, fmt.Sprintf("label %s is already used by %q", name, other)}
+	}
+	a.labels[name] = num
+	a.used[num] = name
+	return num, nil
+}
+
+// statement assembles a single statement, given as fields f, at line n.
+func (a *assembler) statement(n int, f []string) error {
+	// Label definitions.
+	for len(f) > 0 && strings.HasSuffix(f[0], ":") {
+		name := strings.TrimSuffix(f[0], ":")
+		if name == "" {
+			return AsmErr{n, "empty label"}
+		}
+		num, err := a.label(n, name)
+		if err != nil {
+			return err
+		}
+		a.defined[name] = true
+		if err := a.emit(n, fmt.Sprintf("L%d", num), 1); err != nil {
+			return err
+		}
+		f = f[1:]
+	}
+	if len(f) == 0 {
+		return nil
+	}
+
+	count := 1
+	if len(f) >= 3 && f[len(f)-2] == "*" {
+		c, err := strconv.Atoi(f[len(f)-1])
+		if err != nil || c < 0 {
+			return AsmErr{n, fmt.Sprintf("invalid count %q", f[len(f)-1])}
+		}
+		count = c
+		f = f[:len(f)-2]
+	}
+
+	name, args := f[0], f[1:]
+	switch {
+	case name == ".byte":
+		if len(args) != 1 {
+			return AsmErr{n, "expected .byte value"}
+		}
+		b, err := strconv.ParseUint(args[0], 0, 8)
+		if err != nil {
+			return AsmErr{n, fmt.Sprintf("invalid byte %q", args[0])}
+		}
+		for i := 0; i < count; i++ {
+			a.code = append(a.code, byte(b))
+		}
+		return nil
+
+	case (name == "Jump" || name == "JumpR") && len(args) == 1:
+		num, err := a.label(n, args[0])
+		if err != nil {
+			return err
+		}
+		if _, ok := a.refs[args[0]]; !ok {
+			a.refs[args[0]] = n
+		}
+		return a.emit(n, fmt.Sprintf("%s%d", name, num), count)
+
+	case len(args) != 0:
+		return AsmErr{n, fmt.Sprintf("unexpected %q after %s", strings.Join(args, " "), name)}
+	}
+
+	if m, ok := a.macros[name]; ok {
+		if a.expanding[name] {
+			return AsmErr{n, fmt.Sprintf("macro %q expands itself", name)}
+		}
+		a.expanding[name] = true
+		defer delete(a.expanding, name)
+		for i := 0; i < count; i++ {
+			if err := a.block(m); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return a.emit(n, name, count)
+}
+
+// emit appends count copies of the instruction name.
+func (a *assembler) emit(n int, name string, count int) error {
+	b, ok := a.byName[name]
+	if !ok {
+		return AsmErr{n, fmt.Sprintf("unknown instruction %q", name)}
+	}
+	for i := 0; i < count; i++ {
+		a.code = append(a.code, b)
+	}
+	return nil
+}
+
+// jumpLabel returns the label number a JumpN or JumpRN instruction name
+// refers to, and whether it searches backward.
+func jumpLabel(name string) (num int, backward bool, ok bool) {
+	var suffix string
+	switch {
+	case strings.HasPrefix(name, "JumpR"):
+		suffix, backward = name[len("JumpR"):], true
+	case strings.HasPrefix(name, "Jump"):
+		suffix = name[len("Jump"):]
+	default:
+		return 0, false, false
+	}
+	num, err := strconv.Atoi(suffix)
+	if err != nil || num < 1 || num > numLabels {
+		return 0, false, false
+	}
+	return num, backward, true
+}
+
+// isLabel returns true if name is one of L1-L4.
+func isLabel(name string) bool {
+	return len(name) == 2 && name[0] == 'L' && name[1] >= '1' && name[1] <= '0'+numLabels
+}
+
+// Disassemble writes code to w as source that Assemble will turn back into
+// the same code.  Each line is annotated with the address of the
+// instruction, and jumps to labels with the address they would jump to.
+func (ops OpTable) Disassemble(w io.Writer, code Bytecode) error {
+	for i, b := range code {
+		var s, note string
+		if int(b) >= ops.Len() {
+			s = fmt.Sprintf("    .byte %#02x", b)
+		} else if name := ops[b].Name; isLabel(name) {
+			s = name + ":"
+		} else if num, backward, ok := jumpLabel(name); ok {
+			// Jumps search from the instruction following them.
+			if backward {
+				s = fmt.Sprintf("    JumpR L%d", num)
+				note = fmt.Sprintf(" -> %d", code.findBackward(num, i+1))
+			} else {
+				s = fmt.Sprintf("    Jump L%d", num)
+				note = fmt.Sprintf(" -> %d", code.find(num, i+1))
+			}
+		} else {
+			s = "    " + name
+		}
+		if _, err := fmt.Fprintf(w, "%-16s; %d%s\n", s, i, note); err != nil {
+			return err
+		}
+	}
+	return nil
+}
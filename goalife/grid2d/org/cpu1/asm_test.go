@@ -0,0 +1,105 @@
+package cpu1
+
+import "bytes"
+import "reflect"
+import "strings"
+import "testing"
+
+func TestAssemble(t *testing.T) {
+	src := `
+; A contrived program exercising each feature.
+.macro turn
+    Left   # turn twice
+    Left
+.end
+start:  Zero
+loop:
+    Inc * 2
+    turn
+    L3
+    JumpR loop
+.rep 2
+    Jump start
+    Jump
+.end
+    .byte 0xff
+`
+	code, err := Ops.Assemble(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("error assembling: %v", err)
+	}
+	expected := []string{
+		"L1", "Zero", "L2", "Inc", "Inc", "Left", "Left", "L3", "JumpR2",
+		"Jump1", "Jump", "Jump1", "Jump",
+	}
+	got, err := Ops.Decompile(code[:len(code)-1])
+	if err != nil {
+		t.Fatalf("error decompiling: %v", err)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v got %v", expected, got)
+	}
+	if code[len(code)-1] != 0xff {
+		t.Errorf("expected a trailing 0xff got %#02x", code[len(code)-1])
+	}
+}
+
+func TestAssembleErrors(t *testing.T) {
+	cases := []struct {
+		src  string
+		line int
+	}{
+		{"Inc\nBogus\n", 2},
+		{"Inc\n\nJump nowhere\n", 3},
+		{"a:\nb:\nc:\nd:\ne:\n", 5},
+		{"a:\nL1:\n", 2},
+		{".rep 2\nInc\n", 1},
+		{"Inc\n.end\n", 2},
+		{".macro m\nm\n.end\nm\n", 2},
+		{"Inc * x\n", 1},
+		{"Inc Dec\n", 1},
+	}
+	for _, c := range cases {
+		_, err := Ops.Assemble(strings.NewReader(c.src))
+		if e, ok := err.(AsmErr); !ok || e.Line != c.line {
+			t.Errorf("assembling %q expected an error on line %d got %v", c.src, c.line, err)
+		}
+	}
+}
+
+func TestDisassemble(t *testing.T) {
+	code, err := Ops.Compile([]string{"Inc", "L2", "Dec", "JumpR2", "Jump2", "Jump"})
+	if err != nil {
+		t.Fatalf("error compiling: %v", err)
+	}
+	code = append(code, 0xfe)
+
+	var b bytes.Buffer
+	if err := Ops.Disassemble(&b, code); err != nil {
+		t.Fatalf("error disassembling: %v", err)
+	}
+	expected := `    Inc         ; 0
+L2:             ; 1
+    Dec         ; 2
+    JumpR L2    ; 3 -> 1
+    Jump L2     ; 4 -> 1
+    Jump        ; 5
+    .byte 0xfe  ; 6
+`
+	if b.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, b.String())
+	}
+
+	// Disassembly round-trips, even for random code.
+	for i := 0; i < 10; i++ {
+		code := append(RandomBytecode(Ops), 0xfe)
+		b.Reset()
+		Ops.Disassemble(&b, code)
+		got, err := Ops.Assemble(&b)
+		if err != nil {
+			t.Errorf("error reassembling: %v", err)
+		} else if !bytes.Equal(got, code) {
+			t.Errorf("expected %v got %v", code, got)
+		}
+	}
+}
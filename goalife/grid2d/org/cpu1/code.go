@@ -1,8 +1,9 @@
 package cpu1
 
 import "hash/crc32"
-import "math"
-import "math/rand"
+
+import "github.com/dnesting/alife/goalife/mutation"
+import "github.com/dnesting/alife/goalife/util/rng"
 
 // Bytecode represents the instructions the Cpu should execute.
 type Bytecode []byte
@@ -28,55 +29,25 @@ var RandLengthMin = 50
 // RandomBytecode returns randomly-generated bytecode that is plausibly
 // executable.
 func RandomBytecode(ops OpTable) Bytecode {
-	s := rand.Intn(RandLengthMax-RandLengthMin) + RandLengthMin
+	s := rng.Intn(RandLengthMax-RandLengthMin) + RandLengthMin
 	d := make([]byte, s)
 	maxOp := ops.Len()
 	for i := 0; i < s; i++ {
-		d[i] = byte(rand.Intn(maxOp))
+		d[i] = byte(rng.Intn(maxOp))
 	}
 	return Bytecode(d)
 }
 
-// Mutate randomly mutates the code.  Three types of mutations are supported:
-// 1. A single instruction change
-// 2. Deletion of a segment
-// 3. Duplication of a segment
-func (c *Bytecode) Mutate(ops OpTable) {
-	var d []byte
-	maxOp := ops.Len()
-
-	var i int
-	i = rand.Intn(c.Len())
-	l := int(math.Ceil(math.Abs(rand.NormFloat64() * 5)))
-	prob := rand.Float32()
-	if prob < 0.333 && c.Len() > 0 {
-		// Change a single instruction at i
-		d = make([]byte, c.Len())
-		copy(d, c.Bytes())
-		d[i] = byte(rand.Intn(maxOp))
-
-	} else if prob < 0.666 {
-		// Duplicate a segment starting at i of length l
-		d = make([]byte, c.Len()+l)
-		copy(d[:i], c.Bytes()[:i])
-		for j := i; j < i+l; j++ {
-			d[j] = c.Bytes()[j%c.Len()]
-		}
-		copy(d[i+l:], c.Bytes()[i:])
-
-	} else if c.Len() > 0 {
-		// Delete a segment starting at i of length l
-		if i+l > c.Len() {
-			l = c.Len() - i
-		}
-		d = make([]byte, c.Len()-l)
-		copy(d[:i], c.Bytes()[:i])
-		copy(d[i:], c.Bytes()[i+l:])
-	}
-	// Replace the CPU's code only if the mutated version is non-empty
-	if len(d) > 0 {
-		*c = Bytecode(d)
+// Mutate randomly mutates the code, making a mutation of a kind chosen by
+// the current mutation.Scheme (by default, a single instruction change, or
+// the duplication or deletion of a segment).  Returns a description of the
+// mutation, or nil if the code was left unchanged.
+func (c *Bytecode) Mutate(ops OpTable) *mutation.Mutation {
+	m := mutation.Mutate([]byte(*c), ops.Len())
+	if m != nil {
+		*c = Bytecode(m.Apply(*c))
 	}
+	return m
 }
 
 // Find locates the given value in the CPU's code slice, searching forward and wrapping around.
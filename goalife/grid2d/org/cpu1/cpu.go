@@ -8,6 +8,8 @@ import "fmt"
 import "github.com/dnesting/alife/goalife/grid2d"
 import "github.com/dnesting/alife/goalife/grid2d/org"
 import "github.com/dnesting/alife/goalife/log"
+import "github.com/dnesting/alife/goalife/mutation"
+import "github.com/dnesting/alife/goalife/physics"
 
 var Logger = log.Null()
 
@@ -16,24 +18,85 @@ type Cpu struct {
 	Ip   int // Instruction Pointer, an index into Code for the next instruction
 	Code Bytecode
 	R    [4]int // Registers, described as A B C and D in the opcodes
+
+	// Parent is the hash of the Code of the Cpu this one was copied from, or
+	// 0 if it wasn't copied, and Mutation describes how Code was mutated from
+	// the parent's, if it was.  Mate is the hash of the Code of the Cpu that
+	// the parent's Code was crossed with, if it was.  See package lineage.
+	Parent   uint64
+	Mate     uint64 `json:",omitempty"`
+	Mutation string
+
+	// Start is invoked to begin executing the offspring of this Cpu.  It is
+	// inherited by each offspring, and is not saved.  If nil, Go is used.
+	Start StartFunc
+
+	event *mutation.Event // see MutationEvent
+
+	// Trace, if set, is called with a description of each step executed.  It
+	// is not inherited or saved.  See Tracer.
+	Trace TraceFunc
+
+	child *org.Organism // offspring started during a traced step
 }
 
 func (c *Cpu) String() string {
 	return fmt.Sprintf("[cpu %x ip=%d %v]", c.Code.Hash(), c.Ip, c.R)
 }
 
-// Copy returns a new Cpu with the same Code.  The Cpu's instruction pointer
-// and registers are not copied.
+// Copy returns a new Cpu with the same Code and Start, descended from c.  The Cpu's instruction
+// pointer and registers are not copied.
 func (c *Cpu) Copy() *Cpu {
 	return &Cpu{
-		Code: c.Code,
+		Code:   c.Code,
+		Parent: c.Hash(),
+		Start:  c.Start,
 	}
 }
 
-// Mutate causes the Cpu's Code to be mutated.
-func (c *Cpu) Mutate() {
+// Mutate causes the Cpu's Code to be mutated, and records a description of
+// the mutation in Mutation.  Returns the mutation, or nil if Code was left
+// unchanged.
+func (c *Cpu) Mutate() *mutation.Mutation {
 	Logger.Printf("%v.Mutate()", c)
-	c.Code.Mutate(Ops)
+	m := c.Code.Mutate(Ops)
+	if m != nil {
+		c.Mutation = m.String()
+	}
+	return m
+}
+
+// Cross causes the Cpu's Code to be recombined with mate's, using points
+// crossover points (see mutation.Crossover), and records mate in Mate and a
+// description of the crossover in Mutation.  Returns the crossover, or nil
+// if Code was left unchanged.
+func (c *Cpu) Cross(mate *Cpu, points int) *mutation.Mutation {
+	Logger.Printf("%v.Cross(%v, %d)", c, mate, points)
+	m := mutation.Crossover(c.Code, mate.Code, points)
+	if m != nil {
+		c.Code = Bytecode(m.Apply(c.Code))
+		c.Mate = mate.Hash()
+		c.Mutation = m.String()
+	}
+	return m
+}
+
+// MutationEvent returns the mutation.Event recording the birth of this Cpu
+// as a mutant, if it is one and mutation.Events is set.
+func (c *Cpu) MutationEvent() *mutation.Event {
+	return c.event
+}
+
+// Origin returns the hash of the Code of the Cpu's parent, and the mutation
+// that produced its own Code, as recorded by Copy and Mutate.
+func (c *Cpu) Origin() (parent uint64, mutation string) {
+	return c.Parent, c.Mutation
+}
+
+// Parents returns the hashes of the Code of the Cpu's parent and of its
+// mate, as recorded by Copy and Cross.
+func (c *Cpu) Parents() (parent, mate uint64) {
+	return c.Parent, c.Mate
 }
 
 // Hash identifies the Cpu by its bytecode.  This is used to establish the
@@ -55,9 +118,16 @@ var unableToReadErr = errors.New("unable to read next instruction")
 
 // Step executes one CPU operation.  Any error returned either assessing the
 // operation's energy cost or executing it will be returned by this method.
-// Execution is expected to cease (and the organism's Die method
-// invoked) if an error is returned.
+// Execution is expected to cease (and the organism's DieOf method
+// invoked with the error) if an error is returned.
 func (c *Cpu) Step(o *org.Organism) (err error) {
+	if c.Trace != nil {
+		return c.traceStep(o)
+	}
+	return c.step(o)
+}
+
+func (c *Cpu) step(o *org.Organism) error {
 	op, ip := c.readOp()
 	c.Ip = ip
 	if op == nil {
@@ -65,8 +135,13 @@ func (c *Cpu) Step(o *org.Organism) (err error) {
 	}
 	Logger.Printf("%v.Step(%v): %v\n", c, o, op)
 
-	// All operations cost at least 1 energy, to avoid infinite loops.
-	if err := o.Discharge(1 + op.Cost); err != nil {
+	// Organisms age with each step, and may die of it.
+	if err := o.Tick(); err != nil {
+		return err
+	}
+
+	// All operations cost at least StepCost energy, to avoid infinite loops.
+	if err := o.Discharge(physics.Current().StepCost + op.Cost); err != nil {
 		return err
 	}
 
@@ -78,13 +153,13 @@ func (c *Cpu) Step(o *org.Organism) (err error) {
 }
 
 // Run executes Step repeatedly, until Step returns an error, at which point this
-// method will invoke o.Die and return.
+// method will invoke o.DieOf with the error and return.
 func (c *Cpu) Run(o *org.Organism) error {
 	Logger.Printf("%v.Run(%v)\n", c, o)
 	for {
 		if err := c.Step(o); err != nil {
 			Logger.Printf("%v.Run: %v\n", c, err)
-			o.Die()
+			o.DieOf(err)
 			return err
 		}
 	}
@@ -96,21 +171,46 @@ func (c *Cpu) readOp() (*Op, int) {
 		return nil, c.Ip + 1
 	}
 	b := c.Code[c.Ip]
-	if b < 0 || b > byte(len(Ops)) {
+	if int(b) >= len(Ops) {
 		return nil, c.Ip + 1
 	}
 	return &Ops[b], c.Ip + 1
 }
 
-// StartAll finds all organisms driven by Cpu instances, and spawns a goroutine
-// to begin executing each Cpu instance found.
-func StartAll(g grid2d.Grid) {
+// StartFunc begins executing c on behalf of o.
+type StartFunc func(o *org.Organism, c *Cpu)
+
+// Go runs c in its own goroutine.
+func Go(o *org.Organism, c *Cpu) {
+	go c.Run(o)
+}
+
+// start begins executing the offspring n, driven by nc, of c.
+func (c *Cpu) start(n *org.Organism, nc *Cpu) {
+	if c.Trace != nil {
+		c.child = n
+	}
+	if c.Start != nil {
+		c.Start(n, nc)
+	} else {
+		Go(n, nc)
+	}
+}
+
+// StartAll finds all organisms driven by Cpu instances, and uses start to
+// begin executing each Cpu instance found.  Each Cpu will start its offspring
+// the same way.  If start is nil, Go is used.
+func StartAll(g grid2d.Grid, start StartFunc) {
+	if start == nil {
+		start = Go
+	}
 	var locs []grid2d.Point
 	g.Locations(&locs)
 	for _, p := range locs {
 		if o, ok := p.V.(*org.Organism); ok {
 			if c, ok := o.Driver.(*Cpu); ok {
-				go c.Run(o)
+				c.Start = start
+				start(o, c)
 			}
 		}
 	}
@@ -0,0 +1,133 @@
+package cpu1
+
+import "fmt"
+import "sort"
+import "strconv"
+
+import "github.com/dnesting/alife/goalife/grid2d/org"
+
+// Debugger executes a single Cpu driving an Organism one step at a time,
+// stopping at breakpoints set on instruction addresses or opcodes.
+type Debugger struct {
+	O     *org.Organism
+	C     *Cpu
+	Steps int   // the number of steps executed so far
+	Err   error // the error that ended execution, if it has ended
+
+	atIp map[int]bool
+	atOp map[string]bool
+}
+
+// NewDebugger returns a Debugger executing c on behalf of o.  Offspring
+// are placed in the world but not executed.
+func NewDebugger(o *org.Organism, c *Cpu) *Debugger {
+	c.Start = func(o *org.Organism, c *Cpu) {}
+	return &Debugger{
+		O:    o,
+		C:    c,
+		atIp: make(map[int]bool),
+		atOp: make(map[string]bool),
+	}
+}
+
+// Next returns the address of the instruction the Cpu will execute next,
+// and the instruction, which is nil if there is no valid instruction there.
+func (d *Debugger) Next() (int, *Op) {
+	return d.C.peek()
+}
+
+// Break sets a breakpoint, given as either an instruction address or the
+// name of an opcode.
+func (d *Debugger) Break(spec string) error {
+	if ip, err := strconv.Atoi(spec); err == nil {
+		d.atIp[ip] = true
+		return nil
+	}
+	for _, op := range Ops {
+		if op.Name == spec {
+			d.atOp[spec] = true
+			return nil
+		}
+	}
+	return fmt.Errorf("%q is neither an address nor an opcode", spec)
+}
+
+// Clear removes the breakpoint set with Break(spec).
+func (d *Debugger) Clear(spec string) error {
+	if ip, err := strconv.Atoi(spec); err == nil && d.atIp[ip] {
+		delete(d.atIp, ip)
+		return nil
+	}
+	if d.atOp[spec] {
+		delete(d.atOp, spec)
+		return nil
+	}
+	return fmt.Errorf("no breakpoint %q", spec)
+}
+
+// Breakpoints returns the breakpoints set, addresses first.
+func (d *Debugger) Breakpoints() []string {
+	var ips []int
+	for ip := range d.atIp {
+		ips = append(ips, ip)
+	}
+	sort.Ints(ips)
+	var ops []string
+	for op := range d.atOp {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+
+	var bps []string
+	for _, ip := range ips {
+		bps = append(bps, strconv.Itoa(ip))
+	}
+	return append(bps, ops...)
+}
+
+// AtBreakpoint returns true if the next instruction has a breakpoint.
+func (d *Debugger) AtBreakpoint() bool {
+	ip, op := d.Next()
+	return d.atIp[ip] || (op != nil && d.atOp[op.Name])
+}
+
+// Step executes a single instruction.  If this ends execution, the
+// organism dies and the error is returned and saved in Err.
+func (d *Debugger) Step() error {
+	if d.Err != nil {
+		return d.Err
+	}
+	d.Steps++
+	if err := d.C.Step(d.O); err != nil {
+		d.Err = err
+		d.O.DieOf(err)
+		return err
+	}
+	return nil
+}
+
+// Continue executes instructions until the next instruction has a
+// breakpoint, execution ends or max instructions have been executed.
+// Returns the number of instructions executed.
+func (d *Debugger) Continue(max int) (int, error) {
+	for n := 0; n < max; n++ {
+		if err := d.Step(); err != nil {
+			return n + 1, err
+		}
+		if d.AtBreakpoint() {
+			return n + 1, nil
+		}
+	}
+	return max, nil
+}
+
+// String describes the state of the Cpu and Organism.
+func (d *Debugger) String() string {
+	ip, op := d.Next()
+	name := "(invalid)"
+	if op != nil {
+		name = op.Name
+	}
+	return fmt.Sprintf("step %d: ip=%d %-11s A=%d B=%d C=%d D=%d energy=%d dir=%c",
+		d.Steps, ip, name, d.C.R[0], d.C.R[1], d.C.R[2], d.C.R[3], d.O.Energy(), d.O.Arrow())
+}
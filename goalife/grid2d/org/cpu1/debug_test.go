@@ -0,0 +1,59 @@
+package cpu1
+
+import "reflect"
+import "testing"
+
+import "github.com/dnesting/alife/goalife/grid2d"
+import "github.com/dnesting/alife/goalife/grid2d/org"
+
+func TestDebugger(t *testing.T) {
+	code, err := Ops.Compile([]string{"L1", "Inc", "Inc", "Right", "JumpR1"})
+	if err != nil {
+		t.Fatalf("error compiling: %v", err)
+	}
+	g := grid2d.New(3, 3, nil)
+	c := &Cpu{Code: code}
+	o := org.Random()
+	o.Driver = c
+	o.AddEnergy(30)
+	g.Put(1, 1, o, grid2d.PutAlways)
+
+	d := NewDebugger(o, c)
+	if err := d.Break("Right"); err != nil {
+		t.Errorf("error setting breakpoint: %v", err)
+	}
+	if err := d.Break("2"); err != nil {
+		t.Errorf("error setting breakpoint: %v", err)
+	}
+	if err := d.Break("Bogus"); err == nil {
+		t.Errorf("expected an error setting a breakpoint on an unknown opcode")
+	}
+	if expected := []string{"2", "Right"}; !reflect.DeepEqual(d.Breakpoints(), expected) {
+		t.Errorf("expected breakpoints %v got %v", expected, d.Breakpoints())
+	}
+
+	if n, err := d.Continue(100); n != 2 || err != nil {
+		t.Errorf("expected to stop after 2 steps got %d (err=%v)", n, err)
+	}
+	if ip, _ := d.Next(); ip != 2 || c.R[0] != 1 {
+		t.Errorf("expected to stop at 2 with A=1, got %d with A=%d", ip, c.R[0])
+	}
+	if n, _ := d.Continue(100); n != 1 {
+		t.Errorf("expected to stop after 1 step got %d", n)
+	}
+	if _, op := d.Next(); op == nil || op.Name != "Right" {
+		t.Errorf("expected to stop at Right got %v", op)
+	}
+
+	d.Clear("2")
+	d.Clear("Right")
+	if _, err := d.Continue(100); err != org.ErrNoEnergy {
+		t.Errorf("expected the organism to run out of energy, got %v", err)
+	}
+	if d.Err != org.ErrNoEnergy || d.Step() != org.ErrNoEnergy {
+		t.Errorf("expected execution to remain stopped")
+	}
+	if _, ok := g.Get(1, 1).Value().(*org.Organism); ok {
+		t.Errorf("expected the organism to have died")
+	}
+}
@@ -0,0 +1,65 @@
+package cpu1
+
+import "fmt"
+
+import "github.com/dnesting/alife/goalife/grid2d"
+import "github.com/dnesting/alife/goalife/grid2d/org"
+
+// Remap translates code written for an op table whose instruction names
+// were from into the equivalent code for ops, matching instructions by
+// name.  Instructions that ops no longer has (and bytes that were never
+// valid instructions) are replaced with the first instruction in ops (a
+// no-op in Ops), so that the code keeps its length and labels keep their
+// positions.  Returns the new code and the names of the instructions that
+// were replaced, one per occurrence.
+func Remap(code Bytecode, from []string, ops OpTable) (Bytecode, []string) {
+	index := make(map[string]byte, len(ops))
+	for i, op := range ops {
+		index[op.Name] = byte(i)
+	}
+	// Work out what each possible byte becomes up front, by itself, so that
+	// an invalid or missing instruction doesn't spoil the rest.
+	var names [256]string
+	var to [256]byte
+	var ok [256]bool
+	for b := range names {
+		if b < len(from) {
+			names[b] = from[b]
+		} else {
+			names[b] = fmt.Sprintf("%#02x", b)
+		}
+		to[b], ok[b] = index[names[b]]
+	}
+
+	var missing []string
+	remapped := make(Bytecode, len(code))
+	for i, b := range code {
+		if ok[b] {
+			remapped[i] = to[b]
+		} else {
+			missing = append(missing, names[b])
+		}
+	}
+	return remapped, missing
+}
+
+// Migrate remaps the Code of every Cpu in g, which was saved with an op
+// table whose instruction names were from, to Ops.  Returns the number of
+// each missing instruction replaced, keyed by name.
+func Migrate(g grid2d.Grid, from []string) map[string]int {
+	missing := make(map[string]int)
+	var points []grid2d.Point
+	g.Locations(&points)
+	for _, p := range points {
+		if o, ok := p.V.(*org.Organism); ok {
+			if c, ok := o.Driver.(*Cpu); ok {
+				var m []string
+				c.Code, m = Remap(c.Code, from, Ops)
+				for _, name := range m {
+					missing[name]++
+				}
+			}
+		}
+	}
+	return missing
+}
@@ -0,0 +1,17 @@
+package cpu1
+
+import "reflect"
+import "testing"
+
+func TestRemap(t *testing.T) {
+	from := []string{"XXX", "Inc", "Gone", "Dec"}
+	ops := OpTable{{Name: "XXX"}, {Name: "Dec"}, {Name: "Inc"}}
+
+	code, missing := Remap(Bytecode{1, 3, 2, 9, 1}, from, ops)
+	if expected := (Bytecode{2, 1, 0, 0, 2}); !reflect.DeepEqual(code, expected) {
+		t.Errorf("expected %v got %v", expected, code)
+	}
+	if expected := []string{"Gone", "0x09"}; !reflect.DeepEqual(missing, expected) {
+		t.Errorf("expected missing %v got %v", expected, missing)
+	}
+}
@@ -1,22 +1,48 @@
 package cpu1
 
 import "errors"
-import "math/rand"
 
 import "github.com/dnesting/alife/goalife/grid2d/org"
+import "github.com/dnesting/alife/goalife/mutation"
+import "github.com/dnesting/alife/goalife/physics"
+import "github.com/dnesting/alife/goalife/util/rng"
+import "github.com/dnesting/alife/goalife/util/schema"
 
 // MutationRate specifies the rate at which mutations occur during a Divide operation.
 var MutationRate = 0.01
 
+// crossoverPoints is the number of crossover points used by the Mate
+// operation (see SetCrossoverPoints).
+var crossoverPoints = 0
+
 // ErrDivisionByZero is reported when an opcode would result in division by zero.
 var ErrDivisionByZero = errors.New("division by zero")
 
 // ops contains the actual optable for cpu1.
 var Ops OpTable
 
+// allOps contains every operation, including those only in Ops when enabled.
+// Ops is a prefix of it, so they share their costs.
+var allOps OpTable
+
+// TableName names Ops in the headers of saved files.
+const TableName = "cpu1.Ops"
+
+// legacyNames lists the names of Ops as they were before saved files
+// recorded them.
+var legacyNames = []string{
+	"XXX", "L1", "L2", "L3", "L4",
+	"Jump1", "Jump2", "Jump3", "Jump4", "JumpR1", "JumpR2", "JumpR3", "JumpR4",
+	"SwapAB", "SwapAC", "SwapAD", "Zero", "Shl0", "Shl1", "Shr", "Inc", "Dec",
+	"Add", "Sub", "Div", "Mul", "And", "Or", "Xor", "Mod",
+	"IfEq", "IfNe", "IfGt", "IfLt", "IfZ", "IfNZ", "IfLoop",
+	"Jump", "Eat", "Left", "Right", "Forward", "Divide", "Sense", "SenseOthers",
+}
+
 func init() {
-	// Note: Modifying opcodes risks making any organisms saved by the census nonviable.
-	Ops = OpTable([]Op{
+	// Note: Modifying opcodes makes any organisms saved by the census or
+	// autosave incompatible; see util/schema.
+	allOps = OpTable([]Op{
 		Op{"XXX", opNoop, 0},
 
 		// L1-L4 represent labels used by opJumpN and opJumpRN opcodes.
@@ -75,7 +101,48 @@ func init() {
 		Op{"Divide", opDivide, 0},
 		Op{"Sense", opSense, 0},
 		Op{"SenseOthers", opSenseOthers, 0},
+
+		// Mate is only in Ops if recombination is enabled.
+		Op{"Mate", opMate, 0},
 	})
+	SetCrossoverPoints(0)
+	schema.Register(TableName, func() []string { return Ops.Names() }, legacyNames)
+
+	for _, op := range allOps {
+		defaultCosts = append(defaultCosts, op.Cost)
+	}
+	physics.RegisterCosts(TableName, allOps.Names, setCosts)
+}
+
+// SetCrossoverPoints sets the number of crossover points (1 or 2) used by
+// the Mate operation, adding Mate to Ops, or if points is 0, removes Mate
+// from Ops, leaving the opcode table as it was before Mate existed.  Since
+// this changes the opcode table, worlds saved with recombination enabled can
+// only be loaded with it enabled.  It must be called before any organisms
+// begin executing.
+func SetCrossoverPoints(points int) {
+	crossoverPoints = points
+	if points > 0 {
+		Ops = allOps
+	} else {
+		Ops = allOps[:len(allOps)-1]
+	}
+}
+
+// defaultCosts holds the cost of each of allOps before any physics.Physics
+// changed them.
+var defaultCosts []int
+
+// setCosts sets the cost of each of allOps to its cost in costs, if any, or
+// else its default.
+func setCosts(costs map[string]int) {
+	for i := range allOps {
+		if cost, ok := costs[allOps[i].Name]; ok {
+			allOps[i].Cost = cost
+		} else {
+			allOps[i].Cost = defaultCosts[i]
+		}
+	}
 }
 
 // opSwapAB: A, B = B, A
@@ -321,13 +388,58 @@ func opIfNZ(o *org.Organism, c *Cpu) error {
 // opDivide: spawn a new organism in neighboring cell with same bytecode
 // and energy fraction described by A/256.
 func opDivide(o *org.Organism, c *Cpu) error {
-	lenc := len(c.Code)
-	if err := o.Discharge(lenc); err != nil {
-		return err
+	nc := c.Copy()
+	var m *mutation.Mutation
+	if rng.Float64() < MutationRate {
+		m = nc.Mutate()
+	}
+	return c.divide(o, nc, m)
+}
+
+// opMate: like Divide, but the new organism's bytecode is a crossover of
+// this organism's and that of a neighboring organism driven by a Cpu, if
+// there is one (see SetCrossoverPoints), which is then mutated as usual.
+func opMate(o *org.Organism, c *Cpu) error {
+	mate := c.mate(o)
+	if mate == nil || crossoverPoints == 0 {
+		return opDivide(o, c)
 	}
 	nc := c.Copy()
-	if rand.Float64() < MutationRate {
-		nc.Mutate()
+	m := nc.Cross(mate, crossoverPoints)
+	if rng.Float64() < MutationRate {
+		// A mutation event records the crossover, if there was one, but
+		// Mutation describes both.
+		if pm := nc.Mutate(); m == nil {
+			m = pm
+		} else if pm != nil {
+			nc.Mutation = m.String() + ", " + pm.String()
+		}
+	}
+	return c.divide(o, nc, m)
+}
+
+// mate returns the Cpu driving a random neighbor of o, other than the one in
+// front of it where its offspring would go, or nil if there is none.
+func (c *Cpu) mate(o *org.Organism) *Cpu {
+	var mates []*Cpu
+	for _, v := range o.Neighbors()[1:] {
+		if n, ok := v.(*org.Organism); ok {
+			if nc, ok := n.Driver.(*Cpu); ok {
+				mates = append(mates, nc)
+			}
+		}
+	}
+	if len(mates) == 0 {
+		return nil
+	}
+	return mates[rng.Intn(len(mates))]
+}
+
+// divide spawns o's offspring driven by nc, which has been varied from c by
+// m, if not nil.
+func (c *Cpu) divide(o *org.Organism, nc *Cpu, m *mutation.Mutation) error {
+	if err := o.Discharge(len(c.Code)); err != nil {
+		return err
 	}
 	n, err := o.Divide(nc, float64(c.R[0])/256.0)
 	if err == org.ErrNotEmpty {
@@ -336,7 +448,11 @@ func opDivide(o *org.Organism, c *Cpu) error {
 	if err != nil {
 		return err
 	}
-	go nc.Run(n)
+	c.event.Resolve(true)
+	if m != nil && nc.Hash() != nc.Parent {
+		nc.event = mutation.Born(nc.Parent, nc.Hash(), m)
+	}
+	c.start(n, nc)
 	return nil
 }
 
@@ -0,0 +1,82 @@
+package cpu1
+
+import "bytes"
+import "strings"
+import "testing"
+
+import "github.com/dnesting/alife/goalife/grid2d"
+import "github.com/dnesting/alife/goalife/grid2d/org"
+
+func TestMate(t *testing.T) {
+	defer func(r float64) { MutationRate = r; SetCrossoverPoints(0) }(MutationRate)
+	MutationRate = 0
+
+	// Without recombination, the opcode table is as it was before Mate.
+	SetCrossoverPoints(0)
+	if names := Ops.Names(); names[len(names)-1] != "SenseOthers" {
+		t.Errorf("expected Mate not to be in Ops by default got %v", names)
+	}
+	SetCrossoverPoints(1)
+
+	code, err := Ops.Compile([]string{"Mate", "L1", "L1", "L1", "L1", "L1", "L1", "L1"})
+	if err != nil {
+		t.Fatalf("error compiling: %v", err)
+	}
+	mateCode, _ := Ops.Compile([]string{"L2", "L2", "L2", "L2", "L2", "L2", "L2", "L2"})
+
+	g := grid2d.New(5, 5, nil)
+	var child *Cpu
+	start := func(o *org.Organism, c *Cpu) { child = c }
+	c := &Cpu{Code: code, Start: start}
+	o := &org.Organism{Driver: c}
+	o.AddEnergy(1000000)
+	g.Put(1, 1, o, grid2d.PutAlways)
+	mate := &Cpu{Code: mateCode}
+	g.Put(0, 1, &org.Organism{Driver: mate}, grid2d.PutAlways)
+
+	// Mate divides into the cell in front, to the east.
+	mateOnce := func() *Cpu {
+		child = nil
+		c.Ip = 0
+		if err := c.Step(o); err != nil {
+			t.Fatalf("error stepping: %v", err)
+		}
+		g.Remove(2, 1)
+		return child
+	}
+
+	crossoverPoints = 0
+	if nc := mateOnce(); nc == nil || !bytes.Equal(nc.Code, code) || nc.Mate != 0 {
+		t.Errorf("expected a clone without crossover got %v", nc)
+	}
+
+	crossoverPoints = 1
+	MutationRate = 1
+	mutated := false
+	for i := 0; i < 20 && !mutated; i++ {
+		nc := mateOnce()
+		mutated = nc != nil && nc.Mate != 0 && strings.Contains(nc.Mutation, ", ")
+	}
+	if !mutated {
+		t.Errorf("expected recombined offspring to be mutated")
+	}
+	MutationRate = 0
+	for i := 0; i < 20; i++ {
+		nc := mateOnce()
+		if nc == nil {
+			t.Fatalf("expected an offspring")
+		}
+		if nc.Mate == 0 {
+			continue
+		}
+		if nc.Mate != mate.Hash() || nc.Parent != c.Hash() || nc.Mutation == "" {
+			t.Errorf("expected offspring of %x and %x got %+v", c.Hash(), mate.Hash(), nc)
+		}
+		i := bytes.IndexByte(nc.Code, mateCode[0])
+		if i < 0 || !bytes.Equal(nc.Code[:i], code[:i]) || !bytes.Equal(nc.Code[i:], mateCode[i:]) {
+			t.Errorf("expected a crossover of %v and %v got %v", code, mateCode, nc.Code)
+		}
+		return
+	}
+	t.Errorf("expected some offspring to be recombined")
+}
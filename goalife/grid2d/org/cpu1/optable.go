@@ -9,7 +9,7 @@ import "github.com/dnesting/alife/goalife/grid2d/org"
 type Op struct {
 	Name string                              // the symbolic name of the instruction
 	Fn   func(o *org.Organism, c *Cpu) error // what gets executed for this instruction
-	Cost int                                 // the instruction's energy cost (above the default 1)
+	Cost int                                 // the instruction's energy cost (above physics.Physics.StepCost)
 }
 
 func (o Op) String() string {
@@ -22,6 +22,15 @@ func (ops OpTable) Len() int {
 	return len([]Op(ops))
 }
 
+// Names returns the names of the instructions in ops, in order.
+func (ops OpTable) Names() []string {
+	names := make([]string, len(ops))
+	for i, op := range ops {
+		names[i] = op.Name
+	}
+	return names
+}
+
 type UnknownOpErr struct {
 	V interface{}
 }
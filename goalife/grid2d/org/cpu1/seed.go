@@ -0,0 +1,48 @@
+package cpu1
+
+import "fmt"
+import "os"
+
+import "github.com/dnesting/alife/goalife/util/rng"
+
+// Seeder creates the Cpus of organisms introduced into the world from
+// outside, such as its initial population, from a set of ancestor programs
+// mixed with random bytecode.
+type Seeder struct {
+	Ancestors []Bytecode // ancestor programs, chosen among uniformly
+	Random    float64    // fraction of Cpus given random bytecode instead
+}
+
+// Cpu returns a new Cpu running a copy of one of the ancestors, or random
+// bytecode with probability Random.  Without ancestors, the bytecode is
+// always random.
+func (s *Seeder) Cpu() *Cpu {
+	if s == nil || len(s.Ancestors) == 0 || rng.Float64() < s.Random {
+		return Random()
+	}
+	a := s.Ancestors[rng.Intn(len(s.Ancestors))]
+	code := make(Bytecode, len(a))
+	copy(code, a)
+	return &Cpu{Code: code}
+}
+
+// LoadAncestors assembles each of the named source files with Ops.
+func LoadAncestors(filenames ...string) ([]Bytecode, error) {
+	var ancestors []Bytecode
+	for _, name := range filenames {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		code, err := Ops.Assemble(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		if len(code) == 0 {
+			return nil, fmt.Errorf("%s: no instructions", name)
+		}
+		ancestors = append(ancestors, code)
+	}
+	return ancestors, nil
+}
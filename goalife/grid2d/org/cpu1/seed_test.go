@@ -0,0 +1,73 @@
+package cpu1
+
+import "bytes"
+import "testing"
+
+import "github.com/dnesting/alife/goalife/grid2d"
+import "github.com/dnesting/alife/goalife/grid2d/food"
+import "github.com/dnesting/alife/goalife/grid2d/org"
+import "github.com/dnesting/alife/goalife/grid2d/sched"
+import "github.com/dnesting/alife/goalife/util/rng"
+
+func TestSeeder(t *testing.T) {
+	a := Bytecode{1, 2, 3}
+	s := &Seeder{Ancestors: []Bytecode{a}}
+	for i := 0; i < 10; i++ {
+		c := s.Cpu()
+		if !bytes.Equal(c.Code, a) {
+			t.Errorf("expected ancestor %v got %v", a, c.Code)
+		}
+		c.Code[0] = 9
+	}
+	if a[0] != 1 {
+		t.Errorf("ancestor should not share its code with the Cpus seeded from it")
+	}
+
+	s.Random = 1
+	if c := s.Cpu(); bytes.Equal(c.Code, a) {
+		t.Errorf("expected random code got the ancestor")
+	}
+	var none *Seeder
+	if c := none.Cpu(); len(c.Code) == 0 {
+		t.Errorf("expected random code from a nil Seeder")
+	}
+}
+
+func TestReplicator(t *testing.T) {
+	defer func(r float64) { MutationRate = r }(MutationRate)
+	MutationRate = 0
+	rng.Seed(1)
+
+	ancestors, err := LoadAncestors("../../../ancestors/replicator.s")
+	if err != nil {
+		t.Fatalf("error loading ancestor: %v", err)
+	}
+	s := &Seeder{Ancestors: ancestors}
+	g := grid2d.New(20, 20, nil)
+	sc := sched.New(sched.Shuffled, 1)
+	start := func(o *org.Organism, c *Cpu) { sc.Add(o, c) }
+	for i := 0; i < 5; i++ {
+		c := s.Cpu()
+		c.Start = start
+		o := org.Random()
+		o.Driver = c
+		o.AddEnergy(10000)
+		g.PutRandomly(o, grid2d.PutWhenNil)
+		start(o, c)
+	}
+	for i := 0; i < 50; i++ {
+		g.PutRandomly(food.New(2000), grid2d.PutWhenNil)
+	}
+
+	before := org.CountActions()
+	for i := 0; i < 2000 && sc.Len() > 0; i++ {
+		sc.Round()
+	}
+	a := org.CountActions()
+	if divides := a.Divides - before.Divides; divides < 50 {
+		t.Errorf("expected the replicator to divide at least 50 times got %d", divides)
+	}
+	if eats := a.Eats - before.Eats; eats == 0 {
+		t.Errorf("expected the replicator to eat")
+	}
+}
@@ -0,0 +1,186 @@
+package cpu1
+
+import "encoding/json"
+import "io"
+import "math/rand"
+import "sync"
+
+import "github.com/dnesting/alife/goalife/grid2d/org"
+
+// TraceState describes the state of a Cpu and its Organism.
+type TraceState struct {
+	Ip     int
+	R      [4]int
+	Energy int
+	X, Y   int
+	Dir    int
+}
+
+// TraceChild describes an offspring created by a step.
+type TraceChild struct {
+	X, Y int
+	Hash uint64
+}
+
+// TraceStep describes a single step executed by a traced Cpu.
+type TraceStep struct {
+	Org    uint64 // identifies the organism within a trace
+	N      int    // the number of steps the organism has executed in the trace
+	Hash   uint64 // the organism's genome
+	Op     string // the instruction executed, or empty if it was invalid
+	Before TraceState
+	After  TraceState
+	Child  *TraceChild `json:",omitempty"`
+	Err    string      `json:",omitempty"` // the error that ended execution
+}
+
+// TraceFunc receives a description of each step executed by a Cpu.
+type TraceFunc func(s *TraceStep)
+
+// peek returns the address of the instruction the Cpu will execute next, and
+// the instruction, which is nil if there is no valid instruction there.
+func (c *Cpu) peek() (int, *Op) {
+	if len(c.Code) == 0 {
+		return 0, nil
+	}
+	ip := c.Ip % len(c.Code)
+	if ip < 0 || int(c.Code[ip]) >= len(Ops) {
+		return ip, nil
+	}
+	return ip, &Ops[c.Code[ip]]
+}
+
+func (c *Cpu) state(o *org.Organism) TraceState {
+	x, y, _ := o.Position()
+	return TraceState{Ip: c.Ip, R: c.R, Energy: o.Energy(), X: x, Y: y, Dir: o.Dir}
+}
+
+// traceStep executes Step, and describes it to c.Trace.
+func (c *Cpu) traceStep(o *org.Organism) error {
+	s := TraceStep{Hash: c.Hash(), Before: c.state(o)}
+	if _, op := c.peek(); op != nil {
+		s.Op = op.Name
+	}
+	err := c.step(o)
+	s.After = c.state(o)
+	if n := c.child; n != nil {
+		c.child = nil
+		s.Child = &TraceChild{}
+		s.Child.X, s.Child.Y, _ = n.Position()
+		if nc, ok := n.Driver.(*Cpu); ok {
+			s.Child.Hash = nc.Hash()
+		}
+	}
+	if err != nil {
+		s.Err = err.Error()
+	}
+	c.Trace(&s)
+	return err
+}
+
+// TraceSelector decides whether to trace an organism as it starts.
+type TraceSelector func(o *org.Organism, c *Cpu) bool
+
+// TraceHashes selects organisms whose genomes have any of the given hashes.
+func TraceHashes(hashes ...uint64) TraceSelector {
+	return func(o *org.Organism, c *Cpu) bool {
+		h := c.Hash()
+		for _, v := range hashes {
+			if h == v {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// TraceRegion selects organisms starting within the rectangle from x0,y0 to
+// x1,y1 inclusive.
+func TraceRegion(x0, y0, x1, y1 int) TraceSelector {
+	return func(o *org.Organism, c *Cpu) bool {
+		x, y, ok := o.Position()
+		return ok && x >= x0 && x <= x1 && y >= y0 && y <= y1
+	}
+}
+
+// TraceSample selects the given fraction of organisms at random.  It uses
+// its own source of randomness, seeded with seed, so that tracing does not
+// disturb an otherwise reproducible run.
+func TraceSample(frac float64, seed int64) TraceSelector {
+	var mu sync.Mutex
+	r := rand.New(rand.NewSource(seed))
+	return func(o *org.Organism, c *Cpu) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return r.Float64() < frac
+	}
+}
+
+// TraceAny selects organisms selected by any of sels.
+func TraceAny(sels ...TraceSelector) TraceSelector {
+	return func(o *org.Organism, c *Cpu) bool {
+		for _, sel := range sels {
+			if sel(o, c) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Tracer records every step executed by selected organisms to a writer, as
+// a stream of JSON-encoded TraceStep values.
+type Tracer struct {
+	Select TraceSelector // selects organisms to trace; if nil, all are
+
+	mu   sync.Mutex
+	enc  *json.Encoder
+	next uint64
+	err  error
+}
+
+// NewTracer creates a Tracer writing to w the steps of organisms selected
+// by sel.
+func NewTracer(w io.Writer, sel TraceSelector) *Tracer {
+	return &Tracer{Select: sel, enc: json.NewEncoder(w)}
+}
+
+// Start returns a StartFunc that begins tracing each Cpu that Select
+// selects before using start to begin executing it.  Since offspring are
+// started the same way as their parents, they are considered in turn.
+func (t *Tracer) Start(start StartFunc) StartFunc {
+	return func(o *org.Organism, c *Cpu) {
+		if t.Select == nil || t.Select(o, c) {
+			t.attach(c)
+		}
+		start(o, c)
+	}
+}
+
+func (t *Tracer) attach(c *Cpu) {
+	t.mu.Lock()
+	t.next++
+	id := t.next
+	t.mu.Unlock()
+	n := 0
+	c.Trace = func(s *TraceStep) {
+		n++
+		s.Org, s.N = id, n
+		t.record(s)
+	}
+}
+
+func (t *Tracer) record(s *TraceStep) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.err == nil {
+		t.err = t.enc.Encode(s)
+	}
+}
+
+// Err returns the first error encountered writing the trace, if any.
+func (t *Tracer) Err() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.err
+}
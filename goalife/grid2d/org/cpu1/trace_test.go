@@ -0,0 +1,70 @@
+package cpu1
+
+import "bytes"
+import "encoding/json"
+import "testing"
+
+import "github.com/dnesting/alife/goalife/grid2d"
+import "github.com/dnesting/alife/goalife/grid2d/org"
+
+func TestTracer(t *testing.T) {
+	defer func(r float64) { MutationRate = r }(MutationRate)
+	MutationRate = 0
+
+	code, err := Ops.Compile([]string{"Shl1", "Shl0", "Divide", "Right", "Right", "Forward"})
+	if err != nil {
+		t.Fatalf("error compiling: %v", err)
+	}
+	g := grid2d.New(5, 5, nil)
+	var b bytes.Buffer
+	tr := NewTracer(&b, TraceAny(TraceHashes(code.Hash()), TraceRegion(0, 0, 0, 0)))
+	var started []*Cpu
+	start := tr.Start(func(o *org.Organism, c *Cpu) { started = append(started, c) })
+
+	put := func(x, y int, code Bytecode) (*org.Organism, *Cpu) {
+		c := &Cpu{Code: code, Start: start}
+		o := &org.Organism{Driver: c}
+		o.AddEnergy(100000)
+		g.Put(x, y, o, grid2d.PutAlways)
+		start(o, c)
+		return o, c
+	}
+	o, c := put(1, 1, code)
+	_, other := put(3, 3, Bytecode{1, 2, 3})
+	_, corner := put(0, 0, Bytecode{1, 2, 3})
+	if c.Trace == nil || other.Trace != nil || corner.Trace == nil {
+		t.Fatalf("expected only the matching genome and the organism at 0,0 to be traced")
+	}
+	other.Step(o)
+	for i := 0; i < 6; i++ {
+		c.Step(o)
+	}
+	if len(started) != 4 || started[3].Trace == nil {
+		t.Errorf("expected the offspring to be started and traced, got %v", started)
+	}
+
+	dec := json.NewDecoder(&b)
+	var steps []TraceStep
+	for dec.More() {
+		var s TraceStep
+		if err := dec.Decode(&s); err != nil {
+			t.Fatalf("error decoding trace: %v", err)
+		}
+		steps = append(steps, s)
+	}
+	if len(steps) != 6 {
+		t.Fatalf("expected 6 steps got %d: %v", len(steps), steps)
+	}
+	if s := steps[1]; s.Op != "Shl0" || s.N != 2 || s.Before.R[0] != 1 || s.After.R[0] != 2 {
+		t.Errorf("expected Shl0 as step 2 from A=1 to A=2, got %+v", s)
+	}
+	if s := steps[2]; s.Child == nil || s.Child.Hash != code.Hash() || s.Child.X == 1 && s.Child.Y == 1 || s.After.Energy >= s.Before.Energy {
+		t.Errorf("expected Divide to record an offspring and lose energy, got %+v", s)
+	}
+	if s := steps[5]; s.Op != "Forward" || s.Before.X == s.After.X && s.Before.Y == s.After.Y {
+		t.Errorf("expected Forward to record a move, got %+v", s)
+	}
+	if tr.Err() != nil {
+		t.Errorf("unexpected error writing trace: %v", tr.Err())
+	}
+}
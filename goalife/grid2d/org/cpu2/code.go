@@ -0,0 +1,76 @@
+package cpu2
+
+import "hash/fnv"
+
+import "github.com/dnesting/alife/goalife/mutation"
+import "github.com/dnesting/alife/goalife/util/rng"
+
+// Bytecode represents the instructions the Cpu should execute.
+type Bytecode []byte
+
+// Hash identifies the bytecode.  This uses a 64-bit hash, unlike cpu1, so
+// that a cpu2 genome is unlikely to share a census key with a cpu1 genome
+// made of the same bytes.
+func (c Bytecode) Hash() uint64 {
+	h := fnv.New64a()
+	h.Write(c)
+	return h.Sum64()
+}
+
+func (c Bytecode) Len() int {
+	return len(c)
+}
+
+// RandLengthMax is the maximum length of randomly-generated code.
+var RandLengthMax = 1000
+
+// RandLengthMin is the minimum length of randomly-generated code.
+var RandLengthMin = 50
+
+// RandomBytecode returns randomly-generated bytecode that is plausibly
+// executable.
+func RandomBytecode(ops OpTable) Bytecode {
+	s := rng.Intn(RandLengthMax-RandLengthMin) + RandLengthMin
+	d := make([]byte, s)
+	maxOp := ops.Len()
+	for i := 0; i < s; i++ {
+		d[i] = byte(rng.Intn(maxOp))
+	}
+	return Bytecode(d)
+}
+
+// Mutate randomly mutates the code, making a mutation of a kind chosen by
+// the current mutation.Scheme (by default, a single instruction change, or
+// the duplication or deletion of a segment).  Returns a description of the
+// mutation, or nil if the code was left unchanged.
+func (c *Bytecode) Mutate(ops OpTable) *mutation.Mutation {
+	m := mutation.Mutate([]byte(*c), ops.Len())
+	if m != nil {
+		*c = Bytecode(m.Apply(*c))
+	}
+	return m
+}
+
+// find locates the given value in the code, searching forward from start
+// and wrapping around.  Returns start if it is not found.
+func (c Bytecode) find(value byte, start int) int {
+	for i := 0; i < len(c); i++ {
+		j := (start + i) % len(c)
+		if c[j] == value {
+			return j
+		}
+	}
+	return start
+}
+
+// findBackward locates the given value in the code, searching backward from
+// start and wrapping around.  Returns start if it is not found.
+func (c Bytecode) findBackward(value byte, start int) int {
+	for i := 1; i <= len(c); i++ {
+		j := ((start-i)%len(c) + len(c)) % len(c)
+		if c[j] == value {
+			return j
+		}
+	}
+	return start
+}
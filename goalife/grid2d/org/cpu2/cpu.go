@@ -0,0 +1,217 @@
+// Package cpu2 contains an implementation of an org.Organism driver that
+// drives the organism using a stack-based virtual machine.
+//
+// Compared with cpu1, the Cpu has a small data stack in place of registers,
+// a private scratch memory, a call stack supporting subroutines, and
+// instructions reporting what kind of occupant lies ahead of the organism
+// rather than only how much energy.  All values are 8 bits wide.
+package cpu2
+
+import "errors"
+import "fmt"
+
+import "github.com/dnesting/alife/goalife/grid2d"
+import "github.com/dnesting/alife/goalife/grid2d/org"
+import "github.com/dnesting/alife/goalife/log"
+import "github.com/dnesting/alife/goalife/mutation"
+import "github.com/dnesting/alife/goalife/physics"
+
+var Logger = log.Null()
+
+// StackSize is the number of values the data stack holds.  Pushing onto a
+// full stack discards its oldest value, and popping an empty stack yields 0.
+const StackSize = 16
+
+// MemSize is the number of values in the scratch memory.  Addresses wrap
+// around.
+const MemSize = 16
+
+// CallDepth is the number of return addresses the call stack holds.
+// Calling with a full call stack discards the oldest return address.
+const CallDepth = 8
+
+// Cpu is a simple 8-bit stack machine with scratch memory and associated
+// bytecode.
+type Cpu struct {
+	Ip    int // Instruction Pointer, an index into Code for the next instruction
+	Code  Bytecode
+	Stack []int        // the data stack, topmost value last
+	Mem   [MemSize]int // scratch memory, private to this Cpu
+	Calls []int        // return addresses, most recent last
+
+	// Parent is the hash of the Code of the Cpu this one was copied from, or
+	// 0 if it wasn't copied, and Mutation describes how Code was mutated from
+	// the parent's, if it was.  See package lineage.
+	Parent   uint64
+	Mutation string
+
+	// Start is invoked to begin executing the offspring of this Cpu.  It is
+	// inherited by each offspring, and is not saved.  If nil, Go is used.
+	Start StartFunc
+
+	event *mutation.Event // see MutationEvent
+}
+
+func (c *Cpu) String() string {
+	return fmt.Sprintf("[cpu2 %x ip=%d %v]", c.Code.Hash(), c.Ip, c.Stack)
+}
+
+// Copy returns a new Cpu with the same Code and Start, descended from c.  The Cpu's instruction
+// pointer, stacks and memory are not copied.
+func (c *Cpu) Copy() *Cpu {
+	return &Cpu{
+		Code:   c.Code,
+		Parent: c.Hash(),
+		Start:  c.Start,
+	}
+}
+
+// Mutate causes the Cpu's Code to be mutated, and records a description of
+// the mutation in Mutation.  Returns the mutation, or nil if Code was left
+// unchanged.
+func (c *Cpu) Mutate() *mutation.Mutation {
+	Logger.Printf("%v.Mutate()", c)
+	m := c.Code.Mutate(Ops)
+	if m != nil {
+		c.Mutation = m.String()
+	}
+	return m
+}
+
+// MutationEvent returns the mutation.Event recording the birth of this Cpu
+// as a mutant, if it is one and mutation.Events is set.
+func (c *Cpu) MutationEvent() *mutation.Event {
+	return c.event
+}
+
+// Origin returns the hash of the Code of the Cpu's parent, and the mutation
+// that produced its own Code, as recorded by Copy and Mutate.
+func (c *Cpu) Origin() (parent uint64, mutation string) {
+	return c.Parent, c.Mutation
+}
+
+// Hash identifies the Cpu by its bytecode, so that the census can track the
+// population running the same bytecode.
+func (c *Cpu) Hash() uint64 {
+	return c.Code.Hash()
+}
+
+// Random generates a Cpu with random bytecode.
+func Random() *Cpu {
+	return &Cpu{
+		Code: RandomBytecode(Ops),
+	}
+}
+
+// push pushes v onto the data stack, discarding the oldest value if it is
+// full.
+func (c *Cpu) push(v int) {
+	if len(c.Stack) >= StackSize {
+		c.Stack = append(c.Stack[:0], c.Stack[1:]...)
+	}
+	c.Stack = append(c.Stack, asUByte(v))
+}
+
+// pop removes and returns the topmost value of the data stack, or 0 if it is
+// empty.
+func (c *Cpu) pop() int {
+	n := len(c.Stack)
+	if n == 0 {
+		return 0
+	}
+	v := c.Stack[n-1]
+	c.Stack = c.Stack[:n-1]
+	return v
+}
+
+// top returns the topmost value of the data stack without removing it, or 0
+// if it is empty.
+func (c *Cpu) top() int {
+	if n := len(c.Stack); n > 0 {
+		return c.Stack[n-1]
+	}
+	return 0
+}
+
+var unableToReadErr = errors.New("unable to read next instruction")
+
+// Step executes one CPU operation.  Any error returned either assessing the
+// operation's energy cost or executing it will be returned by this method.
+// Execution is expected to cease (and the organism's DieOf method
+// invoked with the error) if an error is returned.
+func (c *Cpu) Step(o *org.Organism) error {
+	if len(c.Code) == 0 {
+		return unableToReadErr
+	}
+	c.Ip %= len(c.Code)
+	if c.Ip < 0 {
+		c.Ip += len(c.Code)
+	}
+	b := c.Code[c.Ip]
+	c.Ip++
+	if int(b) >= len(Ops) {
+		return unableToReadErr
+	}
+	op := &Ops[b]
+	Logger.Printf("%v.Step(%v): %v\n", c, o, op)
+
+	// Organisms age with each step, and may die of it.
+	if err := o.Tick(); err != nil {
+		return err
+	}
+
+	// All operations cost at least StepCost energy, to avoid infinite loops.
+	if err := o.Discharge(physics.Current().StepCost + op.Cost); err != nil {
+		return err
+	}
+	return op.Fn(o, c)
+}
+
+// Run executes Step repeatedly, until Step returns an error, at which point this
+// method will invoke o.DieOf with the error and return.
+func (c *Cpu) Run(o *org.Organism) error {
+	Logger.Printf("%v.Run(%v)\n", c, o)
+	for {
+		if err := c.Step(o); err != nil {
+			Logger.Printf("%v.Run: %v\n", c, err)
+			o.DieOf(err)
+			return err
+		}
+	}
+}
+
+// StartFunc begins executing c on behalf of o.
+type StartFunc func(o *org.Organism, c *Cpu)
+
+// Go runs c in its own goroutine.
+func Go(o *org.Organism, c *Cpu) {
+	go c.Run(o)
+}
+
+// start begins executing the offspring n, driven by nc, of c.
+func (c *Cpu) start(n *org.Organism, nc *Cpu) {
+	if c.Start != nil {
+		c.Start(n, nc)
+	} else {
+		Go(n, nc)
+	}
+}
+
+// StartAll finds all organisms driven by Cpu instances, and uses start to
+// begin executing each Cpu instance found.  Each Cpu will start its offspring
+// the same way.  If start is nil, Go is used.
+func StartAll(g grid2d.Grid, start StartFunc) {
+	if start == nil {
+		start = Go
+	}
+	var locs []grid2d.Point
+	g.Locations(&locs)
+	for _, p := range locs {
+		if o, ok := p.V.(*org.Organism); ok {
+			if c, ok := o.Driver.(*Cpu); ok {
+				c.Start = start
+				start(o, c)
+			}
+		}
+	}
+}
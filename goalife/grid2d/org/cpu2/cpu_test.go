@@ -0,0 +1,133 @@
+package cpu2
+
+import "reflect"
+import "testing"
+
+import "github.com/dnesting/alife/goalife/grid2d"
+import "github.com/dnesting/alife/goalife/grid2d/food"
+import "github.com/dnesting/alife/goalife/grid2d/org"
+
+// run places an organism facing east driven by prog in g at x,y and executes
+// it for steps steps.
+func run(t *testing.T, g grid2d.Grid, x, y int, prog []string, steps int) (*org.Organism, *Cpu) {
+	code, err := Ops.Compile(prog)
+	if err != nil {
+		t.Fatalf("error compiling: %v", err)
+	}
+	c := &Cpu{Code: code, Start: func(o *org.Organism, c *Cpu) {}}
+	o := &org.Organism{Driver: c}
+	o.AddEnergy(10000)
+	g.Put(x, y, o, grid2d.PutAlways)
+	for i := 0; i < steps; i++ {
+		if err := c.Step(o); err != nil {
+			t.Fatalf("step %d of %v: %v", i, prog, err)
+		}
+	}
+	return o, c
+}
+
+func TestStack(t *testing.T) {
+	cases := []struct {
+		prog     []string
+		expected []int
+	}{
+		{[]string{"Push1", "Shl1", "Shl0"}, []int{6}},
+		{[]string{"Push1", "Inc", "Dup", "Mul", "Push1", "Sub"}, []int{3}},
+		{[]string{"Push1", "Push0", "Swap"}, []int{0, 1}},
+		{[]string{"Push1", "Push0", "Over"}, []int{1, 0, 1}},
+		{[]string{"Push0", "Dec"}, []int{255}},
+		{[]string{"Drop", "Dup", "Dup", "Inc"}, []int{0, 1}},
+		// Mem[3] = 5, then Mem[3] + 1
+		{[]string{"Push1", "Shl0", "Shl1", "Push1", "Shl1", "Store", "Push1", "Shl1", "Load", "Inc"}, []int{6}},
+	}
+	for _, c := range cases {
+		_, cpu := run(t, grid2d.New(5, 5, nil), 0, 0, c.prog, len(c.prog))
+		if !reflect.DeepEqual(cpu.Stack, c.expected) {
+			t.Errorf("%v: expected %v got %v", c.prog, c.expected, cpu.Stack)
+		}
+	}
+
+	c := &Cpu{}
+	for i := 0; i < StackSize+2; i++ {
+		c.push(i)
+	}
+	if len(c.Stack) != StackSize || c.Stack[0] != 2 {
+		t.Errorf("expected a full stack to discard the oldest values, got %v", c.Stack)
+	}
+}
+
+func TestCall(t *testing.T) {
+	prog := []string{
+		"Call1", "Inc", "Jump2", // 0-2
+		"L1", "Inc", "Inc", "Ret", // 3-6
+		"L2", // 7
+	}
+	_, c := run(t, grid2d.New(5, 5, nil), 0, 0, prog, 8)
+	if !reflect.DeepEqual(c.Stack, []int{3}) || c.Ip != 8 || len(c.Calls) != 0 {
+		t.Errorf("expected the subroutine to run and return, got %v ip=%d calls=%v", c.Stack, c.Ip, c.Calls)
+	}
+
+	// IfZ pops its argument and skips the next instruction when non-zero.
+	_, c = run(t, grid2d.New(5, 5, nil), 0, 0, []string{"Push1", "IfZ", "Push1", "Push0"}, 3)
+	if !reflect.DeepEqual(c.Stack, []int{0}) {
+		t.Errorf("expected IfZ to skip, got %v", c.Stack)
+	}
+}
+
+func TestLook(t *testing.T) {
+	g := grid2d.New(10, 1, nil)
+	g.Put(3, 0, food.New(100), grid2d.PutAlways)
+	_, kin := run(t, g, 6, 0, []string{"XXX", "Look"}, 0)
+
+	// Facing east from 1,0: food at distance 2.
+	_, c := run(t, g, 1, 0, []string{"Look", "Scan"}, 2)
+	if expected := []int{KindEmpty, KindFood, 2}; !reflect.DeepEqual(c.Stack, expected) {
+		t.Errorf("expected %v got %v", expected, c.Stack)
+	}
+	// Facing east from 5,0: kin adjacent.
+	_, c = run(t, g, 5, 0, []string{"XXX", "Look"}, 2)
+	if expected := []int{KindKin}; c.Hash() != kin.Hash() || !reflect.DeepEqual(c.Stack, expected) {
+		t.Errorf("expected %v got %v", expected, c.Stack)
+	}
+	// Facing east from 4,0: another organism at distance 1.
+	_, c = run(t, g, 4, 0, []string{"Look"}, 1)
+	if expected := []int{KindOrganism}; !reflect.DeepEqual(c.Stack, expected) {
+		t.Errorf("expected %v got %v", expected, c.Stack)
+	}
+}
+
+func TestDivide(t *testing.T) {
+	defer func(r float64) { MutationRate = r }(MutationRate)
+	MutationRate = 0
+
+	g := grid2d.New(5, 5, nil)
+	prog := []string{"Push1", "Shl0", "Divide", "Push0", "Divide"}
+	o, c := run(t, g, 1, 1, prog, len(prog))
+	if !reflect.DeepEqual(c.Stack, []int{1, 0}) {
+		t.Errorf("expected one successful Divide, got %v", c.Stack)
+	}
+	n, ok := g.Get(2, 1).Value().(*org.Organism)
+	if !ok {
+		t.Fatalf("expected an offspring at 2,1, got %v", g.Get(2, 1))
+	}
+	if nc := n.Driver.(*Cpu); nc.Hash() != c.Hash() || n.Energy() == 0 || o.Energy() <= n.Energy() {
+		t.Errorf("expected a copy with a share of energy, got %v from %v", n, o)
+	}
+}
+
+func TestMutate(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		c := Random()
+		nc := c.Copy()
+		m := nc.Mutate()
+		if m == nil {
+			continue
+		}
+		if got := m.Apply(c.Code); !reflect.DeepEqual(Bytecode(got), nc.Code) {
+			t.Errorf("%v: expected %v got %v", m, nc.Code, got)
+		}
+		if nc.Parent != c.Hash() || nc.Mutation != m.String() {
+			t.Errorf("expected parent %x by %v got %x by %v", c.Hash(), m, nc.Parent, nc.Mutation)
+		}
+	}
+}
@@ -0,0 +1,482 @@
+package cpu2
+
+import "errors"
+
+import "github.com/dnesting/alife/goalife/grid2d/food"
+import "github.com/dnesting/alife/goalife/grid2d/org"
+import "github.com/dnesting/alife/goalife/mutation"
+import "github.com/dnesting/alife/goalife/physics"
+import "github.com/dnesting/alife/goalife/util/rng"
+import "github.com/dnesting/alife/goalife/util/schema"
+
+// MutationRate specifies the rate at which mutations occur during a Divide operation.
+var MutationRate = 0.01
+
+// ErrDivisionByZero is reported when an opcode would result in division by zero.
+var ErrDivisionByZero = errors.New("division by zero")
+
+// Ops contains the actual optable for cpu2.
+var Ops OpTable
+
+// TableName names Ops in the headers of saved files.
+const TableName = "cpu2.Ops"
+
+// The kinds of occupant reported by the Look and Scan instructions.
+const (
+	KindEmpty    = iota // nothing
+	KindFood            // food
+	KindKin             // an organism with the same genome
+	KindOrganism        // any other organism
+	KindOther           // anything else
+)
+
+func init() {
+	// Note: Modifying opcodes makes any organisms saved by the census or
+	// autosave incompatible; see util/schema.
+	Ops = OpTable([]Op{
+		Op{"XXX", opNoop, 0},
+
+		// L1-L4 represent labels used by the JumpN, JumpRN and CallN
+		// opcodes.  They must remain opcodes 1-4.
+		Op{"L1", opNoop, 0},
+		Op{"L2", opNoop, 0},
+		Op{"L3", opNoop, 0},
+		Op{"L4", opNoop, 0},
+
+		Op{"Jump1", jump(1), 0},
+		Op{"Jump2", jump(2), 0},
+		Op{"Jump3", jump(3), 0},
+		Op{"Jump4", jump(4), 0},
+
+		Op{"JumpR1", jumpBackward(1), 0},
+		Op{"JumpR2", jumpBackward(2), 0},
+		Op{"JumpR3", jumpBackward(3), 0},
+		Op{"JumpR4", jumpBackward(4), 0},
+
+		Op{"Call1", call(1), 0},
+		Op{"Call2", call(2), 0},
+		Op{"Call3", call(3), 0},
+		Op{"Call4", call(4), 0},
+		Op{"Ret", opRet, 0},
+
+		Op{"Push0", opPush0, 0},
+		Op{"Push1", opPush1, 0},
+		Op{"Dup", opDup, 0},
+		Op{"Drop", opDrop, 0},
+		Op{"Swap", opSwap, 0},
+		Op{"Over", opOver, 0},
+
+		Op{"Shl0", opShl0, 0},
+		Op{"Shl1", opShl1, 0},
+		Op{"Shr", opShr, 0},
+		Op{"Inc", opInc, 0},
+		Op{"Dec", opDec, 0},
+
+		Op{"Add", opAdd, 0},
+		Op{"Sub", opSub, 0},
+		Op{"Mul", opMul, 0},
+		Op{"Div", opDiv, 0},
+		Op{"Mod", opMod, 0},
+		Op{"And", opAnd, 0},
+		Op{"Or", opOr, 0},
+		Op{"Xor", opXor, 0},
+
+		Op{"Load", opLoad, 0},
+		Op{"Store", opStore, 0},
+
+		Op{"IfZ", opIfZ, 0},
+		Op{"IfNZ", opIfNZ, 0},
+		Op{"IfEq", opIfEq, 0},
+		Op{"IfLt", opIfLt, 0},
+		Op{"IfGt", opIfGt, 0},
+
+		Op{"Eat", opEat, 5},
+		Op{"Left", opLeft, 5},
+		Op{"Right", opRight, 5},
+		Op{"Forward", opForward, 10},
+		Op{"Divide", opDivide, 0},
+
+		Op{"Look", opLook, 1},
+		Op{"Scan", opScan, 5},
+		Op{"Energy", opEnergy, 0},
+	})
+	schema.Register(TableName, Ops.Names, nil)
+
+	for _, op := range Ops {
+		defaultCosts = append(defaultCosts, op.Cost)
+	}
+	physics.RegisterCosts(TableName, Ops.Names, setCosts)
+}
+
+// defaultCosts holds the cost of each of Ops before any physics.Physics
+// changed them.
+var defaultCosts []int
+
+// setCosts sets the cost of each of Ops to its cost in costs, if any, or
+// else its default.
+func setCosts(costs map[string]int) {
+	for i := range Ops {
+		if cost, ok := costs[Ops[i].Name]; ok {
+			Ops[i].Cost = cost
+		} else {
+			Ops[i].Cost = defaultCosts[i]
+		}
+	}
+}
+
+func asUByte(v int) int {
+	v = v % 256
+	if v < 0 {
+		v += 256
+	}
+	return v
+}
+
+func clip(v, min, max int) int {
+	if v > max {
+		v = max
+	}
+	if v < min {
+		v = min
+	}
+	return v
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// opNoop: No-op
+func opNoop(o *org.Organism, c *Cpu) error {
+	return nil
+}
+
+// jump: Jump forward to label n
+func jump(n byte) func(o *org.Organism, c *Cpu) error {
+	return func(o *org.Organism, c *Cpu) error {
+		c.Ip = c.Code.find(n, c.Ip)
+		return nil
+	}
+}
+
+// jumpBackward: Jump backward to label n
+func jumpBackward(n byte) func(o *org.Organism, c *Cpu) error {
+	return func(o *org.Organism, c *Cpu) error {
+		c.Ip = c.Code.findBackward(n, c.Ip)
+		return nil
+	}
+}
+
+// call: Push the address of the next instruction onto the call stack and
+// jump forward to label n
+func call(n byte) func(o *org.Organism, c *Cpu) error {
+	return func(o *org.Organism, c *Cpu) error {
+		if len(c.Calls) >= CallDepth {
+			c.Calls = append(c.Calls[:0], c.Calls[1:]...)
+		}
+		c.Calls = append(c.Calls, c.Ip)
+		c.Ip = c.Code.find(n, c.Ip)
+		return nil
+	}
+}
+
+// opRet: Pop an address from the call stack and continue there, or continue
+// with the next instruction if the call stack is empty
+func opRet(o *org.Organism, c *Cpu) error {
+	if n := len(c.Calls); n > 0 {
+		c.Ip = c.Calls[n-1]
+		c.Calls = c.Calls[:n-1]
+	}
+	return nil
+}
+
+// opPush0: push 0
+func opPush0(o *org.Organism, c *Cpu) error {
+	c.push(0)
+	return nil
+}
+
+// opPush1: push 1
+func opPush1(o *org.Organism, c *Cpu) error {
+	c.push(1)
+	return nil
+}
+
+// opDup: a -> a a
+func opDup(o *org.Organism, c *Cpu) error {
+	c.push(c.top())
+	return nil
+}
+
+// opDrop: a ->
+func opDrop(o *org.Organism, c *Cpu) error {
+	c.pop()
+	return nil
+}
+
+// opSwap: a b -> b a
+func opSwap(o *org.Organism, c *Cpu) error {
+	b, a := c.pop(), c.pop()
+	c.push(b)
+	c.push(a)
+	return nil
+}
+
+// opOver: a b -> a b a
+func opOver(o *org.Organism, c *Cpu) error {
+	b, a := c.pop(), c.pop()
+	c.push(a)
+	c.push(b)
+	c.push(a)
+	return nil
+}
+
+// opShl0: a -> a<<1
+func opShl0(o *org.Organism, c *Cpu) error {
+	c.push(c.pop() << 1)
+	return nil
+}
+
+// opShl1: a -> a<<1 | 1
+func opShl1(o *org.Organism, c *Cpu) error {
+	c.push(c.pop()<<1 | 1)
+	return nil
+}
+
+// opShr: a -> a>>1
+func opShr(o *org.Organism, c *Cpu) error {
+	c.push(c.pop() >> 1)
+	return nil
+}
+
+// opInc: a -> a+1
+func opInc(o *org.Organism, c *Cpu) error {
+	c.push(c.pop() + 1)
+	return nil
+}
+
+// opDec: a -> a-1
+func opDec(o *org.Organism, c *Cpu) error {
+	c.push(c.pop() - 1)
+	return nil
+}
+
+// opAdd: a b -> a+b
+func opAdd(o *org.Organism, c *Cpu) error {
+	b, a := c.pop(), c.pop()
+	c.push(a + b)
+	return nil
+}
+
+// opSub: a b -> a-b
+func opSub(o *org.Organism, c *Cpu) error {
+	b, a := c.pop(), c.pop()
+	c.push(a - b)
+	return nil
+}
+
+// opMul: a b -> a*b
+func opMul(o *org.Organism, c *Cpu) error {
+	b, a := c.pop(), c.pop()
+	c.push(a * b)
+	return nil
+}
+
+// opDiv: a b -> a/b (may return ErrDivisionByZero)
+func opDiv(o *org.Organism, c *Cpu) error {
+	b, a := c.pop(), c.pop()
+	if b == 0 {
+		return ErrDivisionByZero
+	}
+	c.push(a / b)
+	return nil
+}
+
+// opMod: a b -> a%b (may return ErrDivisionByZero)
+func opMod(o *org.Organism, c *Cpu) error {
+	b, a := c.pop(), c.pop()
+	if b == 0 {
+		return ErrDivisionByZero
+	}
+	c.push(a % b)
+	return nil
+}
+
+// opAnd: a b -> a&b
+func opAnd(o *org.Organism, c *Cpu) error {
+	b, a := c.pop(), c.pop()
+	c.push(a & b)
+	return nil
+}
+
+// opOr: a b -> a|b
+func opOr(o *org.Organism, c *Cpu) error {
+	b, a := c.pop(), c.pop()
+	c.push(a | b)
+	return nil
+}
+
+// opXor: a b -> a^b
+func opXor(o *org.Organism, c *Cpu) error {
+	b, a := c.pop(), c.pop()
+	c.push(a ^ b)
+	return nil
+}
+
+// opLoad: addr -> Mem[addr]
+func opLoad(o *org.Organism, c *Cpu) error {
+	c.push(c.Mem[c.pop()%MemSize])
+	return nil
+}
+
+// opStore: v addr -> (Mem[addr] = v)
+func opStore(o *org.Organism, c *Cpu) error {
+	addr, v := c.pop(), c.pop()
+	c.Mem[addr%MemSize] = v
+	return nil
+}
+
+// skipUnless skips the next instruction unless cond is true.
+func skipUnless(c *Cpu, cond bool) {
+	if !cond {
+		c.Ip += 1
+	}
+}
+
+// opIfZ: a -> (if a == 0 { execute next instruction } else skip)
+func opIfZ(o *org.Organism, c *Cpu) error {
+	skipUnless(c, c.pop() == 0)
+	return nil
+}
+
+// opIfNZ: a -> (if a != 0 { execute next instruction } else skip)
+func opIfNZ(o *org.Organism, c *Cpu) error {
+	skipUnless(c, c.pop() != 0)
+	return nil
+}
+
+// opIfEq: a b -> (if a == b { execute next instruction } else skip)
+func opIfEq(o *org.Organism, c *Cpu) error {
+	b, a := c.pop(), c.pop()
+	skipUnless(c, a == b)
+	return nil
+}
+
+// opIfLt: a b -> (if a < b { execute next instruction } else skip)
+func opIfLt(o *org.Organism, c *Cpu) error {
+	b, a := c.pop(), c.pop()
+	skipUnless(c, a < b)
+	return nil
+}
+
+// opIfGt: a b -> (if a > b { execute next instruction } else skip)
+func opIfGt(o *org.Organism, c *Cpu) error {
+	b, a := c.pop(), c.pop()
+	skipUnless(c, a > b)
+	return nil
+}
+
+// opEat: a -> (consume a*10 energy from neighbor)
+func opEat(o *org.Organism, c *Cpu) error {
+	_, err := o.Eat(c.pop() * 10)
+	return err
+}
+
+// opLeft: turn left
+func opLeft(o *org.Organism, c *Cpu) error {
+	o.Left()
+	return nil
+}
+
+// opRight: turn right
+func opRight(o *org.Organism, c *Cpu) error {
+	o.Right()
+	return nil
+}
+
+// opForward: -> moved (move forward if able, pushing 1 if moved)
+func opForward(o *org.Organism, c *Cpu) error {
+	err := o.Forward()
+	if err != nil && err != org.ErrNotEmpty {
+		return err
+	}
+	c.push(boolInt(err == nil))
+	return nil
+}
+
+// opDivide: a -> divided (spawn a new organism in neighboring cell with same
+// bytecode and energy fraction described by a/256, pushing 1 if it was spawned)
+func opDivide(o *org.Organism, c *Cpu) error {
+	frac := c.pop()
+	if err := o.Discharge(len(c.Code)); err != nil {
+		return err
+	}
+	nc := c.Copy()
+	var m *mutation.Mutation
+	if rng.Float64() < MutationRate {
+		m = nc.Mutate()
+	}
+	n, err := o.Divide(nc, float64(frac)/256.0)
+	if err == org.ErrNotEmpty {
+		c.push(0)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	c.push(1)
+	c.event.Resolve(true)
+	if m != nil && nc.Hash() != nc.Parent {
+		nc.event = mutation.Born(nc.Parent, nc.Hash(), m)
+	}
+	c.start(n, nc)
+	return nil
+}
+
+// kind returns the kind of occupant v is, from the point of view of c.
+func (c *Cpu) kind(v interface{}) int {
+	switch v := v.(type) {
+	case nil:
+		return KindEmpty
+	case *food.Food:
+		return KindFood
+	case *org.Organism:
+		if nc, ok := v.Driver.(*Cpu); ok && nc.Hash() == c.Hash() {
+			return KindKin
+		}
+		return KindOrganism
+	default:
+		return KindOther
+	}
+}
+
+// opLook: -> kind (the kind of occupant in the neighboring cell)
+func opLook(o *org.Organism, c *Cpu) error {
+	c.push(c.kind(o.Look(1)))
+	return nil
+}
+
+// opScan: -> kind dist (the kind of the nearest occupant up to
+// physics.Physics.SenseDistance cells ahead, and its distance, or KindEmpty
+// and 0)
+func opScan(o *org.Organism, c *Cpu) error {
+	for i := 1; i <= physics.Current().SenseDistance; i++ {
+		if v := o.Look(i); v != nil {
+			c.push(c.kind(v))
+			c.push(i)
+			return nil
+		}
+	}
+	c.push(KindEmpty)
+	c.push(0)
+	return nil
+}
+
+// opEnergy: -> e (the organism's own energy in hundreds, capped at 255)
+func opEnergy(o *org.Organism, c *Cpu) error {
+	c.push(clip(o.Energy()/100, 0, 255))
+	return nil
+}
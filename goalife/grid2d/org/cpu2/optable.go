@@ -0,0 +1,68 @@
+package cpu2
+
+import "fmt"
+
+import "github.com/dnesting/alife/goalife/grid2d/org"
+
+// Op represents a single named instruction.
+type Op struct {
+	Name string                              // the symbolic name of the instruction
+	Fn   func(o *org.Organism, c *Cpu) error // what gets executed for this instruction
+	Cost int                                 // the instruction's energy cost (above physics.Physics.StepCost)
+}
+
+func (o Op) String() string {
+	return o.Name
+}
+
+type OpTable []Op
+
+func (ops OpTable) Len() int {
+	return len(ops)
+}
+
+// Names returns the names of the instructions in ops, in order.
+func (ops OpTable) Names() []string {
+	names := make([]string, len(ops))
+	for i, op := range ops {
+		names[i] = op.Name
+	}
+	return names
+}
+
+type UnknownOpErr struct {
+	V interface{}
+}
+
+func (e UnknownOpErr) Error() string {
+	return fmt.Sprintf("unknown operation: %v", e.V)
+}
+
+// Compile converts a slice of symbolic instructions into bytecode.
+func (ops OpTable) Compile(prog []string) (Bytecode, error) {
+	m := make(map[string]byte)
+	for i, op := range ops {
+		m[op.Name] = byte(i)
+	}
+	code := make(Bytecode, 0, len(prog))
+	for _, s := range prog {
+		b, ok := m[s]
+		if !ok {
+			return nil, UnknownOpErr{s}
+		}
+		code = append(code, b)
+	}
+	return code, nil
+}
+
+// Decompile converts a slice of bytecode into symbolic instructions.
+func (ops OpTable) Decompile(code []byte) ([]string, error) {
+	var s []string
+	for _, b := range code {
+		if int(b) >= ops.Len() {
+			return nil, UnknownOpErr{b}
+		}
+		s = append(s, ops[b].Name)
+	}
+	return s, nil
+}
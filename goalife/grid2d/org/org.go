@@ -6,37 +6,31 @@ package org
 import "errors"
 import "fmt"
 import "math"
-import "math/rand"
 import "sync"
+import "sync/atomic"
 import "runtime"
+import "time"
 
 import "github.com/dnesting/alife/goalife/energy"
 import "github.com/dnesting/alife/goalife/grid2d"
 import "github.com/dnesting/alife/goalife/grid2d/food"
 import "github.com/dnesting/alife/goalife/log"
-
-// An organism's "body" is considered to have this much energy.  It costs at least this
-// much energy for one organism to create another, and when an organism dies, it is replaced
-// with Food storing this much energy.
-const BodyEnergy = 1000
-
-// SenseFalloffExp describes the exponential falloff as Sense range increases.
-const SenseFalloffExp = 2
-
-// SenseDistance is the maximum distance we look out to satisfy Sense calls.
-const SenseDistance = 10
+import "github.com/dnesting/alife/goalife/physics"
+import "github.com/dnesting/alife/goalife/util/rng"
 
 var Logger = log.Null()
 
 // Organism represents an occupant of a Grid that has a more organically-inspired lifecycle,
 // energy store and direction.  By itself, it doesn't do anything.  It requires additional
 // functionality to "drive" it by invoking its methods to inspect and navigate its environment.
-// An Organism's direction can be any of 8 values representing the four cardinal compass directions
-// and one degree in between each (i.e, north, north-west, west, etc.).
+// An Organism's direction is one of the directions of the grid2d.Geometry of the Grid it
+// inhabits.  On a grid2d.Square grid, this can be any of 8 values representing the four
+// cardinal compass directions and one degree in between each (i.e, north, north-west, west,
+// etc.).  On a grid2d.Hex grid, there are 6.
 //
-// Most methods have an energy cost associated with them, and can return ErrNoEnergy if the
-// organism's energy is exhausted.  Callers are expected to terminate execution and invoke the
-// organism's Die method in this case.
+// Most methods have an energy cost associated with them, described by physics.Current, and
+// can return ErrNoEnergy if the organism's energy is exhausted.  Callers are expected to
+// terminate execution and invoke the organism's Die method in this case.
 type Organism struct {
 	energy.Store
 	loc    grid2d.Locator
@@ -44,6 +38,13 @@ type Organism struct {
 
 	mu  sync.Mutex
 	Dir int
+
+	// Steps is the number of steps the organism has executed (see Tick),
+	// and Born is the time it was born, or first placed in a Grid.
+	Steps int64
+	Born  time.Time
+
+	cause error // see Cause
 }
 
 func (o *Organism) String() string {
@@ -55,17 +56,30 @@ func (o *Organism) String() string {
 // placed in a Grid and should not normally be called.
 func (o *Organism) UseLocator(loc grid2d.Locator) {
 	o.loc = loc
+	if o.Born.IsZero() {
+		o.Born = time.Now()
+	}
+}
+
+// geometry returns the grid2d.Geometry of the Grid the organism inhabits, or
+// grid2d.Square if it has not yet been placed in one.
+func (o *Organism) geometry() grid2d.Geometry {
+	if o.loc != nil {
+		return o.loc.Geometry()
+	}
+	return grid2d.Square
 }
 
 // Left causes the organism to rotate its direction counter-clockwise once (i.e.,
 // from north to north-west).
 func (o *Organism) Left() {
 	Logger.Printf("%v.Left()\n", o)
+	n := o.geometry().Directions()
 	o.mu.Lock()
 
 	o.Dir -= 1
-	if o.Dir < 0 {
-		o.Dir = 7
+	if o.Dir < 0 || o.Dir >= n {
+		o.Dir = n - 1
 	}
 
 	o.mu.Unlock()
@@ -76,8 +90,9 @@ func (o *Organism) Left() {
 // from north to north-east).
 func (o *Organism) Right() {
 	Logger.Printf("%v.Right()\n", o)
+	n := o.geometry().Directions()
 	o.mu.Lock()
-	o.Dir = (o.Dir + 1) % 8
+	o.Dir = (o.Dir + 1) % n
 	o.mu.Unlock()
 	runtime.Gosched()
 }
@@ -86,6 +101,26 @@ func (o *Organism) Right() {
 // to perform the requested action.
 var ErrNoEnergy = errors.New("out of energy")
 
+// Actions counts actions taken by organisms that aren't otherwise visible as
+// changes to the Grid, for statistics.
+type Actions struct {
+	Eats    int64 // successful attempts to eat
+	Eaten   int64 // energy eaten
+	Divides int64 // successful divisions
+}
+
+var actions Actions
+
+// CountActions returns the actions taken by all organisms since the program
+// began.
+func CountActions() Actions {
+	return Actions{
+		Eats:    atomic.LoadInt64(&actions.Eats),
+		Eaten:   atomic.LoadInt64(&actions.Eaten),
+		Divides: atomic.LoadInt64(&actions.Divides),
+	}
+}
+
 // Discharge attempts to reduce the energy store of the organism by amt.  Returns
 // ErrNoEnergy if this resulted in reducing the energy store to zero.
 func (o *Organism) Discharge(amt int) error {
@@ -96,62 +131,75 @@ func (o *Organism) Discharge(amt int) error {
 	return nil
 }
 
+// ErrOldAge is returned from Tick when the organism has reached the maximum
+// age (physics.Physics.MaxAge).
+var ErrOldAge = errors.New("old age")
+
+// Tick records that the organism is executing a step, and charges it the cost
+// of its age (physics.Physics.AgingCost).  Returns ErrOldAge if it has
+// executed physics.Physics.MaxAge steps already, or ErrNoEnergy if its energy
+// is exhausted.
+func (o *Organism) Tick() error {
+	steps := atomic.AddInt64(&o.Steps, 1) - 1
+	p := physics.Current()
+	if p.MaxAge > 0 && steps >= p.MaxAge {
+		return ErrOldAge
+	}
+	if cost := int(float64(steps) * p.AgingCost); cost > 0 {
+		return o.Discharge(cost)
+	}
+	return nil
+}
+
+// Age returns the number of steps the organism has executed and the time
+// since it was born.
+func (o *Organism) Age() (steps int64, since time.Duration) {
+	return atomic.LoadInt64(&o.Steps), time.Since(o.Born)
+}
+
 // Die causes the organism to terminate its existence.  It will be replaced with
 // an item of Food storing the same amount of energy as the organism plus the
-// base BodyEnergy.
+// energy of its body (physics.Physics.BodyEnergy).
 func (o *Organism) Die() {
-	Logger.Printf("%v.Die()\n", o)
-	o.loc.Replace(food.New(o.Energy() + BodyEnergy))
+	o.DieOf(nil)
+}
+
+// DieOf is like Die, but records cause as the cause of death, such as
+// ErrNoEnergy or ErrOldAge (see Cause).
+func (o *Organism) DieOf(cause error) {
+	Logger.Printf("%v.DieOf(%v)\n", o, cause)
+	o.cause = cause
+	o.loc.Replace(food.New(o.Energy() + physics.Current().BodyEnergy))
 	runtime.Gosched()
 }
 
+// Cause returns the cause of the organism's death given to DieOf, or nil if
+// it hasn't died or the cause isn't known.  It is set before the organism is
+// removed from its Grid, so it can be consulted by subscribers to the Grid's
+// updates.
+func (o *Organism) Cause() error {
+	return o.cause
+}
+
+// Position returns the organism's location in the Grid it inhabits, or ok
+// false if it has not been placed in one.
+func (o *Organism) Position() (x, y int, ok bool) {
+	if o.loc == nil {
+		return 0, 0, false
+	}
+	x, y = o.loc.Position()
+	return x, y, true
+}
+
 // Arrow returns an arrow rune representing the direction the organism is pointing.
 func (o *Organism) Arrow() rune {
-	switch o.Dir {
-	case 0:
-		return '→'
-	case 1:
-		return '↗'
-	case 2:
-		return '↑'
-	case 3:
-		return '↖'
-	case 4:
-		return '←'
-	case 5:
-		return '↙'
-	case 6:
-		return '↓'
-	case 7:
-		return '↘'
-	default:
-		panic(fmt.Sprintf("out of range direction %d", o.Dir))
-	}
+	return o.geometry().Arrow(o.Dir)
 }
 
 // delta returns the relative coordinates of the cell dist cells
 // away in the organisms direction.
 func (o *Organism) delta(dist int) (int, int) {
-	switch o.Dir {
-	case 0:
-		return dist * 1, 0
-	case 1:
-		return dist * 1, dist * -1
-	case 2:
-		return 0, dist * -1
-	case 3:
-		return dist * -1, dist * -1
-	case 4:
-		return dist * -1, 0
-	case 5:
-		return dist * -1, dist * 1
-	case 6:
-		return 0, dist * 1
-	case 7:
-		return dist * 1, dist * 1
-	default:
-		panic(fmt.Sprintf("out of range direction %d", o.Dir))
-	}
+	return o.geometry().Delta(o.Dir, dist)
 }
 
 // ErrNotEmpty is returned when an operation requires occupying a cell
@@ -164,7 +212,7 @@ var ErrNotEmpty = errors.New("cell occupied")
 // by something else.
 func (o *Organism) Forward() error {
 	Logger.Printf("%v.Forward()\n", o)
-	if err := o.Discharge(1); err != nil {
+	if err := o.Discharge(physics.Current().MoveCost); err != nil {
 		Logger.Printf("%v.Forward: %v\n", o, err)
 		return err
 	}
@@ -176,10 +224,17 @@ func (o *Organism) Forward() error {
 	return ErrNotEmpty
 }
 
-// Random generates an organism pointing in a random direction.  The
-// resulting organism has no driver and is not associated with a locator.
+// Random generates an organism pointing in a random direction on a
+// grid2d.Square grid.  The resulting organism has no driver and is not
+// associated with a locator.
 func Random() *Organism {
-	return &Organism{Dir: rand.Intn(8)}
+	return RandomIn(grid2d.Square)
+}
+
+// RandomIn generates an organism pointing in a random direction of geom.
+// The resulting organism has no driver and is not associated with a locator.
+func RandomIn(geom grid2d.Geometry) *Organism {
+	return &Organism{Dir: rng.Intn(geom.Directions())}
 }
 
 // PutWhenFood is a grid2d.PutWhenFunc that returns true if the cell is
@@ -198,20 +253,22 @@ var PutWhenFood = func(orig, n interface{}) bool {
 // organism is pointing.  Energy from the parent, multiplied by energyFrac, will
 // be transferred to the child to give it something to start off with.  The
 // returns organism will be associated with a grid2d.Locator and given driver,
-// but still requires the caller spawn a goroutine to drive it.  Returns nil and
+// but still requires the caller to begin driving it.  Returns nil and
 // an error if there was insufficient energy to divide, or if the cell the child
 // would be spawned within is already occupied by anything other than Food.
 func (o *Organism) Divide(driver interface{}, energyFrac float64) (*Organism, error) {
 	Logger.Printf("%v.Divide(%v, %v)\n", o, driver, energyFrac)
-	if err := o.Discharge(BodyEnergy); err != nil {
+	if err := o.Discharge(physics.Current().BodyEnergy); err != nil {
 		return nil, err
 	}
 
-	n := Random()
+	n := RandomIn(o.geometry())
 	n.Driver = driver
+	n.Born = time.Now()
 	dx, dy := o.delta(1)
 	if _, loc := o.loc.Put(dx, dy, n, PutWhenFood); loc != nil {
 		energy.Transfer(n, o, int(float64(o.Energy())*energyFrac))
+		atomic.AddInt64(&actions.Divides, 1)
 		Logger.Printf("- parent: %v\n", o)
 		Logger.Printf("-  child: %v\n", n)
 		runtime.Gosched()
@@ -228,15 +285,16 @@ func (o *Organism) Divide(driver interface{}, energyFrac float64) (*Organism, er
 // occupants.  Exponential falloff will be applied on top of this, so that nearer occupants
 // will contribute more to the returned energy level than more distant occupants.
 func (o *Organism) Sense(fn func(o interface{}) float64) float64 {
-	Logger.Printf("%v.Sense(%v)\n", o, fn)
+	Logger.Printf("%v.Sense(%p)\n", o, fn)
 	var e float64
 	if fn == nil {
 		fn = func(_ interface{}) float64 { return 1.0 }
 	}
-	for i := 1; i <= SenseDistance; i++ {
+	p := physics.Current()
+	for i := 1; i <= p.SenseDistance; i++ {
 		if n := o.loc.Get(o.delta(i)); n != nil {
 			if n, ok := n.(energy.Energetic); ok {
-				e += float64(n.Energy()) * fn(n) / math.Pow(float64(i), SenseFalloffExp)
+				e += float64(n.Energy()) * fn(n) / math.Pow(float64(i), p.SenseFalloffExp)
 			}
 		}
 	}
@@ -244,12 +302,39 @@ func (o *Organism) Sense(fn func(o interface{}) float64) float64 {
 	return e
 }
 
+// Look returns the occupant of the cell dist cells away in the direction the
+// organism points, or nil if the cell is empty.
+func (o *Organism) Look(dist int) interface{} {
+	Logger.Printf("%v.Look(%v)\n", o, dist)
+	if n := o.loc.Get(o.delta(dist)); n != nil {
+		return n.Value()
+	}
+	return nil
+}
+
+// Neighbors returns the occupants of the cells adjacent to the organism,
+// indexed by direction relative to the one it points, so that the occupant
+// in front of it is first.  Empty cells are nil.
+func (o *Organism) Neighbors() []interface{} {
+	Logger.Printf("%v.Neighbors()\n", o)
+	geom := o.geometry()
+	n := geom.Directions()
+	vs := make([]interface{}, n)
+	for i := range vs {
+		if v := o.loc.Get(geom.Delta((o.Dir+i)%n, 1)); v != nil {
+			vs[i] = v.Value()
+		}
+	}
+	runtime.Gosched()
+	return vs
+}
+
 // Eat attempts to transfer energy from the occupant in the neighboring cell in the
 // direction the organism points.  Returns the amount transferred successfully or
 // an error if there was insufficient energy to complete the action.
 func (o *Organism) Eat(amt int) (int, error) {
 	Logger.Printf("%v.Eat(%v)\n", o, amt)
-	if err := o.Discharge(int(math.Ceil(float64(amt) / 100.0))); err != nil {
+	if err := o.Discharge(int(math.Ceil(float64(amt) / float64(physics.Current().EatRatio)))); err != nil {
 		return 0, err
 	}
 	if n := o.loc.Get(o.delta(1)); n != nil {
@@ -257,6 +342,10 @@ func (o *Organism) Eat(amt int) (int, error) {
 		if n, ok := n.Value().(energy.Energetic); ok {
 			amt, _, _ = energy.Transfer(o, n, amt)
 			Logger.Printf("- transferred %v\n", amt)
+			if amt > 0 {
+				atomic.AddInt64(&actions.Eats, 1)
+				atomic.AddInt64(&actions.Eaten, int64(amt))
+			}
 			Logger.Printf("  - %v\n", o)
 			Logger.Printf("  - %v\n", n)
 			runtime.Gosched()
@@ -0,0 +1,49 @@
+package org
+
+import "testing"
+
+import "github.com/dnesting/alife/goalife/grid2d"
+import "github.com/dnesting/alife/goalife/physics"
+
+func TestTick(t *testing.T) {
+	defer physics.Set(physics.Default())
+	p := physics.Default()
+	p.MaxAge = 3
+	p.AgingCost = 0.5
+	if err := physics.Set(p); err != nil {
+		t.Fatalf("error setting physics: %v", err)
+	}
+
+	g := grid2d.New(5, 5, nil)
+	o := Random()
+	o.AddEnergy(100)
+	g.Put(1, 1, o, grid2d.PutAlways)
+	if o.Born.IsZero() {
+		t.Errorf("expected an organism placed in a grid to be born")
+	}
+
+	// Aging costs 0, 0 and then 1.
+	for i := 0; i < 3; i++ {
+		if err := o.Tick(); err != nil {
+			t.Fatalf("step %d: unexpected error %v", i, err)
+		}
+	}
+	if steps, _ := o.Age(); steps != 3 || o.Energy() != 99 {
+		t.Errorf("expected age 3 and energy 99 got %d and %d", steps, o.Energy())
+	}
+	err := o.Tick()
+	if err != ErrOldAge {
+		t.Fatalf("expected %v got %v", ErrOldAge, err)
+	}
+
+	if o.Cause() != nil {
+		t.Errorf("expected no cause of death while alive got %v", o.Cause())
+	}
+	o.DieOf(err)
+	if o.Cause() != ErrOldAge {
+		t.Errorf("expected death of %v got %v", ErrOldAge, o.Cause())
+	}
+	if _, ok := g.Get(1, 1).Value().(*Organism); ok {
+		t.Errorf("expected the organism to be replaced")
+	}
+}
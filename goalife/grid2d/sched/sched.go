@@ -0,0 +1,150 @@
+// Package sched executes organisms one step at a time in a controlled
+// order, as an alternative to running each organism in its own goroutine.
+//
+// When every organism is stepped by a single Scheduler and the world's
+// randomness is seeded (see util/rng), the same seed and the same starting
+// Grid will always produce the same world.
+package sched
+
+import "math/rand"
+import "sync"
+
+import "github.com/dnesting/alife/goalife/grid2d/org"
+import "github.com/dnesting/alife/goalife/log"
+
+var Logger = log.Null()
+
+// Stepper is implemented by organism drivers capable of executing a single
+// step at a time, such as cpu1.Cpu.
+type Stepper interface {
+	Step(o *org.Organism) error
+}
+
+// Order describes the order in which organisms are stepped within a round.
+type Order int
+
+const (
+	// RoundRobin steps organisms in the order in which they were added.
+	RoundRobin Order = iota
+
+	// Shuffled steps organisms in a random order, chosen anew each round
+	// using the Scheduler's seeded source.
+	Shuffled
+)
+
+func (o Order) String() string {
+	switch o {
+	case RoundRobin:
+		return "round-robin"
+	case Shuffled:
+		return "shuffled"
+	default:
+		return "unknown"
+	}
+}
+
+type entry struct {
+	o *org.Organism
+	s Stepper
+}
+
+// Scheduler steps each of its organisms once per round.  When a step returns
+// an error, the organism's Die method is invoked and it is no longer scheduled.
+// Organisms added during a round are first stepped in the following round.
+type Scheduler struct {
+	mu      sync.Mutex
+	order   Order
+	rnd     *rand.Rand
+	entries []entry
+	pending []entry
+	rounds  int64
+}
+
+// New creates a Scheduler that steps organisms in the given order.  seed
+// initializes the source used when order is Shuffled.
+func New(order Order, seed int64) *Scheduler {
+	return &Scheduler{
+		order: order,
+		rnd:   rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Add schedules o to be stepped by st, beginning with the next round.
+func (s *Scheduler) Add(o *org.Organism, st Stepper) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending = append(s.pending, entry{o, st})
+}
+
+// Len returns the number of organisms currently scheduled, including those
+// that will begin in the next round.
+func (s *Scheduler) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries) + len(s.pending)
+}
+
+// Rounds returns the number of rounds completed.
+func (s *Scheduler) Rounds() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rounds
+}
+
+// Round steps every scheduled organism once, and returns the number of
+// organisms stepped.  Round must not be called concurrently with itself.
+func (s *Scheduler) Round() int {
+	s.mu.Lock()
+	s.entries = append(s.entries, s.pending...)
+	s.pending = nil
+	entries := s.entries
+	var perm []int
+	if s.order == Shuffled {
+		perm = s.rnd.Perm(len(entries))
+	}
+	s.mu.Unlock()
+
+	dead := make([]bool, len(entries))
+	for i := range entries {
+		if perm != nil {
+			i = perm[i]
+		}
+		e := entries[i]
+		if err := e.s.Step(e.o); err != nil {
+			Logger.Printf("%v: %v\n", e.o, err)
+			e.o.DieOf(err)
+			dead[i] = true
+		}
+	}
+
+	live := make([]entry, 0, len(entries))
+	for i, e := range entries {
+		if !dead[i] {
+			live = append(live, e)
+		}
+	}
+
+	s.mu.Lock()
+	s.entries = live
+	s.rounds++
+	s.mu.Unlock()
+	return len(entries)
+}
+
+// Run invokes Round repeatedly until exit yields a value or is closed.  If
+// between is non-nil, it is invoked before each round, permitting the caller
+// to make changes to the world (such as adding organisms) in a reproducible
+// way.
+func (s *Scheduler) Run(exit <-chan bool, between func()) {
+	for {
+		select {
+		case <-exit:
+			return
+		default:
+		}
+		if between != nil {
+			between()
+		}
+		s.Round()
+	}
+}
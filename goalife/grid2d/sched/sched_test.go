@@ -0,0 +1,177 @@
+package sched
+
+import "errors"
+import "fmt"
+import "reflect"
+import "testing"
+
+import "github.com/dnesting/alife/goalife/grid2d"
+import "github.com/dnesting/alife/goalife/grid2d/food"
+import "github.com/dnesting/alife/goalife/grid2d/org"
+import "github.com/dnesting/alife/goalife/grid2d/org/cpu1"
+import "github.com/dnesting/alife/goalife/util/rng"
+
+type fakeStepper struct {
+	name   string
+	steps  *[]string
+	fail   bool
+	onStep func()
+}
+
+func (f *fakeStepper) Step(o *org.Organism) error {
+	*f.steps = append(*f.steps, f.name)
+	if f.onStep != nil {
+		f.onStep()
+		f.onStep = nil
+	}
+	if f.fail {
+		return errors.New("fail")
+	}
+	return nil
+}
+
+func addFake(t *testing.T, s *Scheduler, g grid2d.Grid, x int, name string, steps *[]string, fail bool) *org.Organism {
+	o := org.Random()
+	o.AddEnergy(10)
+	if _, loc := g.Put(x, 0, o, grid2d.PutWhenNil); loc == nil {
+		t.Fatalf("unable to place organism at (%d,0)", x)
+	}
+	s.Add(o, &fakeStepper{name: name, steps: steps, fail: fail})
+	return o
+}
+
+func TestRoundRobin(t *testing.T) {
+	g := grid2d.New(3, 1, nil)
+	s := New(RoundRobin, 1)
+	var steps []string
+	addFake(t, s, g, 0, "a", &steps, false)
+	addFake(t, s, g, 1, "b", &steps, true)
+	addFake(t, s, g, 2, "c", &steps, false)
+
+	if s.Len() != 3 {
+		t.Errorf("Len() should be 3, got %d", s.Len())
+	}
+	if n := s.Round(); n != 3 {
+		t.Errorf("Round() should have stepped 3 organisms, got %d", n)
+	}
+	expected := []string{"a", "b", "c"}
+	if !reflect.DeepEqual(steps, expected) {
+		t.Errorf("wrong step order, expected %v got %v", expected, steps)
+	}
+
+	// b failed, so it should have died and been replaced with food.
+	if s.Len() != 2 {
+		t.Errorf("Len() should be 2 after a failed step, got %d", s.Len())
+	}
+	if _, ok := g.Get(1, 0).Value().(*food.Food); !ok {
+		t.Errorf("failed organism should have been replaced with food, got %v", g.Get(1, 0).Value())
+	}
+
+	steps = nil
+	s.Round()
+	expected = []string{"a", "c"}
+	if !reflect.DeepEqual(steps, expected) {
+		t.Errorf("wrong step order, expected %v got %v", expected, steps)
+	}
+	if s.Rounds() != 2 {
+		t.Errorf("Rounds() should be 2, got %d", s.Rounds())
+	}
+}
+
+func TestAddDuringRound(t *testing.T) {
+	g := grid2d.New(2, 1, nil)
+	s := New(RoundRobin, 1)
+	var steps []string
+	o := org.Random()
+	o.AddEnergy(10)
+	g.Put(0, 0, o, grid2d.PutWhenNil)
+
+	// a adds b during its step, as a dividing organism would.
+	s.Add(o, &fakeStepper{name: "a", steps: &steps, onStep: func() {
+		s.Add(o, &fakeStepper{name: "b", steps: &steps})
+	}})
+	s.Round()
+	expected := []string{"a"}
+	if !reflect.DeepEqual(steps, expected) {
+		t.Errorf("organism added during a round should not run in that round, expected %v got %v", expected, steps)
+	}
+
+	steps = nil
+	s.Round()
+	expected = []string{"a", "b"}
+	if !reflect.DeepEqual(steps, expected) {
+		t.Errorf("organism added during a round should run after existing ones, expected %v got %v", expected, steps)
+	}
+}
+
+func TestShuffled(t *testing.T) {
+	order := func(seed int64) []string {
+		g := grid2d.New(10, 1, nil)
+		s := New(Shuffled, seed)
+		var steps []string
+		for i := 0; i < 10; i++ {
+			addFake(t, s, g, i, fmt.Sprint(i), &steps, false)
+		}
+		s.Round()
+		return steps
+	}
+	a, b := order(42), order(42)
+	if !reflect.DeepEqual(a, b) {
+		t.Errorf("shuffled order should be reproducible with the same seed, got %v and %v", a, b)
+	}
+}
+
+// snapshot describes the contents of g in a form that can be compared.
+func snapshot(g grid2d.Grid) []string {
+	var points []grid2d.Point
+	g.Locations(&points)
+	var s []string
+	for _, p := range points {
+		switch v := p.V.(type) {
+		case *org.Organism:
+			c := v.Driver.(*cpu1.Cpu)
+			s = append(s, fmt.Sprintf("%d,%d org e=%d d=%d %x ip=%d %v", p.X, p.Y, v.Energy(), v.Dir, c.Hash(), c.Ip, c.R))
+		case *food.Food:
+			s = append(s, fmt.Sprintf("%d,%d food e=%d", p.X, p.Y, v.Energy()))
+		default:
+			s = append(s, fmt.Sprintf("%d,%d %v", p.X, p.Y, v))
+		}
+	}
+	return s
+}
+
+func runWorld(seed int64, order Order, rounds int) []string {
+	defer func(orig float64) { cpu1.MutationRate = orig }(cpu1.MutationRate)
+	cpu1.MutationRate = 0.5
+
+	rng.Seed(seed)
+	g := grid2d.New(20, 20, nil)
+	s := New(order, seed)
+
+	for i := 0; i < 30; i++ {
+		o := org.Random()
+		o.Driver = cpu1.Random()
+		o.AddEnergy(10000)
+		g.PutRandomly(o, org.PutWhenFood)
+	}
+	cpu1.StartAll(g, func(o *org.Organism, c *cpu1.Cpu) { s.Add(o, c) })
+
+	for i := 0; i < rounds; i++ {
+		s.Round()
+	}
+	return snapshot(g)
+}
+
+func TestReproducible(t *testing.T) {
+	for _, order := range []Order{RoundRobin, Shuffled} {
+		a := runWorld(7, order, 300)
+		b := runWorld(7, order, 300)
+		if !reflect.DeepEqual(a, b) {
+			t.Errorf("%v: two runs with the same seed should produce the same world:\n%v\n%v", order, a, b)
+		}
+		c := runWorld(8, order, 300)
+		if reflect.DeepEqual(a, c) {
+			t.Errorf("%v: two runs with different seeds should not produce the same world", order)
+		}
+	}
+}
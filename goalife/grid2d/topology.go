@@ -0,0 +1,140 @@
+package grid2d
+
+import "fmt"
+
+// Topology describes what lies beyond the edges of a Grid, which determines
+// how coordinates relative to a Locator are resolved.
+type Topology interface {
+	// Resolve maps x,y, which may lie outside of a width x height Grid, to
+	// a cell within it.  Returns false if there is no such cell.
+	Resolve(x, y, width, height int) (int, int, bool)
+}
+
+// geometryResolver is implemented by Topologies whose behavior depends on
+// the Geometry of the Grid.  The Grid uses resolveIn in place of Resolve.
+type geometryResolver interface {
+	resolveIn(geom Geometry, x, y, width, height int) (int, int, bool)
+}
+
+type torus struct{}
+
+func (torus) String() string { return "torus" }
+
+func (torus) Resolve(x, y, width, height int) (int, int, bool) {
+	x %= width
+	y %= height
+	if x < 0 {
+		x += width
+	}
+	if y < 0 {
+		y += height
+	}
+	return x, y, true
+}
+
+type walled struct{}
+
+func (walled) String() string { return "walled" }
+
+func (walled) Resolve(x, y, width, height int) (int, int, bool) {
+	if x < 0 || x >= width || y < 0 || y >= height {
+		return x, y, false
+	}
+	return x, y, true
+}
+
+type reflective struct{}
+
+func (reflective) String() string { return "reflective" }
+
+// mirror folds v back and forth across [0,n) as if the edges were mirrors
+// centered on the outermost cells, so that -1 becomes 1 and n becomes n-2.
+func mirror(v, n int) int {
+	if n <= 1 {
+		return 0
+	}
+	period := 2 * (n - 1)
+	v %= period
+	if v < 0 {
+		v += period
+	}
+	if v >= n {
+		v = period - v
+	}
+	return v
+}
+
+func (reflective) Resolve(x, y, width, height int) (int, int, bool) {
+	return mirror(x, width), mirror(y, height), true
+}
+
+// maxHexReflections bounds the number of edges reflectHex will reflect
+// across before giving up and folding each axis with mirror.
+const maxHexReflections = 8
+
+// reflectHex mirrors x,y across the edges of a width x height Hex grid.
+// Mirroring each axis independently would turn a step across an edge into
+// a jump to a cell that isn't a neighbor, so instead we reflect across the
+// line of cells at each edge, which preserves adjacency.  In axial
+// coordinates, reflecting across the column x=0 maps x,y to -x,y+x, and
+// reflecting across the row y=0 maps x,y to x+y,-y.
+func reflectHex(x, y, width, height int) (int, int) {
+	if width <= 1 || height <= 1 {
+		return mirror(x, width), mirror(y, height)
+	}
+	for i := 0; i < maxHexReflections; i++ {
+		switch {
+		case x < 0:
+			x, y = -x, y+x
+		case x >= width:
+			u := x - (width - 1)
+			x, y = width-1-u, y+u
+		case y < 0:
+			x, y = x+y, -y
+		case y >= height:
+			v := y - (height - 1)
+			x, y = x+v, height-1-v
+		default:
+			return x, y
+		}
+	}
+	return mirror(x, width), mirror(y, height)
+}
+
+func (r reflective) resolveIn(geom Geometry, x, y, width, height int) (int, int, bool) {
+	if geom == Hex {
+		x, y = reflectHex(x, y, width, height)
+		return x, y, true
+	}
+	return r.Resolve(x, y, width, height)
+}
+
+var (
+	// Torus wraps each edge of the Grid around to the opposite edge.
+	Torus Topology = torus{}
+
+	// Walled treats the edges of the Grid as impassable.  Attempts to get,
+	// put or move beyond an edge fail as if the cell were occupied.
+	Walled Topology = walled{}
+
+	// Reflective mirrors the Grid at each edge, so that an occupant moving
+	// off an edge bounces back into the Grid.  On a Hex grid, a Grid
+	// reflects across the lines of cells at its edges instead (see
+	// reflectHex), which Resolve alone cannot do.
+	Reflective Topology = reflective{}
+)
+
+// Topologies maps the names of the standard topologies to their values.
+var Topologies = map[string]Topology{
+	"torus":      Torus,
+	"walled":     Walled,
+	"reflective": Reflective,
+}
+
+// TopologyByName returns the standard Topology with the given name.
+func TopologyByName(name string) (Topology, error) {
+	if t, ok := Topologies[name]; ok {
+		return t, nil
+	}
+	return nil, fmt.Errorf("unknown topology %q", name)
+}
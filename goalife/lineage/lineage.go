@@ -0,0 +1,315 @@
+// Package lineage records which genome each genome descended from, so that
+// the phylogeny of a run can be walked and exported.
+//
+// A genome is recorded when it is first born by division from a different
+// genome, with the hash of its parent's genome, the time and the mutation
+// that produced it.  A genome produced by recombining its parent's genome
+// with another's also records the other, its mate, though the tree follows
+// parents only.  Seeded genomes are not recorded themselves, and appear
+// only as the roots of the genomes descending from them.  Records are
+// appended to a file as JSON, one per line, as they are added.
+package lineage
+
+import "bufio"
+import "encoding/json"
+import "fmt"
+import "io"
+import "os"
+import "sort"
+import "strconv"
+import "sync"
+import "time"
+
+// Hash identifies a genome, as census.Key.Hash does.  It is written in hex.
+type Hash uint64
+
+func (h Hash) String() string {
+	return strconv.FormatUint(uint64(h), 16)
+}
+
+// MarshalText encodes h in hex.
+func (h Hash) MarshalText() ([]byte, error) {
+	return []byte(h.String()), nil
+}
+
+// UnmarshalText decodes h from hex.
+func (h *Hash) UnmarshalText(b []byte) error {
+	v, err := strconv.ParseUint(string(b), 16, 64)
+	if err != nil {
+		return fmt.Errorf("invalid hash %q", b)
+	}
+	*h = Hash(v)
+	return nil
+}
+
+// Descendant is a genome that knows where it came from, such as a cpu1.Cpu.
+type Descendant interface {
+	Hash() uint64
+
+	// Origin returns the hash of the parent genome, or 0 if there was none,
+	// and a description of the mutation that produced this one from it.
+	Origin() (parent uint64, mutation string)
+}
+
+// Recombinant is a Descendant that may have been recombined with a second
+// genome, its mate.
+type Recombinant interface {
+	// Parents returns the hash of the parent genome and of its mate, or 0
+	// if there was none.
+	Parents() (parent, mate uint64)
+}
+
+// Record describes the birth of a genome.
+type Record struct {
+	Hash     Hash
+	Parent   Hash
+	Mate     Hash `json:",omitempty"`
+	Born     time.Time
+	Mutation string `json:",omitempty"`
+}
+
+// Tree holds the records of a run.
+type Tree struct {
+	mu       sync.Mutex
+	records  map[Hash]*Record
+	children map[Hash][]Hash
+	order    []Hash // in the order added
+	f        *os.File
+}
+
+// New returns an empty Tree that isn't saved.
+func New() *Tree {
+	return &Tree{
+		records:  make(map[Hash]*Record),
+		children: make(map[Hash][]Hash),
+	}
+}
+
+// Read returns a Tree holding the records read from r.
+func Read(r io.Reader) (*Tree, error) {
+	t := New()
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		if len(s.Bytes()) == 0 {
+			continue
+		}
+		var rec Record
+		if err := json.Unmarshal(s.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		t.add(rec)
+	}
+	return t, s.Err()
+}
+
+// Load returns a Tree holding the records in filename.
+func Load(filename string) (*Tree, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	t, err := Read(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return t, nil
+}
+
+// Open returns a Tree holding the records in filename, if it exists, to
+// which records added are appended.
+func Open(filename string) (*Tree, error) {
+	t, err := Load(filename)
+	if os.IsNotExist(err) {
+		t, err = New(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if t.f, err = os.OpenFile(filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Close closes the file the Tree is saved to, if any.
+func (t *Tree) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.f == nil {
+		return nil
+	}
+	err := t.f.Close()
+	t.f = nil
+	return err
+}
+
+func (t *Tree) add(r Record) bool {
+	if _, ok := t.records[r.Hash]; ok || r.Hash == r.Parent {
+		return false
+	}
+	if _, ok := t.children[r.Hash]; ok {
+		// Seeded before it was born.
+		return false
+	}
+	t.records[r.Hash] = &r
+	t.children[r.Parent] = append(t.children[r.Parent], r.Hash)
+	t.order = append(t.order, r.Hash)
+	return true
+}
+
+// Add records r, unless its genome has already been recorded or seen as a
+// parent, or it was born with the same genome as its parent.  Returns true
+// if r was added.
+func (t *Tree) Add(r Record) (bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.add(r) {
+		return false, nil
+	}
+	if t.f != nil {
+		b, err := json.Marshal(&r)
+		if err != nil {
+			return true, err
+		}
+		if _, err := t.f.Write(append(b, '\n')); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// AddDescendant records the birth of d at time when, if it was born with a
+// new genome by division.  If d is a Recombinant, its mate is recorded too.
+func (t *Tree) AddDescendant(when time.Time, d Descendant) (bool, error) {
+	parent, mutation := d.Origin()
+	if parent == 0 {
+		return false, nil
+	}
+	r := Record{Hash: Hash(d.Hash()), Parent: Hash(parent), Born: when, Mutation: mutation}
+	if rd, ok := d.(Recombinant); ok {
+		_, mate := rd.Parents()
+		r.Mate = Hash(mate)
+	}
+	return t.Add(r)
+}
+
+// Len returns the number of genomes recorded.
+func (t *Tree) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.records)
+}
+
+// Get returns the record of the genome h.
+func (t *Tree) Get(h Hash) (Record, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r, ok := t.records[h]
+	if !ok {
+		return Record{}, false
+	}
+	return *r, true
+}
+
+// Ancestry returns the records of h and of each of its ancestors in turn,
+// ending with the one descended from a seeded genome.
+func (t *Tree) Ancestry(h Hash) []Record {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var rs []Record
+	seen := make(map[Hash]bool)
+	for r, ok := t.records[h]; ok && !seen[h]; r, ok = t.records[h] {
+		seen[h] = true
+		rs = append(rs, *r)
+		h = r.Parent
+	}
+	return rs
+}
+
+// Children returns the genomes descended directly from h, in the order they
+// were born.
+func (t *Tree) Children(h Hash) []Hash {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Hash(nil), t.children[h]...)
+}
+
+// Roots returns the seeded genomes from which the genomes recorded are
+// descended, sorted.
+func (t *Tree) Roots() []Hash {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.roots()
+}
+
+func (t *Tree) roots() []Hash {
+	var roots []Hash
+	for h := range t.children {
+		if _, ok := t.records[h]; !ok {
+			roots = append(roots, h)
+		}
+	}
+	sort.Slice(roots, func(i, j int) bool { return roots[i] < roots[j] })
+	return roots
+}
+
+// Newick writes the Tree to w in Newick format, as one tree for each root
+// on its own line, with each genome labeled by its hash.  If keep is not
+// nil, only the genomes for which keep returns true and their ancestors
+// are written.
+func (t *Tree) Newick(w io.Writer, keep func(h Hash) bool) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Mark the genomes to write, working back from those kept.
+	roots := t.roots()
+	var marked map[Hash]bool
+	if keep != nil {
+		marked = make(map[Hash]bool)
+		for _, h := range append(roots, t.order...) {
+			if !keep(h) {
+				continue
+			}
+			for !marked[h] {
+				marked[h] = true
+				r, ok := t.records[h]
+				if !ok {
+					break
+				}
+				h = r.Parent
+			}
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	for _, root := range roots {
+		if marked != nil && !marked[root] {
+			continue
+		}
+		t.newick(bw, root, marked)
+		bw.WriteString(";\n")
+	}
+	return bw.Flush()
+}
+
+// newick writes the subtree rooted at h.
+func (t *Tree) newick(w *bufio.Writer, h Hash, marked map[Hash]bool) {
+	var children []Hash
+	for _, c := range t.children[h] {
+		if marked == nil || marked[c] {
+			children = append(children, c)
+		}
+	}
+	if len(children) > 0 {
+		w.WriteByte('(')
+		for i, c := range children {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			t.newick(w, c, marked)
+		}
+		w.WriteByte(')')
+	}
+	w.WriteString(h.String())
+}
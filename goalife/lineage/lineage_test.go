@@ -0,0 +1,149 @@
+package lineage
+
+import "bytes"
+import "io/ioutil"
+import "os"
+import "path"
+import "reflect"
+import "testing"
+import "time"
+
+func testTree(t *testing.T, tr *Tree) {
+	// 1 and 2 are seeded.
+	for _, r := range []Record{
+		{Hash: 0x10, Parent: 1, Mutation: "a"},
+		{Hash: 0x11, Parent: 0x10, Mutation: "b"},
+		{Hash: 0x12, Parent: 0x10, Mutation: "c"},
+		{Hash: 0x13, Parent: 0x11, Mutation: "d"},
+		{Hash: 0x20, Parent: 2, Mutation: "e"},
+	} {
+		if ok, err := tr.Add(r); !ok || err != nil {
+			t.Fatalf("expected to add %+v got %v %v", r, ok, err)
+		}
+	}
+	for _, r := range []Record{
+		{Hash: 0x11, Parent: 0x12},
+		{Hash: 0x14, Parent: 0x14},
+		{Hash: 1, Parent: 0x13},
+	} {
+		if ok, _ := tr.Add(r); ok {
+			t.Errorf("expected not to add %+v", r)
+		}
+	}
+}
+
+func TestTree(t *testing.T) {
+	tr := New()
+	testTree(t, tr)
+
+	if tr.Len() != 5 {
+		t.Errorf("expected 5 records got %d", tr.Len())
+	}
+	if roots := tr.Roots(); !reflect.DeepEqual(roots, []Hash{1, 2}) {
+		t.Errorf("expected roots [1 2] got %v", roots)
+	}
+	if c := tr.Children(0x10); !reflect.DeepEqual(c, []Hash{0x11, 0x12}) {
+		t.Errorf("expected children [11 12] got %v", c)
+	}
+	var got []Hash
+	for _, r := range tr.Ancestry(0x13) {
+		got = append(got, r.Hash)
+	}
+	if !reflect.DeepEqual(got, []Hash{0x13, 0x11, 0x10}) {
+		t.Errorf("expected ancestry [13 11 10] got %v", got)
+	}
+	if r, ok := tr.Get(0x12); !ok || r.Parent != 0x10 || r.Mutation != "c" {
+		t.Errorf("expected 12 descended from 10 by c got %+v %v", r, ok)
+	}
+
+	var b bytes.Buffer
+	tr.Newick(&b, nil)
+	expected := "(((13)11,12)10)1;\n(20)2;\n"
+	if b.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, b.String())
+	}
+	b.Reset()
+	tr.Newick(&b, func(h Hash) bool { return h == 0x13 })
+	expected = "(((13)11)10)1;\n"
+	if b.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, b.String())
+	}
+	b.Reset()
+	tr.Newick(&b, func(h Hash) bool { return h == 2 })
+	expected = "2;\n"
+	if b.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, b.String())
+	}
+}
+
+func TestOpen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lineage")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	filename := path.Join(dir, "lineage")
+
+	tr, err := Open(filename)
+	if err != nil {
+		t.Fatalf("error opening: %v", err)
+	}
+	testTree(t, tr)
+	tr.Close()
+
+	tr, err = Open(filename)
+	if err != nil {
+		t.Fatalf("error reopening: %v", err)
+	}
+	if tr.Len() != 5 {
+		t.Errorf("expected 5 records got %d", tr.Len())
+	}
+	if ok, _ := tr.Add(Record{Hash: 0x30, Parent: 0x20}); !ok {
+		t.Errorf("expected to add a record after reopening")
+	}
+	tr.Close()
+
+	if tr, err = Load(filename); err != nil {
+		t.Fatalf("error loading: %v", err)
+	}
+	if r, ok := tr.Get(0x30); !ok || r.Parent != 0x20 {
+		t.Errorf("expected 30 descended from 20 got %+v %v", r, ok)
+	}
+}
+
+type genome struct {
+	hash, parent uint64
+}
+
+func (g genome) Hash() uint64 { return g.hash }
+
+func (g genome) Origin() (uint64, string) { return g.parent, "m" }
+
+type recombinant struct {
+	genome
+	mate uint64
+}
+
+func (r recombinant) Parents() (uint64, uint64) { return r.parent, r.mate }
+
+func TestAddDescendant(t *testing.T) {
+	tr := New()
+	if ok, _ := tr.AddDescendant(time.Now(), genome{1, 0}); ok {
+		t.Errorf("expected a seeded genome not to be added")
+	}
+	if ok, _ := tr.AddDescendant(time.Now(), genome{1, 1}); ok {
+		t.Errorf("expected an unmutated copy not to be added")
+	}
+	if ok, _ := tr.AddDescendant(time.Now(), genome{2, 1}); !ok {
+		t.Errorf("expected a mutated copy to be added")
+	}
+	if r, _ := tr.Get(2); r.Parent != 1 || r.Mate != 0 || r.Mutation != "m" {
+		t.Errorf("expected 2 descended from 1 by m got %+v", r)
+	}
+	if ok, _ := tr.AddDescendant(time.Now(), recombinant{genome{3, 2}, 1}); !ok {
+		t.Errorf("expected a recombinant to be added")
+	}
+	if r, _ := tr.Get(3); r.Parent != 2 || r.Mate != 1 {
+		t.Errorf("expected 3 descended from 2 mated with 1 got %+v", r)
+	}
+}
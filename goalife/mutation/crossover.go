@@ -0,0 +1,37 @@
+package mutation
+
+import "sort"
+
+import "github.com/dnesting/alife/goalife/util/rng"
+
+// Recombination is the kind of mutation made by Crossover.
+const Recombination Kind = "crossover"
+
+// Crossover returns the recombination of code with mate's code, using one
+// or two crossover points, as a mutation of code.  The points are chosen at
+// random in code, and at the same relative offsets in mate.  With one point,
+// the offspring is code up to the point followed by mate's code after it.
+// With two, the segment between the points in code is replaced with the
+// segment between them in mate.  Returns nil if nothing would be taken from
+// mate, or the offspring is the same as code or would be empty.
+func Crossover(code, mate []byte, points int) *Mutation {
+	if len(code) == 0 || len(mate) == 0 {
+		return nil
+	}
+	cuts := []int{rng.Intn(len(code) + 1), len(code)}
+	if points > 1 {
+		cuts[1] = rng.Intn(len(code) + 1)
+		sort.Ints(cuts)
+	}
+	i, j := cuts[0], cuts[1]
+	mi, mj := i*len(mate)/len(code), j*len(mate)/len(code)
+	if mi == mj {
+		return nil
+	}
+
+	result := make([]byte, 0, len(code)-(j-i)+(mj-mi))
+	result = append(result, code[:i]...)
+	result = append(result, mate[mi:mj]...)
+	result = append(result, code[j:]...)
+	return span(Recombination, mj-mi, code, result)
+}
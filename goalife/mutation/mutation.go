@@ -0,0 +1,158 @@
+// Package mutation describes the changes made to genomes when they are
+// mutated, and records them along with their outcome, so that the spectrum
+// of mutations and their effect on fitness can be measured across a run.
+package mutation
+
+import "encoding/hex"
+import "encoding/json"
+import "fmt"
+import "io"
+import "sync"
+import "time"
+
+import "github.com/dnesting/alife/goalife/lineage"
+
+// Kind is a kind of mutation.
+type Kind string
+
+const (
+	Point       Kind = "point"     // a single instruction is changed
+	Duplication Kind = "duplicate" // a segment is copied in front of itself
+	Deletion    Kind = "delete"    // a segment is removed
+)
+
+// Bytes is a []byte written in hex.
+type Bytes []byte
+
+// MarshalText encodes b in hex.
+func (b Bytes) MarshalText() ([]byte, error) {
+	return []byte(hex.EncodeToString(b)), nil
+}
+
+// UnmarshalText decodes b from hex.
+func (b *Bytes) UnmarshalText(text []byte) error {
+	d, err := hex.DecodeString(string(text))
+	*b = d
+	return err
+}
+
+// Mutation describes exactly how a genome was changed.
+type Mutation struct {
+	Kind   Kind
+	Offset int   // the offset in the original genome of the change
+	Length int   // the number of bytes changed, inserted or removed
+	Old    Bytes // the bytes at Offset replaced, if any
+	New    Bytes // the bytes inserted at Offset in their place, if any
+}
+
+func (m *Mutation) String() string {
+	switch m.Kind {
+	case Point:
+		return fmt.Sprintf("point %d: %x -> %x", m.Offset, []byte(m.Old), []byte(m.New))
+	case Duplication, Deletion, Recombination:
+		return fmt.Sprintf("%s %d at %d", m.Kind, m.Length, m.Offset)
+	}
+	return fmt.Sprintf("%s %d at %d: %x -> %x", m.Kind, m.Length, m.Offset, []byte(m.Old), []byte(m.New))
+}
+
+// Apply returns the result of making m to code, which is not modified.
+func (m *Mutation) Apply(code []byte) []byte {
+	d := make([]byte, 0, len(code)-len(m.Old)+len(m.New))
+	d = append(d, code[:m.Offset]...)
+	d = append(d, m.New...)
+	return append(d, code[m.Offset+len(m.Old):]...)
+}
+
+// Event records a mutant's birth and its outcome: whether it ever divided,
+// or died first.
+type Event struct {
+	Born     time.Time
+	Parent   lineage.Hash // the genome mutated
+	Child    lineage.Hash // the mutant genome
+	Mutation *Mutation
+	Divided  bool    // true if the mutant divided
+	Lifetime float64 // seconds from the mutant's birth until it divided or died
+	Cause    string  `json:",omitempty"` // what the mutant died of, if it died first and it is known
+
+	log  *Log
+	once sync.Once
+}
+
+// Resolve records the outcome of e, if it is the first call to do so.  The
+// Event is then written to its Log.  e may be nil.
+func (e *Event) Resolve(divided bool) {
+	e.resolve(divided, nil)
+}
+
+// Died records that the mutant died without dividing, of cause if it is not
+// nil, as Resolve(false).
+func (e *Event) Died(cause error) {
+	e.resolve(false, cause)
+}
+
+func (e *Event) resolve(divided bool, cause error) {
+	if e == nil {
+		return
+	}
+	e.once.Do(func() {
+		e.Divided = divided
+		e.Lifetime = time.Since(e.Born).Seconds()
+		if cause != nil {
+			e.Cause = cause.Error()
+		}
+		e.log.write(e)
+	})
+}
+
+// Log writes Events, once resolved, as JSON, one per line.  Mutants that
+// are still alive without having divided are not written.
+type Log struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	err error
+}
+
+// NewLog returns a Log writing to w.
+func NewLog(w io.Writer) *Log {
+	return &Log{enc: json.NewEncoder(w)}
+}
+
+func (l *Log) write(e *Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err == nil {
+		l.err = l.enc.Encode(e)
+	}
+}
+
+// Err returns the first error writing to the Log.
+func (l *Log) Err() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.err
+}
+
+// Events, if not nil, is the Log recording mutations as they happen.
+var Events *Log
+
+// Born returns a new Event recording the birth of the mutant child from
+// parent by m, to be resolved once its outcome is known.  Returns nil if
+// Events is nil.
+func Born(parent, child uint64, m *Mutation) *Event {
+	if Events == nil {
+		return nil
+	}
+	return &Event{
+		Born:     time.Now(),
+		Parent:   lineage.Hash(parent),
+		Child:    lineage.Hash(child),
+		Mutation: m,
+		log:      Events,
+	}
+}
+
+// Mutant is an organism driver that may be a mutant awaiting the outcome of
+// its Event.
+type Mutant interface {
+	MutationEvent() *Event
+}
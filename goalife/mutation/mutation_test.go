@@ -0,0 +1,76 @@
+package mutation
+
+import "bytes"
+import "encoding/json"
+import "errors"
+import "testing"
+
+func TestMutation(t *testing.T) {
+	code := []byte{0, 1, 2, 3, 4, 5}
+	cases := []struct {
+		m        Mutation
+		s        string
+		expected []byte
+	}{
+		{Mutation{Point, 2, 1, Bytes{2}, Bytes{9}}, "point 2: 02 -> 09", []byte{0, 1, 9, 3, 4, 5}},
+		{Mutation{Duplication, 1, 2, nil, Bytes{1, 2}}, "duplicate 2 at 1", []byte{0, 1, 2, 1, 2, 3, 4, 5}},
+		{Mutation{Deletion, 4, 2, Bytes{4, 5}, nil}, "delete 2 at 4", []byte{0, 1, 2, 3}},
+	}
+	for _, c := range cases {
+		if s := c.m.String(); s != c.s {
+			t.Errorf("expected %q got %q", c.s, s)
+		}
+		if got := c.m.Apply(code); !bytes.Equal(got, c.expected) {
+			t.Errorf("%v: expected %v got %v", &c.m, c.expected, got)
+		}
+	}
+	if !bytes.Equal(code, []byte{0, 1, 2, 3, 4, 5}) {
+		t.Errorf("expected Apply to leave code unchanged got %v", code)
+	}
+}
+
+func TestLog(t *testing.T) {
+	if Born(1, 2, &Mutation{}) != nil {
+		t.Errorf("expected no Event without a Log")
+	}
+	var nilEvent *Event
+	nilEvent.Resolve(true)
+	nilEvent.Died(nil)
+
+	var b bytes.Buffer
+	Events = NewLog(&b)
+	defer func() { Events = nil }()
+
+	e := Born(0x1a, 0x2b, &Mutation{Point, 3, 1, Bytes{0xff}, Bytes{0}})
+	if b.Len() != 0 {
+		t.Errorf("expected nothing written before the Event is resolved got %s", b.String())
+	}
+	e.Resolve(true)
+	e.Resolve(false)
+	Born(0x2b, 0x3c, &Mutation{Point, 0, 1, Bytes{1}, Bytes{2}}).Died(errors.New("old age"))
+
+	var got []map[string]interface{}
+	dec := json.NewDecoder(&b)
+	for dec.More() {
+		var m map[string]interface{}
+		if err := dec.Decode(&m); err != nil {
+			t.Fatalf("error decoding: %v", err)
+		}
+		got = append(got, m)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 Events written got %d", len(got))
+	}
+	if got[0]["Parent"] != "1a" || got[0]["Child"] != "2b" || got[0]["Divided"] != true {
+		t.Errorf("expected 1a -> 2b divided got %v", got[0])
+	}
+	if m := got[0]["Mutation"].(map[string]interface{}); m["Old"] != "ff" || m["New"] != "00" {
+		t.Errorf("expected ff -> 00 got %v", m)
+	}
+	if _, ok := got[0]["Cause"]; ok {
+		t.Errorf("expected no cause of death for a mutant that divided got %v", got[0])
+	}
+	if got[1]["Divided"] != false || got[1]["Cause"] != "old age" {
+		t.Errorf("expected a mutant that died of old age got %v", got[1])
+	}
+}
@@ -0,0 +1,295 @@
+package mutation
+
+import "fmt"
+import "math"
+import "sort"
+import "strconv"
+import "strings"
+import "sync"
+
+import "github.com/dnesting/alife/goalife/util/rng"
+
+// More kinds of mutation, made by the Operators registered by default.
+const (
+	Insertion     Kind = "insert"    // random instructions are inserted
+	Inversion     Kind = "invert"    // a segment is reversed
+	Transposition Kind = "transpose" // a segment is moved elsewhere
+	CopyError     Kind = "copy"      // each instruction may be miscopied
+)
+
+// Operator makes a mutation of kind to code, whose instructions are the
+// bytes from 0 to maxOp-1, as described by s.  It returns the mutation,
+// which must leave at least one instruction, or nil if it made none.
+type Operator func(code []byte, maxOp int, s *Scheme) *Mutation
+
+var operators = struct {
+	sync.Mutex
+	m map[Kind]Operator
+}{m: map[Kind]Operator{
+	Point:         point,
+	Insertion:     insertion,
+	Deletion:      deletion,
+	Duplication:   duplication,
+	Inversion:     inversion,
+	Transposition: transposition,
+	CopyError:     copyError,
+}}
+
+// Register makes op available to Schemes as kind.
+func Register(kind Kind, op Operator) {
+	operators.Lock()
+	defer operators.Unlock()
+	operators.m[kind] = op
+}
+
+// Kinds returns the kinds of the Operators registered, sorted.
+func Kinds() []Kind {
+	operators.Lock()
+	defer operators.Unlock()
+	var kinds []Kind
+	for k := range operators.m {
+		kinds = append(kinds, k)
+	}
+	sort.Slice(kinds, func(i, j int) bool { return kinds[i] < kinds[j] })
+	return kinds
+}
+
+// Scheme describes how genomes are mutated.
+type Scheme struct {
+	// Weights gives the relative probability of each kind of mutation being
+	// chosen when a genome is mutated.
+	Weights map[Kind]float64
+
+	// Segment is the standard deviation of the lengths of the segments
+	// operated on, which are normally distributed about 0 (and at least 1).
+	Segment float64
+
+	// CopyError is the probability that each instruction is miscopied by a
+	// CopyError mutation.
+	CopyError float64
+}
+
+// DefaultScheme returns the default Scheme, choosing equally between a
+// Point mutation, a Duplication and a Deletion.
+func DefaultScheme() *Scheme {
+	return &Scheme{
+		Weights:   map[Kind]float64{Point: 1, Duplication: 1, Deletion: 1},
+		Segment:   5,
+		CopyError: 0.01,
+	}
+}
+
+// ParseWeights parses weights given as kind=weight,...
+func ParseWeights(s string) (map[Kind]float64, error) {
+	w := make(map[Kind]float64)
+	for _, f := range strings.Split(s, ",") {
+		i := strings.Index(f, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("expected kind=weight got %q", f)
+		}
+		weight, err := strconv.ParseFloat(f[i+1:], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid weight %q", f[i+1:])
+		}
+		w[Kind(f[:i])] = weight
+	}
+	return w, nil
+}
+
+// Check returns an error if s can't be used.
+func (s *Scheme) Check() error {
+	operators.Lock()
+	defer operators.Unlock()
+	var total float64
+	for k, w := range s.Weights {
+		if _, ok := operators.m[k]; !ok {
+			return fmt.Errorf("unknown kind of mutation %q", k)
+		}
+		if w < 0 {
+			return fmt.Errorf("weight %g of %s is negative", w, k)
+		}
+		total += w
+	}
+	switch {
+	case total <= 0:
+		return fmt.Errorf("no kinds of mutation weighted")
+	case s.Segment <= 0:
+		return fmt.Errorf("Segment %g must be positive", s.Segment)
+	case s.CopyError < 0 || s.CopyError > 1:
+		return fmt.Errorf("CopyError %g is not a probability", s.CopyError)
+	}
+	return nil
+}
+
+var current = DefaultScheme()
+
+// Set makes s the Scheme in effect, after checking it.  It must be called
+// before any organisms begin executing.
+func Set(s *Scheme) error {
+	if err := s.Check(); err != nil {
+		return err
+	}
+	current = s
+	return nil
+}
+
+// Current returns the Scheme in effect.  It must not be modified.
+func Current() *Scheme {
+	return current
+}
+
+// Mutate makes a mutation to code, whose instructions are the bytes from 0
+// to maxOp-1, of a kind chosen by the weights of the current Scheme.  It
+// returns the mutation, or nil if it made none; code is not modified (see
+// Mutation.Apply).
+func Mutate(code []byte, maxOp int) *Mutation {
+	s := current
+	if len(code) == 0 {
+		return nil
+	}
+	var total float64
+	kinds := make([]Kind, 0, len(s.Weights))
+	for k, w := range s.Weights {
+		kinds = append(kinds, k)
+		total += w
+	}
+	// Choose in a consistent order, so that a seeded run is reproducible.
+	sort.Slice(kinds, func(i, j int) bool { return kinds[i] < kinds[j] })
+	r := rng.Float64() * total
+	for _, k := range kinds {
+		if r -= s.Weights[k]; r < 0 {
+			operators.Lock()
+			op := operators.m[k]
+			operators.Unlock()
+			return op(code, maxOp, s)
+		}
+	}
+	return nil
+}
+
+// segment returns the length of a segment, as described by s.
+func (s *Scheme) segment() int {
+	l := int(math.Ceil(math.Abs(rng.NormFloat64() * s.Segment)))
+	if l < 1 {
+		l = 1
+	}
+	return l
+}
+
+// span returns a Mutation of kind changing code into result, covering only
+// the bytes that differ, or nil if there are none or result is empty.
+func span(kind Kind, length int, code, result []byte) *Mutation {
+	if len(result) == 0 {
+		return nil
+	}
+	n := len(code)
+	if len(result) < n {
+		n = len(result)
+	}
+	p := 0
+	for p < n && code[p] == result[p] {
+		p++
+	}
+	s := 0
+	for s < n-p && code[len(code)-1-s] == result[len(result)-1-s] {
+		s++
+	}
+	if p == len(code)-s && p == len(result)-s {
+		return nil
+	}
+	m := &Mutation{Kind: kind, Offset: p, Length: length}
+	if old := code[p : len(code)-s]; len(old) > 0 {
+		m.Old = Bytes(old)
+	}
+	if nw := result[p : len(result)-s]; len(nw) > 0 {
+		m.New = Bytes(nw)
+	}
+	return m
+}
+
+func randomBytes(n, maxOp int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(rng.Intn(maxOp))
+	}
+	return b
+}
+
+// point changes a single instruction.
+func point(code []byte, maxOp int, s *Scheme) *Mutation {
+	i := rng.Intn(len(code))
+	return &Mutation{Kind: Point, Offset: i, Length: 1, Old: Bytes(code[i : i+1]), New: randomBytes(1, maxOp)}
+}
+
+// insertion inserts a segment of random instructions.
+func insertion(code []byte, maxOp int, s *Scheme) *Mutation {
+	i := rng.Intn(len(code) + 1)
+	l := s.segment()
+	return &Mutation{Kind: Insertion, Offset: i, Length: l, New: randomBytes(l, maxOp)}
+}
+
+// deletion removes a segment.
+func deletion(code []byte, maxOp int, s *Scheme) *Mutation {
+	i := rng.Intn(len(code))
+	l := s.segment()
+	if i+l > len(code) {
+		l = len(code) - i
+	}
+	if l == len(code) {
+		return nil
+	}
+	return &Mutation{Kind: Deletion, Offset: i, Length: l, Old: Bytes(code[i : i+l])}
+}
+
+// duplication inserts a copy of a segment in front of itself, wrapping
+// around the end of the code.
+func duplication(code []byte, maxOp int, s *Scheme) *Mutation {
+	i := rng.Intn(len(code))
+	l := s.segment()
+	d := make([]byte, l)
+	for j := range d {
+		d[j] = code[(i+j)%len(code)]
+	}
+	return &Mutation{Kind: Duplication, Offset: i, Length: l, New: d}
+}
+
+// inversion reverses a segment.
+func inversion(code []byte, maxOp int, s *Scheme) *Mutation {
+	i := rng.Intn(len(code))
+	l := s.segment()
+	if i+l > len(code) {
+		l = len(code) - i
+	}
+	result := append([]byte(nil), code...)
+	for a, b := i, i+l-1; a < b; a, b = a+1, b-1 {
+		result[a], result[b] = result[b], result[a]
+	}
+	return span(Inversion, l, code, result)
+}
+
+// transposition moves a segment elsewhere.
+func transposition(code []byte, maxOp int, s *Scheme) *Mutation {
+	i := rng.Intn(len(code))
+	l := s.segment()
+	if i+l > len(code) {
+		l = len(code) - i
+	}
+	seg := code[i : i+l]
+	rest := append(append([]byte(nil), code[:i]...), code[i+l:]...)
+	j := rng.Intn(len(rest) + 1)
+	result := append(append(append([]byte(nil), rest[:j]...), seg...), rest[j:]...)
+	return span(Transposition, l, code, result)
+}
+
+// copyError miscopies each instruction with probability s.CopyError.
+func copyError(code []byte, maxOp int, s *Scheme) *Mutation {
+	result := append([]byte(nil), code...)
+	n := 0
+	for i := range result {
+		if rng.Float64() < s.CopyError {
+			result[i] = byte(rng.Intn(maxOp))
+			n++
+		}
+	}
+	return span(CopyError, n, code, result)
+}
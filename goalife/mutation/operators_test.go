@@ -0,0 +1,133 @@
+package mutation
+
+import "bytes"
+import "reflect"
+import "sort"
+import "testing"
+
+import "github.com/dnesting/alife/goalife/util/rng"
+
+func sorted(b []byte) []byte {
+	s := append([]byte(nil), b...)
+	sort.Slice(s, func(i, j int) bool { return s[i] < s[j] })
+	return s
+}
+
+func TestOperators(t *testing.T) {
+	defer Set(DefaultScheme())
+	rng.Seed(1)
+	code := []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+	for _, kind := range Kinds() {
+		s := DefaultScheme()
+		s.Weights = map[Kind]float64{kind: 1}
+		s.CopyError = 0.2
+		if err := Set(s); err != nil {
+			t.Fatalf("%s: error setting scheme: %v", kind, err)
+		}
+		n := 0
+		for i := 0; i < 100; i++ {
+			m := Mutate(code, 10)
+			if m == nil {
+				continue
+			}
+			n++
+			if m.Kind != kind {
+				t.Fatalf("expected %s got %v", kind, m)
+			}
+			if !bytes.Equal(code[m.Offset:m.Offset+len(m.Old)], m.Old) {
+				t.Fatalf("%v: expected Old to match %v", m, code)
+			}
+			got := m.Apply(code)
+			if len(got) == 0 {
+				t.Fatalf("%v: expected code to remain got none", m)
+			}
+			switch kind {
+			case Point:
+				if len(got) != len(code) {
+					t.Errorf("%v: expected length %d got %v", m, len(code), got)
+				}
+			case Insertion, Duplication:
+				if len(got) != len(code)+m.Length {
+					t.Errorf("%v: expected %d instructions inserted got %v", m, m.Length, got)
+				}
+			case Deletion:
+				if len(got) != len(code)-m.Length {
+					t.Errorf("%v: expected %d instructions deleted got %v", m, m.Length, got)
+				}
+			case Inversion, Transposition:
+				if !bytes.Equal(sorted(got), code) || bytes.Equal(got, code) {
+					t.Errorf("%v: expected a rearrangement of %v got %v", m, code, got)
+				}
+			case CopyError:
+				if len(got) != len(code) || m.Length < 1 {
+					t.Errorf("%v: expected miscopied instructions got %v", m, got)
+				}
+			}
+		}
+		if n == 0 {
+			t.Errorf("expected some %s mutations", kind)
+		}
+	}
+}
+
+func TestScheme(t *testing.T) {
+	w, err := ParseWeights("point=1,invert=0.5")
+	if err != nil {
+		t.Fatalf("error parsing weights: %v", err)
+	}
+	if expected := map[Kind]float64{Point: 1, Inversion: 0.5}; !reflect.DeepEqual(w, expected) {
+		t.Errorf("expected %v got %v", expected, w)
+	}
+	if _, err := ParseWeights("point"); err == nil {
+		t.Errorf("expected an error parsing a kind without a weight")
+	}
+
+	for _, s := range []*Scheme{
+		{Weights: map[Kind]float64{"bogus": 1}, Segment: 5},
+		{Weights: map[Kind]float64{Point: -1, Deletion: 2}, Segment: 5},
+		{Weights: map[Kind]float64{Point: 0}, Segment: 5},
+		{Weights: map[Kind]float64{Point: 1}, Segment: 0},
+		{Weights: map[Kind]float64{Point: 1}, Segment: 5, CopyError: 2},
+	} {
+		if err := Set(s); err == nil {
+			t.Errorf("expected an error setting %+v", s)
+		}
+	}
+	if Current().Segment != DefaultScheme().Segment {
+		t.Errorf("expected an invalid Scheme not to be set")
+	}
+}
+
+func TestCrossover(t *testing.T) {
+	rng.Seed(1)
+	code := []byte{0, 1, 2, 3, 4, 5, 6, 7}
+	mate := []byte{10, 11, 12, 13}
+	for i := 0; i < 100; i++ {
+		for points := 1; points <= 2; points++ {
+			m := Crossover(code, mate, points)
+			if m == nil {
+				continue
+			}
+			got := m.Apply(code)
+			if len(got) == 0 || m.Kind != Recombination {
+				t.Fatalf("%v: expected a non-empty crossover got %v", m, got)
+			}
+			// The offspring is code with a run of mate in place of the rest
+			// (with one point) or of a segment (with two).
+			a := 0
+			for a < len(got) && got[a] < 10 {
+				a++
+			}
+			b := a
+			for b < len(got) && got[b] >= 10 {
+				b++
+			}
+			if !bytes.Equal(got[:a], code[:a]) || b < len(got) && !bytes.Equal(got[b:], code[len(code)-(len(got)-b):]) {
+				t.Errorf("%v: expected code around the crossover got %v", m, got)
+			}
+			if points == 1 && b != len(got) {
+				t.Errorf("%v: expected one point to end with mate got %v", m, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,224 @@
+// Package physics describes the costs and constants governing how
+// organisms interact with the world, so that experiments can vary them
+// without recompiling.
+//
+// The Physics in effect is consulted by package org and by the Cpu
+// implementations, and is recorded in autosave snapshots.  It is read from
+// a JSON file in which any field left out keeps its default, such as:
+//
+//	{
+//	    "BodyEnergy": 2000,
+//	    "Costs": {"cpu1.Ops": {"Forward": 20, "Divide": 5}}
+//	}
+package physics
+
+import "bytes"
+import "encoding/json"
+import "fmt"
+import "io"
+import "os"
+import "sort"
+import "sync"
+
+// Physics describes the costs and constants of the world.
+type Physics struct {
+	// BodyEnergy is the energy of an organism's body.  Creating an organism
+	// costs at least this much energy, and when one dies, it is replaced with
+	// Food storing this much energy in addition to its own.
+	BodyEnergy int
+
+	// SenseDistance is the maximum distance an organism can sense.
+	SenseDistance int
+
+	// SenseFalloffExp describes the exponential falloff of sensed energy as
+	// distance increases.
+	SenseFalloffExp float64
+
+	// EatRatio is the amount of energy an organism can attempt to eat for
+	// each unit of energy the attempt costs.
+	EatRatio int
+
+	// MoveCost is the energy cost of moving forward, in addition to the cost
+	// of the instruction doing so.
+	MoveCost int
+
+	// StepCost is the energy cost of executing any instruction, to avoid
+	// infinite loops.  It must be at least 1.
+	StepCost int
+
+	// MaxAge is the number of steps an organism can execute before it dies
+	// of old age, or 0 if organisms don't die of old age.
+	MaxAge int64 `json:",omitempty"`
+
+	// AgingCost is the energy cost of executing any instruction, in addition
+	// to StepCost, for each step the organism has already executed.  The
+	// cost is rounded down, so that 0.001 costs 1 more per step after the
+	// 1000th step.
+	AgingCost float64 `json:",omitempty"`
+
+	// Costs holds the cost of instructions (above StepCost) differing from
+	// their defaults, by the name of their opcode table and then instruction.
+	Costs map[string]map[string]int `json:",omitempty"`
+}
+
+// Default returns the default Physics.
+func Default() *Physics {
+	return &Physics{
+		BodyEnergy:      1000,
+		SenseDistance:   10,
+		SenseFalloffExp: 2,
+		EatRatio:        100,
+		MoveCost:        1,
+		StepCost:        1,
+	}
+}
+
+var current = Default()
+
+// Current returns the Physics in effect.  It must not be modified.
+func Current() *Physics {
+	return current
+}
+
+// String returns p encoded as JSON on a single line.
+func (p *Physics) String() string {
+	b, _ := json.Marshal(p)
+	return string(b)
+}
+
+// UnmarshalJSON decodes p from JSON.  Fields left out take their defaults,
+// and unknown fields are an error.  This applies wherever a Physics is
+// decoded, including within other values.
+func (p *Physics) UnmarshalJSON(b []byte) error {
+	type plain Physics // without this method
+	q := plain(*Default())
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&q); err != nil {
+		return err
+	}
+	*p = Physics(q)
+	return nil
+}
+
+// Decode reads a Physics encoded as JSON from r, as UnmarshalJSON.
+func Decode(r io.Reader) (*Physics, error) {
+	p := &Physics{}
+	if err := json.NewDecoder(r).Decode(p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Parse decodes a Physics from s, as returned by String.
+func Parse(s string) (*Physics, error) {
+	return Decode(bytes.NewBufferString(s))
+}
+
+// Load reads a Physics from the JSON file filename.
+func Load(filename string) (*Physics, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	p, err := Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return p, nil
+}
+
+type costTable struct {
+	names func() []string
+	set   func(costs map[string]int)
+}
+
+var registry = struct {
+	sync.Mutex
+	tables map[string]costTable
+}{tables: make(map[string]costTable)}
+
+// RegisterCosts registers an opcode table named name whose costs can be
+// configured.  names returns the names of its instructions, and set is
+// called by Set with the costs differing from their defaults (nil if none).
+func RegisterCosts(name string, names func() []string, set func(costs map[string]int)) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.tables[name] = costTable{names, set}
+}
+
+// Tables returns the names of the registered opcode tables, sorted.
+func Tables() []string {
+	registry.Lock()
+	defer registry.Unlock()
+	var names []string
+	for name := range registry.tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Check returns an error if p describes an impossible world, or the costs
+// of instructions that don't exist.
+func (p *Physics) Check() error {
+	switch {
+	case p.BodyEnergy < 0:
+		return fmt.Errorf("BodyEnergy %d is negative", p.BodyEnergy)
+	case p.SenseDistance < 0:
+		return fmt.Errorf("SenseDistance %d is negative", p.SenseDistance)
+	case p.EatRatio < 1:
+		return fmt.Errorf("EatRatio %d must be at least 1", p.EatRatio)
+	case p.MoveCost < 0:
+		return fmt.Errorf("MoveCost %d is negative", p.MoveCost)
+	case p.StepCost < 1:
+		return fmt.Errorf("StepCost %d must be at least 1", p.StepCost)
+	case p.MaxAge < 0:
+		return fmt.Errorf("MaxAge %d is negative", p.MaxAge)
+	case p.AgingCost < 0:
+		return fmt.Errorf("AgingCost %g is negative", p.AgingCost)
+	}
+	registry.Lock()
+	defer registry.Unlock()
+	var tables []string
+	for name := range p.Costs {
+		tables = append(tables, name)
+	}
+	sort.Strings(tables)
+	for _, name := range tables {
+		t, ok := registry.tables[name]
+		if !ok {
+			return fmt.Errorf("unknown opcode table %q", name)
+		}
+		known := make(map[string]bool)
+		for _, op := range t.names() {
+			known[op] = true
+		}
+		for op, cost := range p.Costs[name] {
+			if !known[op] {
+				return fmt.Errorf("%s: unknown instruction %q", name, op)
+			}
+			if cost < 0 {
+				return fmt.Errorf("%s: cost %d of %s is negative", name, cost, op)
+			}
+		}
+	}
+	return nil
+}
+
+// Set makes p the Physics in effect, after checking it, and applies its
+// instruction costs to each registered opcode table.  It must be called
+// before any organisms begin executing.
+func Set(p *Physics) error {
+	if err := p.Check(); err != nil {
+		return err
+	}
+	registry.Lock()
+	defer registry.Unlock()
+	for name, t := range registry.tables {
+		t.set(p.Costs[name])
+	}
+	current = p
+	return nil
+}
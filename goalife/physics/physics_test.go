@@ -0,0 +1,65 @@
+package physics
+
+import "reflect"
+import "strings"
+import "testing"
+
+func TestDecode(t *testing.T) {
+	p, err := Parse(`{"BodyEnergy": 2000, "Costs": {"t": {"a": 3}}}`)
+	if err != nil {
+		t.Fatalf("error parsing: %v", err)
+	}
+	expected := Default()
+	expected.BodyEnergy = 2000
+	expected.Costs = map[string]map[string]int{"t": {"a": 3}}
+	if !reflect.DeepEqual(p, expected) {
+		t.Errorf("expected %v got %v", expected, p)
+	}
+	if q, err := Parse(p.String()); err != nil || !reflect.DeepEqual(q, p) {
+		t.Errorf("expected %v to round-trip, got %v (err=%v)", p, q, err)
+	}
+	if _, err := Decode(strings.NewReader(`{"BodyEnergie": 2000}`)); err == nil {
+		t.Errorf("expected an error decoding an unknown field")
+	}
+}
+
+func TestSet(t *testing.T) {
+	defer Set(Default())
+	var got map[string]int
+	RegisterCosts("t", func() []string { return []string{"a", "b"} }, func(c map[string]int) { got = c })
+
+	bad := []string{
+		`{"StepCost": 0}`,
+		`{"EatRatio": 0}`,
+		`{"BodyEnergy": -1}`,
+		`{"MaxAge": -1}`,
+		`{"AgingCost": -0.5}`,
+		`{"Costs": {"nonexistent": {"a": 1}}}`,
+		`{"Costs": {"t": {"c": 1}}}`,
+		`{"Costs": {"t": {"a": -1}}}`,
+	}
+	for _, s := range bad {
+		p, err := Parse(s)
+		if err != nil {
+			t.Fatalf("error parsing %s: %v", s, err)
+		}
+		if err := Set(p); err == nil {
+			t.Errorf("expected an error setting %s", s)
+		}
+	}
+	if Current().String() != Default().String() {
+		t.Errorf("expected a rejected physics not to take effect, got %v", Current())
+	}
+
+	p, _ := Parse(`{"MoveCost": 5, "Costs": {"t": {"b": 7}}}`)
+	if err := Set(p); err != nil {
+		t.Fatalf("error setting %v: %v", p, err)
+	}
+	if Current() != p || !reflect.DeepEqual(got, map[string]int{"b": 7}) {
+		t.Errorf("expected %v to take effect with costs b=7, got %v with %v", p, Current(), got)
+	}
+	Set(Default())
+	if got != nil {
+		t.Errorf("expected the default physics to reset costs, got %v", got)
+	}
+}
@@ -0,0 +1,226 @@
+// Package stats collects statistics describing a world over time, for
+// plotting.  A Collector subscribes to the changes made to a Grid to count
+// deaths and moves, consults org.CountActions for births and eats, and
+// scans the Grid each time it is sampled for its population and energy.
+// Samples are written as CSV or as JSON, one per line.
+package stats
+
+import "encoding/csv"
+import "encoding/json"
+import "fmt"
+import "io"
+import "strconv"
+import "sync"
+import "time"
+
+import "github.com/dnesting/alife/goalife/census"
+import "github.com/dnesting/alife/goalife/grid2d"
+import "github.com/dnesting/alife/goalife/grid2d/food"
+import "github.com/dnesting/alife/goalife/grid2d/org"
+import "github.com/dnesting/alife/goalife/grid2d/org/cpu1"
+import "github.com/dnesting/alife/goalife/grid2d/org/cpu2"
+
+// Sample describes a world at a moment, and what happened in it since the
+// previous Sample.
+type Sample struct {
+	Elapsed    float64 // seconds since the Collector was created
+	Orgs       int     // organisms living
+	Species    int     // distinct genomes among them
+	OrgEnergy  int64   // energy stored by organisms
+	FoodEnergy int64   // energy stored by food
+	MeanLength float64 // the mean length of organisms' genomes, in bytes
+	MeanAge    float64 // the mean number of steps organisms have executed
+
+	// Since the previous Sample:
+	Births int   // organisms born by division
+	Deaths int   // organisms that died
+	Aged   int   // organisms that died of old age (see org.ErrOldAge)
+	Moves  int   // organisms that moved
+	Eats   int   // successful attempts to eat
+	Eaten  int64 // energy eaten
+}
+
+// Fields names the fields of a Sample, in the order they are written.
+var Fields = []string{
+	"Elapsed", "Orgs", "Species", "OrgEnergy", "FoodEnergy", "MeanLength", "MeanAge",
+	"Births", "Deaths", "Aged", "Moves", "Eats", "Eaten",
+}
+
+// Collector accumulates statistics about a Grid.
+type Collector struct {
+	g     grid2d.Grid
+	start time.Time
+
+	mu     sync.Mutex
+	deaths int
+	aged   int
+	moves  int
+	last   org.Actions
+}
+
+// New returns a new Collector for g.  It counts only the changes given to
+// Update, so it will normally be followed by a call to Subscribe.
+func New(g grid2d.Grid) *Collector {
+	return &Collector{g: g, start: time.Now(), last: org.CountActions()}
+}
+
+func isOrg(v interface{}) bool {
+	_, ok := v.(*org.Organism)
+	return ok
+}
+
+// Subscribe subscribes c to the changes made to its Grid.
+func (c *Collector) Subscribe() {
+	ch := make(chan []grid2d.Update, 0)
+	c.g.Subscribe(ch, grid2d.Unbounded(), grid2d.Kinds(grid2d.Removed|grid2d.Moved|grid2d.Replaced), grid2d.Occupants(isOrg))
+	go func() {
+		for updates := range ch {
+			c.Update(updates)
+		}
+	}()
+}
+
+// Update counts the deaths and moves among updates, and the deaths of old
+// age.
+func (c *Collector) Update(updates []grid2d.Update) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, u := range updates {
+		if u.Old == nil || !isOrg(u.Old.V) {
+			continue
+		}
+		switch {
+		case u.IsMove():
+			c.moves++
+		case u.IsRemove(), u.IsReplace():
+			c.deaths++
+			if u.Old.V.(*org.Organism).Cause() == org.ErrOldAge {
+				c.aged++
+			}
+		}
+	}
+}
+
+// genomeLength returns the length of the genome of the Cpu driving an
+// organism, or 0 if it isn't known.
+func genomeLength(driver interface{}) int {
+	switch c := driver.(type) {
+	case *cpu1.Cpu:
+		return len(c.Code)
+	case *cpu2.Cpu:
+		return len(c.Code)
+	}
+	return 0
+}
+
+// Sample scans the Grid and returns a Sample describing it, counting what
+// happened since the previous call.
+func (c *Collector) Sample() *Sample {
+	s := &Sample{Elapsed: time.Since(c.start).Seconds()}
+	var locs []grid2d.Point
+	c.g.Locations(&locs)
+	species := make(map[uint64]bool)
+	var length int
+	var age int64
+	for _, l := range locs {
+		switch v := l.V.(type) {
+		case *org.Organism:
+			s.Orgs++
+			s.OrgEnergy += int64(v.Energy())
+			length += genomeLength(v.Driver)
+			steps, _ := v.Age()
+			age += steps
+			if k, ok := v.Driver.(census.Key); ok {
+				species[k.Hash()] = true
+			}
+		case *food.Food:
+			s.FoodEnergy += int64(v.Energy())
+		}
+	}
+	s.Species = len(species)
+	if s.Orgs > 0 {
+		s.MeanLength = float64(length) / float64(s.Orgs)
+		s.MeanAge = float64(age) / float64(s.Orgs)
+	}
+
+	a := org.CountActions()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s.Births = int(a.Divides - c.last.Divides)
+	s.Eats = int(a.Eats - c.last.Eats)
+	s.Eaten = a.Eaten - c.last.Eaten
+	s.Deaths, s.Aged, s.Moves = c.deaths, c.aged, c.moves
+	c.last, c.deaths, c.aged, c.moves = a, 0, 0, 0
+	return s
+}
+
+// Run writes a Sample to w every interval until stop is closed.  Returns
+// the first error writing to w.
+func (c *Collector) Run(w Writer, interval time.Duration, stop <-chan bool) error {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-t.C:
+			if err := w.Write(c.Sample()); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Writer writes Samples.
+type Writer interface {
+	Write(s *Sample) error
+}
+
+// NewWriter returns a Writer writing Samples to w in format, which is csv
+// or json.  CSV begins with a header naming the Fields.
+func NewWriter(w io.Writer, format string) (Writer, error) {
+	switch format {
+	case "csv":
+		return &csvWriter{w: csv.NewWriter(w)}, nil
+	case "json":
+		return &jsonWriter{json.NewEncoder(w)}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+type csvWriter struct {
+	w      *csv.Writer
+	header bool
+}
+
+func (w *csvWriter) Write(s *Sample) error {
+	if !w.header {
+		w.w.Write(Fields)
+		w.header = true
+	}
+	w.w.Write([]string{
+		strconv.FormatFloat(s.Elapsed, 'f', 3, 64),
+		strconv.Itoa(s.Orgs),
+		strconv.Itoa(s.Species),
+		strconv.FormatInt(s.OrgEnergy, 10),
+		strconv.FormatInt(s.FoodEnergy, 10),
+		strconv.FormatFloat(s.MeanLength, 'f', 2, 64),
+		strconv.FormatFloat(s.MeanAge, 'f', 2, 64),
+		strconv.Itoa(s.Births),
+		strconv.Itoa(s.Deaths),
+		strconv.Itoa(s.Aged),
+		strconv.Itoa(s.Moves),
+		strconv.Itoa(s.Eats),
+		strconv.FormatInt(s.Eaten, 10),
+	})
+	w.w.Flush()
+	return w.w.Error()
+}
+
+type jsonWriter struct {
+	enc *json.Encoder
+}
+
+func (w *jsonWriter) Write(s *Sample) error {
+	return w.enc.Encode(s)
+}
@@ -0,0 +1,92 @@
+package stats
+
+import "bytes"
+import "strings"
+import "testing"
+
+import "github.com/dnesting/alife/goalife/grid2d"
+import "github.com/dnesting/alife/goalife/grid2d/food"
+import "github.com/dnesting/alife/goalife/grid2d/org"
+import "github.com/dnesting/alife/goalife/grid2d/org/cpu1"
+
+func TestSample(t *testing.T) {
+	g := grid2d.New(10, 10, nil)
+	c := New(g)
+
+	o := org.Random()
+	o.Dir = 0 // east
+	o.Driver = &cpu1.Cpu{Code: cpu1.Bytecode{1, 2, 3, 4}}
+	o.AddEnergy(10000)
+	g.Put(0, 0, o, grid2d.PutAlways)
+	g.Put(1, 0, food.New(1000), grid2d.PutAlways)
+
+	if _, err := o.Eat(400); err != nil {
+		t.Fatalf("error eating: %v", err)
+	}
+	if _, err := o.Divide(&cpu1.Cpu{Code: cpu1.Bytecode{1, 2}}, 0.5); err != nil {
+		t.Fatalf("error dividing: %v", err)
+	}
+	// The child replaced the food.
+	g.Put(5, 5, food.New(300), grid2d.PutAlways)
+	for i := 0; i < 4; i++ {
+		o.Tick()
+	}
+
+	old := org.Random()
+	g.Put(9, 9, old, grid2d.PutAlways)
+	old.DieOf(org.ErrOldAge)
+	g.Remove(9, 9)
+
+	c.Update([]grid2d.Update{
+		{Old: &grid2d.Point{X: 0, Y: 0, V: o}, New: &grid2d.Point{X: 0, Y: 1, V: o}},
+		{Old: &grid2d.Point{X: 0, Y: 1, V: o}, New: &grid2d.Point{X: 0, Y: 1, V: food.New(1)}},
+		{Old: &grid2d.Point{X: 0, Y: 1, V: food.New(1)}, New: &grid2d.Point{X: 0, Y: 1, V: o}},
+		{Old: &grid2d.Point{X: 9, Y: 9, V: old}, New: &grid2d.Point{X: 9, Y: 9, V: food.New(1)}},
+	})
+
+	s := c.Sample()
+	if s.Orgs != 2 || s.Species != 2 || s.MeanLength != 3 || s.MeanAge != 2 {
+		t.Errorf("expected 2 orgs, 2 species, mean length 3 and age 2 got %+v", s)
+	}
+	if s.OrgEnergy != 10000+400-1000-4 || s.FoodEnergy != 300 {
+		t.Errorf("expected org energy %d and food energy 300 got %+v", 10000+400-1000-4, s)
+	}
+	if s.Births != 1 || s.Deaths != 2 || s.Aged != 1 || s.Moves != 1 || s.Eats != 1 || s.Eaten != 400 {
+		t.Errorf("expected 1 birth, 2 deaths, 1 of old age, 1 move and eat of 400 got %+v", s)
+	}
+
+	s = c.Sample()
+	if s.Orgs != 2 || s.Births != 0 || s.Deaths != 0 || s.Aged != 0 || s.Moves != 0 || s.Eats != 0 || s.Eaten != 0 {
+		t.Errorf("expected counts to be reset got %+v", s)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	s := &Sample{Elapsed: 1.5, Orgs: 2, Species: 1, OrgEnergy: 100, FoodEnergy: 50, MeanLength: 10, MeanAge: 3.5, Births: 1, Aged: 1, Eaten: 7}
+
+	var b bytes.Buffer
+	w, err := NewWriter(&b, "csv")
+	if err != nil {
+		t.Fatalf("error creating csv writer: %v", err)
+	}
+	w.Write(s)
+	w.Write(s)
+	expected := strings.Join(Fields, ",") + "\n" + strings.Repeat("1.500,2,1,100,50,10.00,3.50,1,0,1,0,0,7\n", 2)
+	if b.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, b.String())
+	}
+
+	b.Reset()
+	if w, err = NewWriter(&b, "json"); err != nil {
+		t.Fatalf("error creating json writer: %v", err)
+	}
+	w.Write(s)
+	expected = `{"Elapsed":1.5,"Orgs":2,"Species":1,"OrgEnergy":100,"FoodEnergy":50,"MeanLength":10,"MeanAge":3.5,"Births":1,"Deaths":0,"Aged":1,"Moves":0,"Eats":0,"Eaten":7}` + "\n"
+	if b.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, b.String())
+	}
+
+	if _, err := NewWriter(&b, "xml"); err == nil {
+		t.Errorf("expected an error creating an xml writer")
+	}
+}
@@ -17,6 +17,7 @@ const (
 	bottomLeftRune  = '└'
 	leftRune        = '│'
 	emptyRune       = ' '
+	hexEdgeRune     = '╲'
 )
 
 func writeRune(w io.Writer, r rune) {
@@ -81,8 +82,13 @@ func (p byCoordinate) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
 // if our caller will be doing this in a concurrent way.
 var locPool = sync.Pool{New: func() interface{} { return make([]grid2d.Point, 0) }}
 
-// PrintWorld renders g to w.
+// PrintWorld renders g to w.  Grids with a grid2d.Hex geometry are rendered
+// as a parallelogram, with each row offset by half a cell from the one above.
 func PrintWorld(w io.Writer, g grid2d.Grid) {
+	if g.Geometry() == grid2d.Hex {
+		printHexWorld(w, g)
+		return
+	}
 	points := locPool.Get().([]grid2d.Point)
 	width, height, _ := g.Locations(&points)
 	sort.Sort(byCoordinate(points))
@@ -101,3 +107,41 @@ func PrintWorld(w io.Writer, g grid2d.Grid) {
 	writeRune(w, '\n')
 	addFooter(w, width)
 }
+
+func writeRunes(w io.Writer, r rune, n int) {
+	for i := 0; i < n; i++ {
+		writeRune(w, r)
+	}
+}
+
+// printHexWorld renders a grid2d.Hex grid to w.  Each cell occupies two
+// columns, and each row is indented by one more column than the last, so
+// that every cell appears adjacent to its six neighbors.  The rendering is
+// thus 2*width+height+2 columns wide.
+func printHexWorld(w io.Writer, g grid2d.Grid) {
+	points := locPool.Get().([]grid2d.Point)
+	width, height, _ := g.Locations(&points)
+
+	cells := make([]rune, width*height)
+	for i := range cells {
+		cells[i] = emptyRune
+	}
+	for _, p := range points {
+		cells[p.Y*width+p.X] = RuneForOccupant(p.V)
+	}
+	locPool.Put(points)
+
+	addHeader(w, width*2)
+	for y := 0; y < height; y++ {
+		writeRunes(w, emptyRune, y)
+		writeRune(w, hexEdgeRune)
+		for x := 0; x < width; x++ {
+			writeRune(w, cells[y*width+x])
+			writeRune(w, emptyRune)
+		}
+		writeRune(w, hexEdgeRune)
+		writeRune(w, '\n')
+	}
+	writeRunes(w, emptyRune, height)
+	addFooter(w, width*2)
+}
@@ -0,0 +1,43 @@
+package term
+
+import "bytes"
+import "testing"
+
+import "github.com/dnesting/alife/goalife/grid2d"
+import "github.com/dnesting/alife/goalife/grid2d/food"
+
+func TestPrintWorld(t *testing.T) {
+	g := grid2d.New(4, 3, nil)
+	g.Put(0, 0, food.New(1), grid2d.PutAlways)
+	g.Put(3, 2, food.New(1), grid2d.PutAlways)
+
+	expected := "" +
+		"┌────┐\n" +
+		"│·   │\n" +
+		"│    │\n" +
+		"│   ·│\n" +
+		"└────┘"
+	var b bytes.Buffer
+	PrintWorld(&b, g)
+	if b.String() != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, b.String())
+	}
+}
+
+func TestPrintHexWorld(t *testing.T) {
+	g := grid2d.NewHex(4, 3, grid2d.Torus, nil)
+	g.Put(0, 0, food.New(1), grid2d.PutAlways)
+	g.Put(3, 2, food.New(1), grid2d.PutAlways)
+
+	expected := "" +
+		"┌────────┐\n" +
+		"╲·       ╲\n" +
+		" ╲        ╲\n" +
+		"  ╲      · ╲\n" +
+		"   └────────┘"
+	var b bytes.Buffer
+	PrintWorld(&b, g)
+	if b.String() != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, b.String())
+	}
+}
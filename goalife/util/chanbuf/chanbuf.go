@@ -33,6 +33,12 @@ type Queue interface {
 	QueuePutter
 }
 
+// Dropper is implemented by Queues that may discard values passed to Put,
+// to report how many have been discarded.
+type Dropper interface {
+	Dropped() int
+}
+
 // Feed calls q.Put on every value received from source, and invokes q.Done when
 // the channel is closed.
 func Feed(q QueuePutter, source <-chan interface{}) {
@@ -3,14 +3,16 @@ package chanbuf
 import "sync"
 
 type limitQueue struct {
-	cond   *sync.Cond
-	limit  int
-	values []interface{}
-	done   bool
+	cond    *sync.Cond
+	limit   int
+	values  []interface{}
+	done    bool
+	dropped int
 }
 
 // Limit creates a Queue that only retains the first size elements.
-// A size of 0 will result in all values being discarded.
+// A size of 0 will result in all values being discarded.  The Queue
+// implements Dropper.
 func Limit(size int) Queue {
 	return &limitQueue{
 		cond:  sync.NewCond(&sync.Mutex{}),
@@ -42,9 +44,17 @@ func (q *limitQueue) Put(value interface{}) {
 	if q.limit < 0 || len(q.values) < q.limit {
 		q.values = append(q.values, value)
 		q.cond.Signal()
+	} else {
+		q.dropped++
 	}
 }
 
+func (q *limitQueue) Dropped() int {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	return q.dropped
+}
+
 func (q *limitQueue) Done() {
 	q.cond.L.Lock()
 	defer q.cond.L.Unlock()
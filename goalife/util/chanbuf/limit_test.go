@@ -27,6 +27,10 @@ func TestLimit(t *testing.T) {
 		}
 	}
 
+	if d := q.(Dropper).Dropped(); d != 2 {
+		t.Errorf("expected 2 dropped got %d", d)
+	}
+
 	q.Done()
 	actual, ok := q.Get()
 	if ok {
@@ -4,20 +4,20 @@ import "container/ring"
 import "sync"
 
 type ringQueue struct {
-	cond   *sync.Cond
-	read   *ring.Ring
-	insert *ring.Ring
-	done   bool
+	cond    *sync.Cond
+	read    *ring.Ring
+	insert  *ring.Ring
+	done    bool
+	dropped int
 }
 
-// Ring creates a Queue that only retains the last size elements.
+// Ring creates a Queue that only retains the last size elements.  The
+// Queue implements Dropper.
 func Ring(size int) Queue {
-	q := &ringQueue{
+	return &ringQueue{
 		cond:   sync.NewCond(&sync.Mutex{}),
 		insert: ring.New(size),
 	}
-	q.read = q.insert
-	return q
 }
 
 func (q *ringQueue) Put(value interface{}) {
@@ -31,12 +31,20 @@ func (q *ringQueue) Put(value interface{}) {
 	if q.read == nil {
 		q.read = q.insert
 	} else if q.read == q.insert {
+		// We just overwrote the oldest value.
 		q.read = q.read.Next()
+		q.dropped++
 	}
 	q.insert = q.insert.Next()
 	q.cond.Signal()
 }
 
+func (q *ringQueue) Dropped() int {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	return q.dropped
+}
+
 func (q *ringQueue) Done() {
 	q.cond.L.Lock()
 	defer q.cond.L.Unlock()
@@ -0,0 +1,61 @@
+// Package rng provides the source of randomness shared by the world and
+// the organisms within it.  Routing all simulation randomness through
+// a single seedable source permits a run to be reproduced exactly when
+// its execution order is also controlled (see grid2d/sched).
+//
+// The functions in this package are safe for concurrent use.
+package rng
+
+import "math/rand"
+import "sync"
+
+type lockedSource struct {
+	mu  sync.Mutex
+	src rand.Source
+}
+
+func (s *lockedSource) Int63() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Int63()
+}
+
+func (s *lockedSource) Seed(seed int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.src.Seed(seed)
+}
+
+var src = &lockedSource{src: rand.NewSource(1)}
+var r = rand.New(src)
+
+// Seed re-initializes the shared source with seed.
+func Seed(seed int64) {
+	src.Seed(seed)
+}
+
+// Intn returns a non-negative pseudo-random number in [0,n).
+func Intn(n int) int {
+	return r.Intn(n)
+}
+
+// Float32 returns a pseudo-random number in [0.0,1.0).
+func Float32() float32 {
+	return r.Float32()
+}
+
+// Float64 returns a pseudo-random number in [0.0,1.0).
+func Float64() float64 {
+	return r.Float64()
+}
+
+// NormFloat64 returns a normally distributed number with mean 0 and
+// standard deviation 1.
+func NormFloat64() float64 {
+	return r.NormFloat64()
+}
+
+// Perm returns a pseudo-random permutation of the integers [0,n).
+func Perm(n int) []int {
+	return r.Perm(n)
+}
@@ -0,0 +1,226 @@
+// Package schema describes the format of saved files, so that files saved
+// by one version of the code can be recognized as incompatible with another
+// instead of being silently misinterpreted.
+//
+// A saved file begins with a Header, which identifies the kind of file and
+// the version of its format, and records the contents of each registered
+// table (such as the names of cpu1's opcodes, in order) at the time it was
+// saved.  Since bytecode refers to opcodes by their position, a genome saved
+// with one opcode table means something else entirely with another.
+//
+// Files saved before headers were introduced, which lack the Prefix
+// preceding a Header, are treated as having version 0, and the legacy
+// contents of each table.
+package schema
+
+import "bytes"
+import "encoding/gob"
+import "errors"
+import "fmt"
+import "hash/fnv"
+import "io"
+import "sort"
+import "strings"
+import "sync"
+
+// Version is the current version of the save format.
+const Version = 1
+
+// ErrIncompatible is returned (possibly wrapped) when a saved file cannot be
+// loaded as-is.
+var ErrIncompatible = errors.New("incompatible saved file")
+
+// Header describes the format and context of a saved file.
+type Header struct {
+	Magic   string              // identifies the kind of file
+	Version int                 // the version of the format
+	Tables  map[string][]string // the contents of each registered table
+	Params  map[string]string   // parameters describing the saved world, if any
+}
+
+// IncompatibleError describes a difference between a saved file and the
+// current code that prevents it from being loaded.
+type IncompatibleError struct {
+	What  string // what differs
+	Saved string // what the file was saved with
+	Want  string // what the current code expects
+}
+
+func (e *IncompatibleError) Error() string {
+	return fmt.Sprintf("%v: saved with %s %s, but expected %s", ErrIncompatible, e.What, e.Saved, e.Want)
+}
+
+func (e *IncompatibleError) Unwrap() error {
+	return ErrIncompatible
+}
+
+type table struct {
+	current func() []string
+	legacy  []string
+}
+
+var registry = struct {
+	sync.Mutex
+	tables map[string]table
+}{tables: make(map[string]table)}
+
+// Register registers a table named name whose contents must match for a
+// saved file to be loaded.  current returns the table's current contents,
+// and legacy describes its contents in files saved without a Header, or is
+// nil if the table did not exist then.
+func Register(name string, current func() []string, legacy []string) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.tables[name] = table{current, legacy}
+}
+
+// Current returns the current contents of the registered table named name.
+func Current(name string) []string {
+	registry.Lock()
+	t, ok := registry.tables[name]
+	registry.Unlock()
+	if !ok {
+		return nil
+	}
+	return t.current()
+}
+
+// Fingerprint summarizes the contents of a table for display.
+func Fingerprint(contents []string) string {
+	h := fnv.New32a()
+	io.WriteString(h, strings.Join(contents, "\x00"))
+	return fmt.Sprintf("%08x", h.Sum32())
+}
+
+// New returns a Header for a file of the given kind, recording the current
+// contents of every registered table.
+func New(magic string) Header {
+	registry.Lock()
+	defer registry.Unlock()
+	h := Header{
+		Magic:   magic,
+		Version: Version,
+		Tables:  make(map[string][]string),
+	}
+	for name, t := range registry.tables {
+		h.Tables[name] = t.current()
+	}
+	return h
+}
+
+// Legacy returns the Header assumed for a file of the given kind saved
+// without one.
+func Legacy(magic string) Header {
+	registry.Lock()
+	defer registry.Unlock()
+	h := Header{
+		Magic:  magic,
+		Tables: make(map[string][]string),
+	}
+	for name, t := range registry.tables {
+		if t.legacy != nil {
+			h.Tables[name] = t.legacy
+		}
+	}
+	return h
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Check returns an *IncompatibleError if a file with Header h cannot be
+// loaded as a file of the given kind.  Tables differing from the current
+// contents are reported in order of their names.
+func (h Header) Check(magic string) error {
+	if err := h.CheckMagic(magic); err != nil {
+		return err
+	}
+	if h.Version > Version {
+		return &IncompatibleError{"format version", fmt.Sprint(h.Version), fmt.Sprintf("at most %d", Version)}
+	}
+	for _, name := range h.Changed() {
+		saved, want := h.Tables[name], Current(name)
+		return &IncompatibleError{name, Fingerprint(saved), Fingerprint(want)}
+	}
+	return nil
+}
+
+// CheckMagic returns an *IncompatibleError if h does not describe a file
+// of the given kind.
+func (h Header) CheckMagic(magic string) error {
+	if h.Magic != magic {
+		return &IncompatibleError{"kind", fmt.Sprintf("%q", h.Magic), fmt.Sprintf("%q", magic)}
+	}
+	return nil
+}
+
+// Changed returns the names of the registered tables whose contents in h
+// differ from their current contents, in order.
+func (h Header) Changed() []string {
+	var names []string
+	for name, saved := range h.Tables {
+		if want := Current(name); want != nil && !equal(saved, want) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CheckParam returns an *IncompatibleError if the parameter named name was
+// saved in h with a value other than want.  Files saved without the
+// parameter are assumed to match.
+func (h Header) CheckParam(name, want string) error {
+	if saved, ok := h.Params[name]; ok && saved != want {
+		return &IncompatibleError{name, saved, want}
+	}
+	return nil
+}
+
+// Prefix precedes the Header of a saved file.  Since a gob stream never
+// begins with a zero byte, it distinguishes files saved with a Header from
+// those saved without one.
+const Prefix = "\x00goalife\n"
+
+// Write writes Prefix and h to w, and returns the gob.Encoder to be used to
+// write the rest of the file.
+func Write(w io.Writer, h Header) (*gob.Encoder, error) {
+	if _, err := io.WriteString(w, Prefix); err != nil {
+		return nil, err
+	}
+	enc := gob.NewEncoder(w)
+	if err := enc.Encode(h); err != nil {
+		return nil, err
+	}
+	return enc, nil
+}
+
+// Read reads a Header written by Write from r, and returns it along with the
+// gob.Decoder to be used to read the rest of the file.  If the file was
+// saved without a Header, returns the Legacy Header (whose Version is 0) and
+// a gob.Decoder reading the file from the beginning.
+func Read(r io.Reader, magic string) (Header, *gob.Decoder, error) {
+	buf := make([]byte, len(Prefix))
+	n, err := io.ReadFull(r, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return Header{}, nil, err
+	}
+	if string(buf[:n]) != Prefix {
+		return Legacy(magic), gob.NewDecoder(io.MultiReader(bytes.NewReader(buf[:n]), r)), nil
+	}
+	var h Header
+	dec := gob.NewDecoder(r)
+	if err := dec.Decode(&h); err != nil {
+		return h, nil, err
+	}
+	return h, dec, nil
+}
@@ -0,0 +1,89 @@
+package schema
+
+import "bytes"
+import "encoding/gob"
+import "reflect"
+import "testing"
+
+func TestCheck(t *testing.T) {
+	current := []string{"a", "b", "c"}
+	Register("test", func() []string { return current }, []string{"a", "b"})
+
+	h := New("magic")
+	if err := h.Check("magic"); err != nil {
+		t.Errorf("expected a new header to check cleanly, got %v", err)
+	}
+	if err := h.Check("other"); err == nil {
+		t.Errorf("expected an error checking the wrong magic")
+	}
+	if err := Legacy("magic").Check("magic"); err == nil {
+		t.Errorf("expected an error checking a legacy header with a changed table")
+	}
+	Register("new", func() []string { return []string{"x"} }, nil)
+	if _, ok := Legacy("magic").Tables["new"]; ok {
+		t.Errorf("expected a legacy header to omit a table that did not exist")
+	}
+
+	h.Version = Version + 1
+	if err := h.Check("magic"); err == nil {
+		t.Errorf("expected an error checking a newer version")
+	}
+	h.Version = Version
+
+	current = []string{"a", "c", "b"}
+	if got := h.Changed(); !reflect.DeepEqual(got, []string{"test"}) {
+		t.Errorf("expected [test] to have changed, got %v", got)
+	}
+	err := h.Check("magic")
+	if ie, ok := err.(*IncompatibleError); !ok || ie.What != "test" {
+		t.Errorf("expected an *IncompatibleError for test got %v", err)
+	}
+
+	h.Params = map[string]string{"geometry": "square"}
+	if err := h.CheckParam("geometry", "hex"); err == nil {
+		t.Errorf("expected an error checking a different param")
+	}
+	if err := h.CheckParam("geometry", "square"); err != nil {
+		t.Errorf("expected a matching param to check cleanly, got %v", err)
+	}
+	if err := h.CheckParam("topology", "torus"); err != nil {
+		t.Errorf("expected a missing param to check cleanly, got %v", err)
+	}
+}
+
+func TestRead(t *testing.T) {
+	var b bytes.Buffer
+	enc, err := Write(&b, New("magic"))
+	if err != nil {
+		t.Fatalf("error writing header: %v", err)
+	}
+	enc.Encode(42)
+	h, dec, err := Read(&b, "magic")
+	if err != nil || h.Version != Version {
+		t.Fatalf("expected a version %d header got %v (err=%v)", Version, h, err)
+	}
+	var v int
+	if err := dec.Decode(&v); err != nil || v != 42 {
+		t.Errorf("expected 42 after the header got %v (err=%v)", v, err)
+	}
+
+	// A stream saved without a header.
+	b.Reset()
+	gob.NewEncoder(&b).Encode(struct{ Count int }{10})
+	h, dec, err = Read(&b, "magic")
+	if err != nil || h.Version != 0 || h.Magic != "magic" {
+		t.Fatalf("expected a legacy header got %v (err=%v)", h, err)
+	}
+	var p struct{ Count int }
+	if err := dec.Decode(&p); err != nil || p.Count != 10 {
+		t.Errorf("expected the stream from its beginning got %v (err=%v)", p, err)
+	}
+
+	// A stream saved without a header, shorter than Prefix.
+	h, dec, err = Read(bytes.NewReader([]byte{1, 2}), "magic")
+	if err != nil || h.Version != 0 {
+		t.Errorf("expected a legacy header got %v (err=%v)", h, err)
+	} else if err := dec.Decode(&p); err == nil {
+		t.Errorf("expected an error decoding a truncated stream")
+	}
+}